@@ -19,11 +19,15 @@ type ImageCoord struct {
 }
 
 func CreateImageCoord(img dbo.ImageCoord, creator func(uint64) string) ImageCoord {
+	color := "primary"
+	if img.GPSSource != nil && *img.GPSSource == dbo.GPSSourceGPX {
+		color = "gpx"
+	}
 	return ImageCoord{
 		Image:     ImageID(img.ID),
 		Latitude:  *img.Latitude,
 		Longitude: *img.Longitude,
-		Color:     "primary",
+		Color:     color,
 		URL:       creator(uint64(img.ID)),
 		ImageURL:  routes.CreateDerivativePath(routes.ImageID(img.ID), "s400"),
 		Label:     img.Title,
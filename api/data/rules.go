@@ -0,0 +1,11 @@
+package data
+
+import "github.com/ignisVeneficus/lumenta/ruleengine"
+
+// RuleTestRequest tests the given rules, or the stored rule of the album if
+// rules is not set. The album is the reference of not_in_child_albums.
+type RuleTestRequest struct {
+	AlbumID *uint64               `json:"album_id"`
+	Rules   *ruleengine.RuleGroup `json:"rules"`
+	Samples *int                  `json:"samples"`
+}
@@ -0,0 +1,26 @@
+package endpoint
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ignisVeneficus/logging"
+	apiData "github.com/ignisVeneficus/lumenta/api/data"
+	"github.com/ignisVeneficus/lumenta/config"
+	"github.com/ignisVeneficus/lumenta/derivative"
+)
+
+// DerivativesStatus returns the queue depths, the last finished jobs and the
+// images failed on their last run.
+func DerivativesStatus(cfg config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logg, _ := logging.Enter(c.Request.Context(), "api/admin/derivatives/status", nil, nil)
+		status := derivative.Get().Status()
+		ret := apiData.APIResponse[derivative.Status]{
+			Status: apiData.StatuszOK,
+			Data:   status,
+		}
+		c.IndentedJSON(http.StatusOK, ret)
+		logging.Exit(logg, "ok", map[string]any{"running": status.Running, "failures": len(status.Failures)})
+	}
+}
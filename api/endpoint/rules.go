@@ -0,0 +1,143 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/api"
+	apiData "github.com/ignisVeneficus/lumenta/api/data"
+	"github.com/ignisVeneficus/lumenta/config"
+	"github.com/ignisVeneficus/lumenta/db"
+	"github.com/ignisVeneficus/lumenta/db/dao"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/pipeline"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
+	"github.com/ignisVeneficus/lumenta/server/routes"
+)
+
+const defaultRuleTestSamples = 10
+
+func RulesTest(cfg config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req apiData.RuleTestRequest
+		ret := apiData.APIResponse[*pipeline.RuleTestReport]{}
+		logg, ctx := logging.Enter(c.Request.Context(), "api/admin/rules/test", nil, nil)
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logging.ExitErr(logg, err)
+			ret.HandleError("invalid rules: " + err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, ret)
+			return
+		}
+		samples := defaultRuleTestSamples
+		if req.Samples != nil && *req.Samples >= 0 {
+			samples = *req.Samples
+		}
+
+		database := db.GetDatabase()
+		var report *pipeline.RuleTestReport
+		var err error
+		switch {
+		case req.Rules != nil:
+			report, err = pipeline.RunRuleTest(database, ctx, *req.Rules, req.AlbumID, samples)
+		case req.AlbumID != nil:
+			report, err = pipeline.RunAlbumRuleTest(database, ctx, *req.AlbumID, samples)
+		default:
+			err = pipeline.ErrRuleTestInput
+		}
+		if err != nil {
+			logging.ExitErr(logg, err)
+			switch {
+			case errors.Is(err, pipeline.ErrRuleTestInput), errors.Is(err, ruleengine.ErrEmptyFilter):
+				ret.HandleError(err.Error())
+				c.AbortWithStatusJSON(http.StatusBadRequest, ret)
+			case errors.Is(err, dao.ErrDataNotFound):
+				ret.HandleError("album not found")
+				c.AbortWithStatusJSON(http.StatusNotFound, ret)
+			default:
+				ret.HandleError("internal error")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, ret)
+			}
+			return
+		}
+
+		ret.Data = report
+		ret.Status = apiData.StatuszOK
+		c.IndentedJSON(http.StatusOK, ret)
+		logging.Exit(logg, "ok", map[string]any{
+			"total":   report.Total,
+			"matched": report.Matched,
+		})
+	}
+}
+
+// ImageExplain evaluates the current album rules on the image,
+// ?album=ID limits it to one album.
+func ImageExplain(cfg config.Config) gin.HandlerFunc {
+	return imageExplain("api/admin/images/explain", func(c *gin.Context, ctx context.Context, imageID dbo.ImageID) (*pipeline.ExplainResult, error) {
+		var albumID *uint64
+		if s := c.Query(routes.AlbumParam); s != "" {
+			id, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return nil, errInvalidExplainAlbum
+			}
+			albumID = &id
+		}
+		return pipeline.ExplainImageAlbums(db.GetDatabase(), ctx, imageID, albumID)
+	})
+}
+
+// ImageExplainACL evaluates the ACL rules of the sync config on the image.
+func ImageExplainACL(cfg config.Config) gin.HandlerFunc {
+	return imageExplain("api/admin/images/explain/acl", func(c *gin.Context, ctx context.Context, imageID dbo.ImageID) (*pipeline.ExplainResult, error) {
+		return pipeline.ExplainImageACL(db.GetDatabase(), ctx, cfg.Sync.ACLRules, imageID)
+	})
+}
+
+var errInvalidExplainAlbum = errors.New("invalid album id")
+
+func imageExplain(scope string, explain func(c *gin.Context, ctx context.Context, imageID dbo.ImageID) (*pipeline.ExplainResult, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		imageIDStr := c.Param("id")
+		logg, ctx := logging.Enter(c.Request.Context(), scope, imageIDStr, map[string]any{
+			"image_id": imageIDStr,
+			"album":    c.Query(routes.AlbumParam),
+		})
+		ret := apiData.APIResponse[*pipeline.ExplainResult]{}
+
+		imageID, err := api.ParseImageID(imageIDStr)
+		if err != nil {
+			logging.ExitErr(logg, fmt.Errorf("invalid image id"))
+			ret.HandleError("invalid image id")
+			c.AbortWithStatusJSON(http.StatusBadRequest, ret)
+			return
+		}
+
+		result, err := explain(c, ctx, dbo.ImageID(imageID))
+		if err != nil {
+			logging.ExitErr(logg, err)
+			switch {
+			case errors.Is(err, errInvalidExplainAlbum):
+				ret.HandleError(err.Error())
+				c.AbortWithStatusJSON(http.StatusBadRequest, ret)
+			case errors.Is(err, dao.ErrDataNotFound):
+				ret.HandleError(err.Error())
+				c.AbortWithStatusJSON(http.StatusNotFound, ret)
+			default:
+				ret.HandleError("internal error")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, ret)
+			}
+			return
+		}
+
+		ret.Data = result
+		ret.Status = apiData.StatuszOK
+		c.IndentedJSON(http.StatusOK, ret)
+		logging.Exit(logg, "ok", map[string]any{"entries": len(result.Entries)})
+	}
+}
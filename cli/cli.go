@@ -2,14 +2,20 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/ignisVeneficus/lumenta/config"
+	"github.com/ignisVeneficus/lumenta/db"
+	"github.com/ignisVeneficus/lumenta/derivative"
 	"github.com/ignisVeneficus/lumenta/internal/i18n"
 	"github.com/ignisVeneficus/lumenta/pipeline"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
 	"github.com/ignisVeneficus/lumenta/server"
+	"gopkg.in/yaml.v3"
 )
 
 func Run(cfg config.Config, i18n *i18n.Service, ctx context.Context) error {
@@ -35,6 +41,12 @@ func Run(cfg config.Config, i18n *i18n.Service, ctx context.Context) error {
 	case "import":
 		return runImport(cfg, os.Args[2:])
 
+	case "rules":
+		return runRules(ctx, os.Args[2:])
+
+	case "derivatives":
+		return runDerivatives(cfg, ctx, os.Args[2:])
+
 	case "-h", "--help", "help":
 		printGlobalHelp()
 		return nil
@@ -50,6 +62,13 @@ func printGlobalHelp() {
 Commands:
   sync        Synchronize filesystem with database
   rebuild     Rebuild albums and metadata
+  rules test  Lint and evaluate a rule group on the images
+  derivatives generate
+              Generate the derivatives of every image
+  derivatives gc
+              Delete the orphaned derivative files
+  derivatives migrate
+              Rename the derivative files to the current naming
   status      Show current state
 
 Use "%s <command> --help" for command-specific options.
@@ -57,6 +76,7 @@ Use "%s <command> --help" for command-specific options.
 }
 
 func runServe(cfg config.Config, i18n *i18n.Service, ctx context.Context) error {
+	go pipeline.RunPeriodicAlbumRefresh(ctx, cfg)
 	server.Server(cfg, i18n, ctx)
 	return nil
 }
@@ -95,6 +115,190 @@ func runSync(cfg config.Config, ctx context.Context, args []string) error {
 	err := pipeline.RunGlobalSync(ctx, cfg, cleanUp, force)
 	return err
 }
+
+func runRules(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		return fmt.Errorf("usage: %s rules test [options]", os.Args[0])
+	}
+	fs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s rules test [options]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Options:")
+		fs.PrintDefaults()
+	}
+
+	albumID := fs.Uint64("album", 0, "test the stored rule of the album")
+	file := fs.String("file", "", "rule group file (yaml or json)")
+	samples := fs.Int("samples", 10, "number of sample images")
+	asJSON := fs.Bool("json", false, "print the report as json")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var report *pipeline.RuleTestReport
+	var err error
+	switch {
+	case *file != "":
+		raw, rerr := os.ReadFile(*file)
+		if rerr != nil {
+			return rerr
+		}
+		// yaml is a superset of json
+		var group ruleengine.RuleGroup
+		if err := yaml.Unmarshal(raw, &group); err != nil {
+			return fmt.Errorf("%s: %w", *file, err)
+		}
+		var ref *uint64
+		if *albumID != 0 {
+			ref = albumID
+		}
+		report, err = pipeline.RunRuleTest(db.GetDatabase(), ctx, group, ref, *samples)
+	case *albumID != 0:
+		report, err = pipeline.RunAlbumRuleTest(db.GetDatabase(), ctx, *albumID, *samples)
+	default:
+		fs.Usage()
+		return pipeline.ErrRuleTestInput
+	}
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printRuleTestReport(report)
+	return nil
+}
+
+func printRuleTestReport(report *pipeline.RuleTestReport) {
+	fmt.Printf("Matched: %d / %d\n", report.Matched, report.Total)
+	if len(report.Stats) > 0 {
+		fmt.Println("\nRules:")
+		for _, s := range report.Stats {
+			fmt.Printf("  %-24s %-12s evaluated: %d true: %d false: %d unknown: %d\n",
+				s.Path, s.Type, s.Evaluated, s.True, s.False, s.Unknown)
+		}
+	}
+	if len(report.Samples) > 0 {
+		fmt.Println("\nSamples:")
+		for _, s := range report.Samples {
+			fmt.Printf("  %d\t%s/%s/%s\n", s.ID, s.Root, s.Path, s.Filename)
+		}
+	}
+	if len(report.Warnings) > 0 {
+		fmt.Println("\nWarnings:")
+		for _, w := range report.Warnings {
+			path := w.Path
+			if path == "" {
+				path = "group"
+			}
+			fmt.Printf("  %s [%s] %s\n", path, w.Code, w.Message)
+		}
+	}
+}
+
+func runDerivatives(cfg config.Config, ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s derivatives generate|gc|migrate [options]", os.Args[0])
+	}
+	switch args[0] {
+	case "generate":
+		return runDerivativesGenerate(cfg, ctx, args[1:])
+	case "gc":
+		return runDerivativesGC(cfg, ctx, args[1:])
+	case "migrate":
+		return runDerivativesMigrate(cfg, ctx, args[1:])
+	default:
+		return fmt.Errorf("usage: %s derivatives generate|gc|migrate [options]", os.Args[0])
+	}
+}
+
+func runDerivativesGenerate(cfg config.Config, ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("derivatives generate", flag.ContinueOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s derivatives generate [options]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Options:")
+		fs.PrintDefaults()
+	}
+
+	missing := fs.Bool("missing", false, "generate only the derivatives without a file")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	progress := func(s derivative.BatchStats) {
+		fmt.Printf("  %d / %d done, %d failed\n", s.Done+s.Failed, s.Queued, s.Failed)
+	}
+	stats, failures, err := derivative.Backfill(ctx, db.GetDatabase(), cfg.Derivatives.Sizes, cfg.Filesystem, *missing, 5*time.Second, progress)
+	fmt.Printf("Images: %d queued, %d done, %d failed, %d skipped (already queued)\n", stats.Queued, stats.Done, stats.Failed, stats.Skipped)
+	if len(failures) > 0 {
+		fmt.Println("\nFailures:")
+		for _, f := range failures {
+			fmt.Printf("  %d\t%s: %v\n", f.Image, f.SourcePath, f.Err)
+		}
+	}
+	return err
+}
+
+func runDerivativesGC(cfg config.Config, ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("derivatives gc", flag.ContinueOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s derivatives gc [options]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Options:")
+		fs.PrintDefaults()
+	}
+
+	dryRun := fs.Bool("dry-run", false, "only list the orphaned files")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := derivative.CollectGarbage(ctx, db.GetDatabase(), cfg.Derivatives.Sizes, cfg.Filesystem, *dryRun)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		for _, path := range report.Orphans {
+			fmt.Println(path)
+		}
+		fmt.Printf("\nFiles: %d scanned, %d orphaned, %d bytes reclaimable\n", report.Scanned, len(report.Orphans), report.Bytes)
+		return nil
+	}
+	fmt.Printf("Files: %d scanned, %d orphaned, %d deleted, %d bytes reclaimed\n", report.Scanned, len(report.Orphans), report.Deleted, report.Bytes)
+	return nil
+}
+
+func runDerivativesMigrate(cfg config.Config, ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("derivatives migrate", flag.ContinueOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s derivatives migrate [options]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Options:")
+		fs.PrintDefaults()
+	}
+
+	dryRun := fs.Bool("dry-run", false, "only count the files to relocate and remove")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := derivative.MigratePaths(ctx, db.GetDatabase(), cfg.Derivatives.Sizes, cfg.Filesystem, *dryRun)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Files: %d moved, %d removed (regenerated on request), %d failed\n", report.Moved, report.Removed, report.Failed)
+	return nil
+}
+
 func runExport(cfg config.Config, args []string) error {
 	return nil
 }
@@ -31,6 +31,7 @@ type Config struct {
 	Filesystem   filesystem.FilesystemConfig     `yaml:"filesystem"`
 	Auth         auth.AuthConfig                 `yaml:"auth"`
 	Derivatives  derivative.DerivativesConfig    `yaml:"derivatives"`
+	Decoder      derivative.DecoderConfig        `yaml:"decoder"`
 	Sync         sync.SyncConfig                 `yaml:"sync"`
 	Site         site.SiteConfig                 `yaml:"site"`
 	Presentation presentation.PresentationConfig `yaml:"presentation"`
@@ -1,8 +1,55 @@
 package derivative
 
+import (
+	"strings"
+	"time"
+
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
 type DerivativeSizeMode string
 
-type DerivativesConfig []DerivativeConfig
+type DerivativesConfig struct {
+	// generate the derivatives of the new and changed images during sync
+	Pregenerate bool `yaml:"pregenerate"`
+	// delete the orphaned derivative files after a full (cleanup) sync
+	GCAfterSync bool `yaml:"gc_after_sync"`
+	// parallel generations, default the number of CPUs
+	Workers int `yaml:"workers"`
+	// queued jobs per priority, the bulk submitters wait above it, default 1000
+	QueueSize int `yaml:"queue_size"`
+	// how long a request waits for the generation of a missing file before 202, default 5s
+	WaitTimeout time.Duration      `yaml:"wait_timeout"`
+	Sizes       []DerivativeConfig `yaml:"sizes"`
+	// external encoder of the formats without a Go encoder (webp, avif)
+	Encoders map[string]ExternalEncoderConfig `yaml:"encoders"`
+}
+
+// Output formats of the derivatives, jpeg is always generated as the fallback.
+const (
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+	FormatAVIF = "avif"
+)
+
+// FormatExt is the file extension of the format.
+func FormatExt(format string) string {
+	if format == FormatJPEG {
+		return "jpg"
+	}
+	return format
+}
+
+func FormatMimeType(format string) string {
+	return "image/" + format
+}
+
+// ExternalEncoderConfig is a converter command encoding a png to the format.
+type ExternalEncoderConfig struct {
+	Command string        `yaml:"command"` // pl: "/usr/bin/cwebp"
+	Args    []string      `yaml:"args"`    // {input}, {output} and {quality} are replaced
+	Timeout time.Duration `yaml:"timeout"` // default 60s
+}
 
 var (
 	DerivativeSizeCrop   DerivativeSizeMode = "crop"
@@ -14,6 +61,101 @@ type DerivativeConfig struct {
 	Postfix    string             `yaml:"postfix"`
 	MaxWidth   int                `yaml:"max_width"`
 	MaxHeight  int                `yaml:"max_height"`
-	Mode       DerivativeSizeMode `yaml:"mode"` // crop | fit
-	JPGQuality int                `yaml:"jpg_quality"`
+	Mode       DerivativeSizeMode `yaml:"mode"`        // crop | fit
+	JPGQuality int                `yaml:"jpg_quality"` // the {quality} of the encoders too
+	Formats    []string           `yaml:"formats"`     // jpeg (default), webp, avif; in order of preference
+	// smaller widths of the same aspect for srcset, generated as own derivatives
+	Widths []int  `yaml:"widths"`
+	Ladder string `yaml:"-"` // postfix of the size, if generated from its widths
+	// watermark and exif of every role, overridden by the roles
+	PolicyConfig `yaml:",inline"`
+	Roles        map[dbo.ACLRole]PolicyConfig `yaml:"roles"`
+}
+
+// Ladder returns the derivative of the postfix and its smaller widths ordered by width,
+// the srcset candidates. nil if the postfix is not defined.
+func Ladder(sizes []DerivativeConfig, postfix string) []DerivativeConfig {
+	var top *DerivativeConfig
+	var ret []DerivativeConfig
+	for i := range sizes {
+		switch {
+		case sizes[i].Postfix == postfix:
+			top = &sizes[i]
+		case sizes[i].Ladder == postfix:
+			ret = append(ret, sizes[i])
+		}
+	}
+	if top == nil {
+		return nil
+	}
+	return append(ret, *top)
+}
+
+// HasFormat reports whether the format is generated for the derivative.
+func (d DerivativeConfig) HasFormat(format string) bool {
+	for _, f := range d.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// DecoderConfig controls how the originals are decoded for the derivatives.
+// Order: native Go decoders, embedded JPEG preview (exiftool), external converter.
+type DecoderConfig struct {
+	Preview        *bool                  `yaml:"preview"` // JpgFromRaw / PreviewImage via exiftool, default true
+	External       *ExternalDecoderConfig `yaml:"external"`
+	Color          ColorConfig            `yaml:"color"`
+	PreviewEnabled bool                   `yaml:"-"`
+}
+
+// ColorConfig is the color management of the derivatives: the originals with
+// an embedded non-sRGB ICC profile are converted by an external tool.
+// Every derivative is tagged with the sRGB profile, the built-in one by default.
+type ColorConfig struct {
+	Convert         *ExternalDecoderConfig `yaml:"convert"`      // writes the image converted to sRGB to stdout
+	SRGBProfile     string                 `yaml:"srgb_profile"` // icc file embedded in the derivatives, default built-in
+	SRGBProfileData []byte                 `yaml:"-"`            // the profile of the file or the built-in one
+}
+
+// Enabled: color management is on with a converter or an sRGB profile configured.
+func (c ColorConfig) Enabled() bool {
+	return c.Convert != nil || c.SRGBProfile != ""
+}
+
+// Inputs is the color part of the derivative fingerprint, empty without color management.
+// The built-in profile is no input: it only tags the sRGB pixels, the browsers
+// show the untagged files of before the same way.
+func (c ColorConfig) Inputs() string {
+	if !c.Enabled() {
+		return ""
+	}
+	ret := "color:" + c.SRGBProfile
+	if c.Convert != nil {
+		ret += "|" + c.Convert.Command + " " + strings.Join(c.Convert.Args, " ")
+	}
+	return ret
+}
+
+// ExternalDecoderConfig is a converter command writing the decoded image
+// (png, jpeg, tiff, bmp) to stdout.
+type ExternalDecoderConfig struct {
+	Command              string              `yaml:"command"`    // pl: "/usr/bin/magick"
+	Args                 []string            `yaml:"args"`       // {input} is replaced with the source path
+	Extensions           []string            `yaml:"extensions"` // empty: used for every format
+	Timeout              time.Duration       `yaml:"timeout"`    // default 60s
+	NormalizedExtensions map[string]struct{} `yaml:"-"`
+}
+
+// Accepts reports whether the converter is used for the normalized extension.
+func (e *ExternalDecoderConfig) Accepts(ext string) bool {
+	if e == nil {
+		return false
+	}
+	if len(e.NormalizedExtensions) == 0 {
+		return true
+	}
+	_, ok := e.NormalizedExtensions[ext]
+	return ok
 }
@@ -0,0 +1,147 @@
+package derivative
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
+type WatermarkPosition string
+
+const (
+	WatermarkTopLeft     WatermarkPosition = "top-left"
+	WatermarkTopRight    WatermarkPosition = "top-right"
+	WatermarkBottomLeft  WatermarkPosition = "bottom-left"
+	WatermarkBottomRight WatermarkPosition = "bottom-right"
+	WatermarkCenter      WatermarkPosition = "center"
+)
+
+// ExifGPS in the exif list stands for every GPS tag.
+const ExifGPS = "GPS"
+
+// exifGuestExclusions are exiftool exclusions put after the whitelist of the
+// guests: a group entry (EXIF:all, XMP:all, all) or a single tag
+// (GPSLatitude, Composite:GPSPosition) would copy the position too.
+var exifGuestExclusions = []string{"--GPS:all", "--XMP-exif:GPS*", "--Composite:GPS*"}
+
+// WatermarkConfig: a text or a png drawn on the derivative.
+type WatermarkConfig struct {
+	Text     string            `yaml:"text"`
+	Image    string            `yaml:"image"`    // png file, used instead of the text
+	Position WatermarkPosition `yaml:"position"` // default bottom-right
+	Opacity  float64           `yaml:"opacity"`  // 0-1, default 0.5
+	Scale    float64           `yaml:"scale"`    // width relative to the derivative, default 0.2
+	// content hash of the png: a logo replaced at the same path changes the fingerprint
+	ImageHash string `yaml:"-"`
+}
+
+// PolicyConfig is what a derivative contains besides the pixels.
+type PolicyConfig struct {
+	Watermark *WatermarkConfig `yaml:"watermark"`
+	Exif      []string         `yaml:"exif"` // EXIF tags copied from the original (exiftool names), empty: none
+}
+
+// Roles are the ACL levels of the policies, the derivative variants.
+var Roles = []dbo.ACLRole{dbo.RoleGuest, dbo.RoleUser, dbo.RoleAdmin}
+
+func (p PolicyConfig) Empty() bool {
+	return p.Watermark == nil && len(p.Exif) == 0
+}
+
+// Inputs is the policy part of the derivative fingerprint.
+func (p PolicyConfig) Inputs() string {
+	if p.Empty() {
+		return ""
+	}
+	ret := "exif:" + strings.Join(p.Exif, ",")
+	if w := p.Watermark; w != nil {
+		ret += fmt.Sprintf("|wm:%s|%s|%s|%s|%.2f|%.3f", w.Text, w.Image, w.ImageHash, w.Position, w.Opacity, w.Scale)
+	}
+	return ret
+}
+
+// RoleDependent reports whether the roles may get different derivatives:
+// any copied tag may carry GPS, the guests get it without.
+func (d DerivativeConfig) RoleDependent() bool {
+	return len(d.Roles) > 0 || len(d.Exif) > 0
+}
+
+// ForRole returns the derivative with the policy of the role, the GPS tags
+// are never copied to the derivatives of the guests.
+func (d DerivativeConfig) ForRole(role dbo.ACLRole) DerivativeConfig {
+	if p, ok := d.Roles[role]; ok {
+		d.PolicyConfig = p
+	}
+	if role == dbo.RoleGuest && len(d.Exif) > 0 {
+		exif := make([]string, 0, len(d.Exif)+len(exifGuestExclusions))
+		for _, t := range d.Exif {
+			if t != ExifGPS {
+				exif = append(exif, t)
+			}
+		}
+		d.Exif = append(exif, exifGuestExclusions...)
+	}
+	return d
+}
+
+// Variants returns the distinct derivatives of the roles.
+func (d DerivativeConfig) Variants() []DerivativeConfig {
+	seen := map[string]struct{}{}
+	ret := []DerivativeConfig{}
+	for _, role := range Roles {
+		v := d.ForRole(role)
+		key := v.Inputs()
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func (p *PolicyConfig) transform() {
+	if w := p.Watermark; w != nil {
+		if w.Position == "" {
+			w.Position = WatermarkBottomRight
+		}
+		if w.Opacity == 0 {
+			w.Opacity = 0.5
+		}
+		if w.Scale == 0 {
+			w.Scale = 0.2
+		}
+		if w.Image != "" {
+			// a missing file is reported by the validation
+			if raw, err := os.ReadFile(w.Image); err == nil {
+				sum := sha256.Sum256(raw)
+				w.ImageHash = hex.EncodeToString(sum[:])
+			}
+		}
+	}
+	// the pixels are rotated, a copied orientation would turn them again
+	exif := []string{}
+	seen := map[string]struct{}{}
+	for _, t := range p.Exif {
+		// exclusions are added by ForRole only
+		t = strings.TrimLeft(strings.TrimSpace(t), "-")
+		if strings.EqualFold(t, ExifGPS) {
+			t = ExifGPS
+		}
+		if t == "" || strings.EqualFold(t, "Orientation") {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		exif = append(exif, t)
+	}
+	sort.Strings(exif)
+	p.Exif = exif
+}
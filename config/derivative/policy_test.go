@@ -0,0 +1,27 @@
+package derivative
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyInputsWatermarkContent(t *testing.T) {
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	inputs := func(content string) string {
+		t.Helper()
+		if err := os.WriteFile(logo, []byte(content), 0o600); err != nil {
+			t.Fatalf("setup failed: %v", err)
+		}
+		p := PolicyConfig{Watermark: &WatermarkConfig{Image: logo}}
+		p.transform()
+		return p.Inputs()
+	}
+	first := inputs("old logo")
+	if again := inputs("old logo"); again != first {
+		t.Fatalf("expected the same inputs for the same logo, got %q and %q", first, again)
+	}
+	if replaced := inputs("new logo"); replaced == first {
+		t.Fatalf("expected new inputs for a replaced logo, got %q", replaced)
+	}
+}
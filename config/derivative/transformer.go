@@ -1,11 +1,138 @@
 package derivative
 
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
 func (derivatives *DerivativesConfig) TransformAfterValidation() error {
-	for i := range *derivatives {
-		d := &(*derivatives)[i]
+	for i := range derivatives.Sizes {
+		d := &derivatives.Sizes[i]
 		if d.JPGQuality < 1 || d.JPGQuality > 100 {
 			d.JPGQuality = 75
 		}
 	}
 	return nil
 }
+
+func (derivatives *DerivativesConfig) TransformBeforeValidation() error {
+	if derivatives.Workers == 0 {
+		derivatives.Workers = runtime.NumCPU()
+	}
+	if derivatives.QueueSize == 0 {
+		derivatives.QueueSize = 1000
+	}
+	if derivatives.WaitTimeout == 0 {
+		derivatives.WaitTimeout = 5 * time.Second
+	}
+	for i := range derivatives.Sizes {
+		d := &derivatives.Sizes[i]
+		// jpeg last, the fallback of every client
+		formats := []string{}
+		seen := map[string]struct{}{FormatJPEG: {}}
+		for _, f := range d.Formats {
+			f = strings.ToLower(strings.TrimSpace(f))
+			if f == "jpg" {
+				f = FormatJPEG
+			}
+			if _, ok := seen[f]; ok {
+				continue
+			}
+			seen[f] = struct{}{}
+			formats = append(formats, f)
+		}
+		d.Formats = append(formats, FormatJPEG)
+		d.PolicyConfig.transform()
+		// the roles are matched in lower case
+		roles := make(map[dbo.ACLRole]PolicyConfig, len(d.Roles))
+		for role, p := range d.Roles {
+			key := dbo.ACLRole(strings.ToLower(string(role)))
+			if _, ok := roles[key]; ok {
+				return fmt.Errorf("derivative %s: role %s is defined twice", d.Name, key)
+			}
+			p.transform()
+			roles[key] = p
+		}
+		if d.Roles != nil {
+			d.Roles = roles
+		}
+	}
+	derivatives.Sizes = expandLadders(derivatives.Sizes)
+	for format, e := range derivatives.Encoders {
+		if e.Timeout == 0 {
+			e.Timeout = time.Minute
+			derivatives.Encoders[format] = e
+		}
+	}
+	return nil
+}
+
+// expandLadders adds the widths of a ladder as own derivatives after their size:
+// same mode, aspect and formats, <name>-<width> and <postfix>-<width>.
+// The widths not below max_width are skipped, the size itself is the top of the ladder.
+func expandLadders(sizes []DerivativeConfig) []DerivativeConfig {
+	ret := make([]DerivativeConfig, 0, len(sizes))
+	for _, d := range sizes {
+		ret = append(ret, d)
+		if d.MaxWidth <= 0 {
+			continue
+		}
+		widths := append([]int(nil), d.Widths...)
+		sort.Ints(widths)
+		for _, w := range widths {
+			if w <= 0 || w >= d.MaxWidth {
+				continue
+			}
+			rung := d
+			rung.Name = fmt.Sprintf("%s-%d", d.Name, w)
+			rung.Postfix = fmt.Sprintf("%s-%d", d.Postfix, w)
+			rung.MaxWidth = w
+			if d.MaxHeight > 0 {
+				rung.MaxHeight = int(math.Round(float64(d.MaxHeight) * float64(w) / float64(d.MaxWidth)))
+			}
+			rung.Widths = nil
+			rung.Ladder = d.Postfix
+			ret = append(ret, rung)
+		}
+	}
+	return ret
+}
+
+// builtinSRGBProfile: ICC v2 sRGB profile (D50 adapted primaries, sRGB tone curve),
+// embedded in the derivatives when no srgb_profile is configured
+//
+//go:embed srgb.icc
+var builtinSRGBProfile []byte
+
+func (d *DecoderConfig) TransformBeforeValidation() error {
+	d.PreviewEnabled = d.Preview == nil || *d.Preview
+	if d.External != nil {
+		ret := map[string]struct{}{}
+		for _, e := range d.External.Extensions {
+			ret[strings.TrimPrefix(strings.ToLower(e), ".")] = struct{}{}
+		}
+		d.External.NormalizedExtensions = ret
+		if d.External.Timeout == 0 {
+			d.External.Timeout = time.Minute
+		}
+	}
+	if d.Color.Convert != nil && d.Color.Convert.Timeout == 0 {
+		d.Color.Convert.Timeout = time.Minute
+	}
+	if d.Color.SRGBProfile != "" {
+		// a missing file is reported by the validation
+		d.Color.SRGBProfileData, _ = os.ReadFile(d.Color.SRGBProfile)
+	} else {
+		// the derivatives are sRGB, with or without color management
+		d.Color.SRGBProfileData = builtinSRGBProfile
+	}
+	return nil
+}
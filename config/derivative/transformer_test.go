@@ -0,0 +1,128 @@
+package derivative
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+func TestExpandLadders(t *testing.T) {
+	type rung struct {
+		name, postfix, ladder string
+		width, height         int
+	}
+	tests := []struct {
+		name  string
+		sizes []DerivativeConfig
+		want  []rung
+	}{
+		{
+			name:  "no widths",
+			sizes: []DerivativeConfig{{Name: "thumb", Postfix: "t", MaxWidth: 300, MaxHeight: 300}},
+			want:  []rung{{"thumb", "t", "", 300, 300}},
+		},
+		{
+			name:  "sorted after the size",
+			sizes: []DerivativeConfig{{Name: "large", Postfix: "l", MaxWidth: 2000, MaxHeight: 1500, Widths: []int{1000, 500}}},
+			want: []rung{
+				{"large", "l", "", 2000, 1500},
+				{"large-500", "l-500", "l", 500, 375},
+				{"large-1000", "l-1000", "l", 1000, 750},
+			},
+		},
+		{
+			name:  "widths not below the size skipped",
+			sizes: []DerivativeConfig{{Name: "large", Postfix: "l", MaxWidth: 1000, Widths: []int{0, -5, 1000, 1200, 400}}},
+			want: []rung{
+				{"large", "l", "", 1000, 0},
+				{"large-400", "l-400", "l", 400, 0},
+			},
+		},
+		{
+			name:  "no max width",
+			sizes: []DerivativeConfig{{Name: "full", Postfix: "f", Widths: []int{500}}},
+			want:  []rung{{"full", "f", "", 0, 0}},
+		},
+		{
+			name: "every size keeps its place",
+			sizes: []DerivativeConfig{
+				{Name: "a", Postfix: "a", MaxWidth: 800, MaxHeight: 800, Widths: []int{400}},
+				{Name: "b", Postfix: "b", MaxWidth: 300},
+			},
+			want: []rung{
+				{"a", "a", "", 800, 800},
+				{"a-400", "a-400", "a", 400, 400},
+				{"b", "b", "", 300, 0},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := expandLadders(tc.sizes)
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %d sizes, got %d", len(tc.want), len(got))
+			}
+			for i, w := range tc.want {
+				g := got[i]
+				if g.Name != w.name || g.Postfix != w.postfix || g.Ladder != w.ladder || g.MaxWidth != w.width || g.MaxHeight != w.height {
+					t.Fatalf("size %d: expected %+v, got %s %s %s %dx%d", i, w, g.Name, g.Postfix, g.Ladder, g.MaxWidth, g.MaxHeight)
+				}
+				if w.ladder != "" && g.Widths != nil {
+					t.Fatalf("size %d: rung has widths %v", i, g.Widths)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandLaddersKeepsInput(t *testing.T) {
+	sizes := []DerivativeConfig{{Name: "large", Postfix: "l", MaxWidth: 2000, Widths: []int{1000, 500}, Formats: []string{FormatWebP}}}
+	got := expandLadders(sizes)
+	if !slices.Equal(sizes[0].Widths, []int{1000, 500}) {
+		t.Fatalf("input widths changed: %v", sizes[0].Widths)
+	}
+	for _, d := range got {
+		if !slices.Equal(d.Formats, []string{FormatWebP}) {
+			t.Fatalf("%s: formats %v", d.Name, d.Formats)
+		}
+	}
+}
+
+func TestBuiltinSRGBProfile(t *testing.T) {
+	p := builtinSRGBProfile
+	if len(p) < 132 {
+		t.Fatalf("profile too short: %d bytes", len(p))
+	}
+	if size := binary.BigEndian.Uint32(p); int(size) != len(p) {
+		t.Fatalf("header size %d, file %d bytes", size, len(p))
+	}
+	if string(p[36:40]) != "acsp" || string(p[12:16]) != "mntr" || string(p[16:20]) != "RGB " || string(p[20:24]) != "XYZ " {
+		t.Fatalf("bad header")
+	}
+	want := map[string]bool{"desc": false, "cprt": false, "wtpt": false, "rXYZ": false, "gXYZ": false, "bXYZ": false, "rTRC": false, "gTRC": false, "bTRC": false}
+	count := int(binary.BigEndian.Uint32(p[128:]))
+	for i := 0; i < count; i++ {
+		entry := p[132+12*i:]
+		sig := string(entry[:4])
+		offset := binary.BigEndian.Uint32(entry[4:])
+		size := binary.BigEndian.Uint32(entry[8:])
+		if int(offset+size) > len(p) {
+			t.Fatalf("tag %s overruns the profile", sig)
+		}
+		want[sig] = true
+	}
+	for sig, found := range want {
+		if !found {
+			t.Fatalf("missing tag %s", sig)
+		}
+	}
+
+	var d DecoderConfig
+	if err := d.TransformBeforeValidation(); err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if !bytes.Equal(d.Color.SRGBProfileData, builtinSRGBProfile) || d.Color.Enabled() {
+		t.Fatalf("the built-in profile must be the default without enabling color management")
+	}
+}
@@ -3,12 +3,41 @@ package derivative
 import (
 	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 
 	"github.com/ignisVeneficus/lumenta/config/validate"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
 )
 
 func (derivatives DerivativesConfig) Validate(v *validate.ValidationErrors, path string) {
-	if len(derivatives) == 0 {
+	if derivatives.Workers < 0 {
+		err := errors.New("must be positive")
+		validate.LogConfigError(path+"/workers", derivatives.Workers, err)
+		v.Add(err)
+	}
+	if derivatives.QueueSize < 0 {
+		err := errors.New("must be positive")
+		validate.LogConfigError(path+"/queue_size", derivatives.QueueSize, err)
+		v.Add(err)
+	}
+	if derivatives.WaitTimeout < 0 {
+		err := errors.New("must be positive")
+		validate.LogConfigError(path+"/wait_timeout", derivatives.WaitTimeout, err)
+		v.Add(err)
+	}
+	for format, e := range derivatives.Encoders {
+		if format != FormatWebP && format != FormatAVIF {
+			err := errors.New("unknown format")
+			validate.LogConfigError(path+"/encoders", format, err)
+			v.Add(err)
+			continue
+		}
+		e.validate(v, path+"/encoders/"+format)
+	}
+	path += "/sizes"
+	if len(derivatives.Sizes) == 0 {
 		err := errors.New("at least one derivative must be defined")
 		validate.LogConfigError(path, nil, err)
 		v.Add(err)
@@ -16,10 +45,28 @@ func (derivatives DerivativesConfig) Validate(v *validate.ValidationErrors, path
 	}
 
 	seen := map[string]struct{}{}
+	// the routes match the postfix: the sizes are expanded already, the rungs
+	// of the width ladders are checked against every other size too
+	postfixes := map[string]struct{}{}
 
-	for i, d := range derivatives {
+	for i, d := range derivatives.Sizes {
 		base := fmt.Sprintf("%s[%d]", path, i)
 		name := d.validate(v, base)
+		for _, f := range d.Formats {
+			switch f {
+			case FormatJPEG:
+			case FormatWebP, FormatAVIF:
+				if _, ok := derivatives.Encoders[f]; !ok {
+					err := errors.New("no encoder for format")
+					validate.LogConfigError(base+"/formats", f, err)
+					v.Add(err)
+				}
+			default:
+				err := errors.New("unknown format")
+				validate.LogConfigError(base+"/formats", f, err)
+				v.Add(err)
+			}
+		}
 		if name != "" {
 			if _, ok := seen[name]; ok {
 				err := errors.New("duplicate name")
@@ -28,11 +75,37 @@ func (derivatives DerivativesConfig) Validate(v *validate.ValidationErrors, path
 			}
 			seen[name] = struct{}{}
 		}
+		if _, ok := postfixes[d.Postfix]; ok {
+			err := errors.New("duplicate postfix")
+			if d.Ladder != "" {
+				err = fmt.Errorf("duplicate postfix, width ladder of %s", d.Ladder)
+			}
+			validate.LogConfigError(base+"/postfix", d.Postfix, err)
+			v.Add(fmt.Errorf("%s/postfix %w", base, err))
+		}
+		postfixes[d.Postfix] = struct{}{}
 	}
 }
 func (d DerivativeConfig) validate(v *validate.ValidationErrors, path string) string {
 	validate.RequireString(v, path+"/name", d.Name)
 
+	d.PolicyConfig.validate(v, path)
+	for role, p := range d.Roles {
+		if !dbo.IsValidRole(role) {
+			err := errors.New("invalid role")
+			validate.LogConfigError(path+"/roles", role, err)
+			v.Add(err)
+			continue
+		}
+		p.validate(v, path+"/roles/"+string(role))
+	}
+
+	if len(d.Widths) > 0 && d.MaxWidth <= 0 {
+		err := errors.New("widths need max_width")
+		validate.LogConfigError(path+"/widths", d.Widths, err)
+		v.Add(err)
+	}
+
 	switch {
 	case (d.MaxWidth <= 0 || d.MaxHeight <= 0) && d.Mode == DerivativeSizeCrop:
 		err := errors.New("invalid dimensions")
@@ -56,3 +129,97 @@ func (d DerivativeConfig) validate(v *validate.ValidationErrors, path string) st
 	}
 	return d.Name
 }
+
+func (p PolicyConfig) validate(v *validate.ValidationErrors, path string) {
+	w := p.Watermark
+	if w == nil {
+		return
+	}
+	path += "/watermark"
+	switch {
+	case w.Text == "" && w.Image == "":
+		err := errors.New("text or image required")
+		validate.LogConfigError(path, nil, err)
+		v.Add(err)
+	case w.Image != "":
+		if _, err := os.Stat(w.Image); err != nil {
+			validate.LogConfigError(path+"/image", w.Image, err)
+			v.Add(err)
+		}
+	}
+	switch w.Position {
+	case WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight, WatermarkCenter:
+	default:
+		err := errors.New("invalid position")
+		validate.LogConfigError(path+"/position", w.Position, err)
+		v.Add(err)
+	}
+	if w.Opacity <= 0 || w.Opacity > 1 {
+		err := errors.New("must be in (0, 1]")
+		validate.LogConfigError(path+"/opacity", w.Opacity, err)
+		v.Add(err)
+	}
+	if w.Scale <= 0 || w.Scale > 1 {
+		err := errors.New("must be in (0, 1]")
+		validate.LogConfigError(path+"/scale", w.Scale, err)
+		v.Add(err)
+	}
+}
+
+func (e ExternalEncoderConfig) validate(v *validate.ValidationErrors, path string) {
+	if validate.RequireString(v, path+"/command", e.Command) {
+		if _, err := exec.LookPath(e.Command); err != nil {
+			validate.LogConfigError(path+"/command", e.Command, err)
+			v.Add(err)
+		}
+	}
+	for _, param := range []string{"{input}", "{output}"} {
+		found := false
+		for _, a := range e.Args {
+			if strings.Contains(a, param) {
+				found = true
+			}
+		}
+		if !found {
+			err := fmt.Errorf("missing %s argument", param)
+			validate.LogConfigError(path+"/args", e.Args, err)
+			v.Add(err)
+		}
+	}
+	validate.CheckDuration(v, path+"/timeout", e.Timeout)
+}
+
+func (d DecoderConfig) Validate(v *validate.ValidationErrors, path string) {
+	if d.External != nil {
+		d.External.validate(v, path+"/external")
+	}
+	if d.Color.Convert != nil {
+		d.Color.Convert.validate(v, path+"/color/convert")
+	}
+	if d.Color.SRGBProfile != "" && len(d.Color.SRGBProfileData) == 0 {
+		err := errors.New("missing or empty icc file")
+		validate.LogConfigError(path+"/color/srgb_profile", d.Color.SRGBProfile, err)
+		v.Add(err)
+	}
+}
+
+func (e *ExternalDecoderConfig) validate(v *validate.ValidationErrors, path string) {
+	if validate.RequireString(v, path+"/command", e.Command) {
+		if _, err := exec.LookPath(e.Command); err != nil {
+			validate.LogConfigError(path+"/command", e.Command, err)
+			v.Add(err)
+		}
+	}
+	hasInput := false
+	for _, a := range e.Args {
+		if strings.Contains(a, "{input}") {
+			hasInput = true
+		}
+	}
+	if !hasInput {
+		err := errors.New("missing {input} argument")
+		validate.LogConfigError(path+"/args", e.Args, err)
+		v.Add(err)
+	}
+	validate.CheckDuration(v, path+"/timeout", e.Timeout)
+}
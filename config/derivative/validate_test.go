@@ -0,0 +1,62 @@
+package derivative
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ignisVeneficus/lumenta/config/validate"
+)
+
+func TestValidatePostfixUnique(t *testing.T) {
+	tests := []struct {
+		name    string
+		sizes   []DerivativeConfig
+		wantErr bool
+	}{
+		{
+			name: "distinct",
+			sizes: []DerivativeConfig{
+				{Name: "small", Postfix: "s400", MaxWidth: 400, Widths: []int{320}},
+				{Name: "large", Postfix: "l", MaxWidth: 2000},
+			},
+		},
+		{
+			name: "same postfix",
+			sizes: []DerivativeConfig{
+				{Name: "small", Postfix: "s", MaxWidth: 400},
+				{Name: "other", Postfix: "s", MaxWidth: 800},
+			},
+			wantErr: true,
+		},
+		{
+			name: "rung against a size",
+			sizes: []DerivativeConfig{
+				{Name: "small", Postfix: "s400", MaxWidth: 400, Widths: []int{320}},
+				{Name: "tiny", Postfix: "s400-320", MaxWidth: 320},
+			},
+			wantErr: true,
+		},
+		{
+			name: "rungs of two ladders",
+			sizes: []DerivativeConfig{
+				{Name: "a", Postfix: "x", MaxWidth: 800, Widths: []int{400}},
+				{Name: "b", Postfix: "x-400", MaxWidth: 1600},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := DerivativesConfig{Sizes: tc.sizes}
+			if err := d.TransformBeforeValidation(); err != nil {
+				t.Fatalf("transform failed: %v", err)
+			}
+			var v validate.ValidationErrors
+			d.Validate(&v, "derivatives")
+			got := v.HasErrors() && strings.Contains(v.Error(), "duplicate postfix")
+			if got != tc.wantErr {
+				t.Fatalf("expected duplicate postfix error %v, got %v", tc.wantErr, v.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package derivative
+
+import "gopkg.in/yaml.v3"
+
+// UnmarshalYAML accepts the derivative list alone (sizes only) too.
+func (d *DerivativesConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		return value.Decode(&d.Sizes)
+	}
+	type plain DerivativesConfig
+	return value.Decode((*plain)(d))
+}
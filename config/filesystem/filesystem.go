@@ -1,5 +1,10 @@
 package filesystem
 
+import (
+	"path/filepath"
+	"strings"
+)
+
 // Originals: read-only filesystem
 // Derivatives: writable cache
 type FilesystemConfig struct {
@@ -12,3 +17,43 @@ type RootConfig struct {
 	ExcludedPath []string `yaml:"excluded_path"`
 	ExcludedDirs []string `yaml:"excluded_dir_names"`
 }
+
+// OverlappingOriginal returns the name of the originals root the derivative
+// tree equals, contains or sits inside; empty when they are separate.
+// The derivative tree is garbage collected, an overlap would delete originals.
+func (m FilesystemConfig) OverlappingOriginal() string {
+	if m.Derivatives == "" {
+		return ""
+	}
+	derivatives := canonicalPath(m.Derivatives)
+	for name, conf := range m.Originals {
+		if conf.Root == "" {
+			continue
+		}
+		root := canonicalPath(conf.Root)
+		if isWithin(derivatives, root) || isWithin(root, derivatives) {
+			return name
+		}
+	}
+	return ""
+}
+
+// canonicalPath: absolute, cleaned and, when it exists, symlink resolved path
+func canonicalPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	return filepath.Clean(p)
+}
+
+// isWithin: child is parent or a path below it
+func isWithin(child, parent string) bool {
+	rel, err := filepath.Rel(parent, child)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
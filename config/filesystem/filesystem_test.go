@@ -0,0 +1,81 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ignisVeneficus/lumenta/config/validate"
+)
+
+func TestOverlappingOriginal(t *testing.T) {
+	base := t.TempDir()
+	photos := filepath.Join(base, "photos")
+	inside := filepath.Join(photos, "cache")
+	separate := filepath.Join(base, "cache")
+	for _, dir := range []string{photos, inside, separate} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("setup failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		derivatives string
+		want        string
+	}{
+		{"equal", photos, "main"},
+		{"equal unclean", photos + "/./", "main"},
+		{"contains originals", base, "main"},
+		{"inside originals", inside, "main"},
+		{"separate", separate, ""},
+		{"sibling with common prefix", photos + "-cache", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := FilesystemConfig{
+				Originals:   RootConfigs{"main": {Root: photos}},
+				Derivatives: tt.derivatives,
+			}
+			if got := cfg.OverlappingOriginal(); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsOverlap(t *testing.T) {
+	base := t.TempDir()
+	photos := filepath.Join(base, "photos")
+	inside := filepath.Join(photos, "cache")
+	separate := filepath.Join(base, "cache")
+	for _, dir := range []string{photos, inside, separate} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("setup failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		derivatives string
+		wantErr     bool
+	}{
+		{"equal", photos, true},
+		{"contains originals", base, true},
+		{"inside originals", inside, true},
+		{"separate", separate, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := FilesystemConfig{
+				Originals:   RootConfigs{"main": {Root: photos}},
+				Derivatives: tt.derivatives,
+			}
+			var v validate.ValidationErrors
+			cfg.Validate(&v, "filesystem")
+			if v.HasErrors() != tt.wantErr {
+				t.Fatalf("expected errors: %v, got %v", tt.wantErr, v.Error())
+			}
+		})
+	}
+}
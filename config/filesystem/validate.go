@@ -25,5 +25,9 @@ func (m FilesystemConfig) Validate(v *validate.ValidationErrors, confPath string
 			validate.LogConfigOK(fmt.Sprintf("%s/originals[%s]/excluded_dir[%d]", confPath, name, i), excluded)
 		}
 	}
-
+	if name := m.OverlappingOriginal(); name != "" {
+		err := fmt.Errorf("must be separate from originals[%s]: the derivative tree is garbage collected", name)
+		validate.LogConfigError(confPath+"/derivatives", m.Derivatives, err)
+		v.Add(err)
+	}
 }
@@ -12,16 +12,28 @@ func DefaultDBMetadataConfig() MetadataConfig {
 
 			data.MetaTakenAt: {
 				Sources: []MetadataSourceConfig{
+					{Ref: "composite:SubSecDateTimeOriginal"},
 					{Ref: "composite:DateTimeCreated"},
 					{Ref: "xmp-exif:DateTimeOriginal"},
 					{Ref: "exififd:DateTimeOriginal"},
 					{Ref: "exififd:createdate"},
 					{Ref: "exif:DateTimeOriginal"},
 					{Ref: "exif:CreateDate"},
+					{Ref: "keys:CreationDate"},
+					{Ref: "quicktime:CreateDate"},
 				},
 				Type: data.MetaDateTime,
 			},
 
+			data.MetaOffsetTime: {
+				Sources: []MetadataSourceConfig{
+					{Ref: "exififd:OffsetTimeOriginal"},
+					{Ref: "exif:OffsetTimeOriginal"},
+					{Ref: "exififd:OffsetTime"},
+				},
+				Type: data.MetaString,
+			},
+
 			data.MetaCamera: {
 				Sources: []MetadataSourceConfig{
 					{Ref: "ifd0:model"},
@@ -164,6 +176,8 @@ func DefaultDBMetadataConfig() MetadataConfig {
 					{Ref: "exif:PixelYDimension"},
 					{Ref: "IFD0:ImageHeight"},
 					{Ref: "ExifIFD:ExifImageHeight"},
+					{Ref: "track1:ImageHeight"},
+					{Ref: "quicktime:ImageHeight"},
 				},
 				Type: data.MetaInt,
 			},
@@ -174,6 +188,39 @@ func DefaultDBMetadataConfig() MetadataConfig {
 					{Ref: "exif:PixelXDimension"},
 					{Ref: "IFD0:ImageWidth"},
 					{Ref: "ExifIFD:ExifImageWidth"},
+					{Ref: "track1:ImageWidth"},
+					{Ref: "quicktime:ImageWidth"},
+				},
+				Type: data.MetaInt,
+			},
+			// =========================
+			// VIDEO
+			// =========================
+
+			data.MetaDuration: {
+				Sources: []MetadataSourceConfig{
+					{Ref: "quicktime:Duration"},
+					{Ref: "track1:TrackDuration"},
+				},
+				Type: data.MetaString,
+			},
+			data.MetaVideoCodec: {
+				Sources: []MetadataSourceConfig{
+					{Ref: "track1:CompressorID"},
+					{Ref: "track1:CompressorName"},
+				},
+				Type: data.MetaString,
+			},
+			data.MetaAudioCodec: {
+				Sources: []MetadataSourceConfig{
+					{Ref: "track2:AudioFormat"},
+				},
+				Type: data.MetaString,
+			},
+			data.MetaVideoRotation: {
+				Sources: []MetadataSourceConfig{
+					{Ref: "composite:Rotation"},
+					{Ref: "track1:Rotation"},
 				},
 				Type: data.MetaInt,
 			},
@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"strings"
 	"time"
 
 	"github.com/ignisVeneficus/lumenta/data"
@@ -12,29 +13,39 @@ type StepName string
 
 const (
 	//WorkerFS WorkerName ="filesystem"
-	StepMetadata StepName = "metadata"
-	StepHash     StepName = "hash"
-	StepACL      StepName = "acl"
-	StepAlbum    StepName = "album_rules"
-	StepImage    StepName = "image_writer"
-	StepFiltered StepName = "filtered_writer"
-	StepDB       StepName = "db_reader"
-	StepDirty    StepName = "dirty_check"
-	StepFilter   StepName = "insertion_filter"
-	StepResult   StepName = "result_writer"
+	StepMetadata    StepName = "metadata"
+	StepHash        StepName = "hash"
+	StepACL         StepName = "acl"
+	StepAlbum       StepName = "album_rules"
+	StepImage       StepName = "image_writer"
+	StepFiltered    StepName = "filtered_writer"
+	StepDB          StepName = "db_reader"
+	StepDirty       StepName = "dirty_check"
+	StepFilter      StepName = "insertion_filter"
+	StepResult      StepName = "result_writer"
+	StepGeocode     StepName = "geocode"
+	StepGPX         StepName = "gpx"
+	StepTimezone    StepName = "timezone"
+	StepOverride    StepName = "override"
+	StepDerivatives StepName = "derivatives"
 )
 
 var ValidStepName = map[StepName]struct{}{
-	StepMetadata: {},
-	StepHash:     {},
-	StepACL:      {},
-	StepAlbum:    {},
-	StepImage:    {},
-	StepFiltered: {},
-	StepDB:       {},
-	StepDirty:    {},
-	StepFilter:   {},
-	StepResult:   {},
+	StepMetadata:    {},
+	StepHash:        {},
+	StepACL:         {},
+	StepAlbum:       {},
+	StepImage:       {},
+	StepFiltered:    {},
+	StepDB:          {},
+	StepDirty:       {},
+	StepFilter:      {},
+	StepResult:      {},
+	StepGeocode:     {},
+	StepGPX:         {},
+	StepTimezone:    {},
+	StepOverride:    {},
+	StepDerivatives: {},
 }
 
 type SyncConfig struct {
@@ -43,8 +54,14 @@ type SyncConfig struct {
 	Metadata             MetadataConfig          `yaml:"metadata"`
 	Exiftool             ExiftoolConfig          `yaml:"exiftool"`
 	Panorama             *ruleengine.RuleGroup   `yaml:"panorama"`
+	Geocode              *GeocodeConfig          `yaml:"geocode"`
+	GPX                  *GPXConfig              `yaml:"gpx"`
+	Timezone             *TimezoneConfig         `yaml:"timezone"`
+	Video                *VideoConfig            `yaml:"video"`
+	Overrides            []OverrideConfig        `yaml:"overrides"`
 	ACLRules             ACLRules                `yaml:"ACL_rules"`
 	ACLOverride          bool                    `yaml:"override_ACL_rules"`
+	RefreshInterval      time.Duration           `yaml:"refresh_interval"` // serve mode: periodic re-evaluation of the time dependent and random_daily albums, 0: off
 	Pipeline             map[StepName]StepConfig `yaml:"pipeline"`
 	NormalizedExtensions map[string]struct{}     `yaml:"-"`
 	MergedMetadata       MetadataConfig          `yaml:"-"`
@@ -77,6 +94,99 @@ type ExiftoolConfig struct {
 	ResolvedPath string        `yaml:"-"`
 }
 
+// GeocodeConfig enables the offline reverse geocoding step.
+// Files are GeoNames dumps (https://download.geonames.org/export/dump/).
+type GeocodeConfig struct {
+	Cities      string  `yaml:"cities"`       // cities1000.txt / cities5000.txt / ...
+	Admin1      string  `yaml:"admin1"`       // admin1CodesASCII.txt, optional
+	Countries   string  `yaml:"countries"`    // countryInfo.txt, optional
+	MaxDistance float64 `yaml:"max_distance"` // km, default 25
+	TagRoot     string  `yaml:"tag_root"`     // empty: no location tags
+}
+
+// GPXConfig enables the position lookup from GPX tracks for images without GPS.
+type GPXConfig struct {
+	Path   string        `yaml:"path"`    // directory of .gpx files (recursive)
+	Offset time.Duration `yaml:"offset"`  // added to taken_at before matching (camera clock / timezone)
+	MaxGap time.Duration `yaml:"max_gap"` // default 5m
+}
+
+// TimezoneConfig resolves the capture time of files without an offset in the metadata.
+// Order: metadata offset, longest matching path, GPS lookup (if enabled), default.
+type TimezoneConfig struct {
+	Default         string               `yaml:"default"`    // IANA name, e.g. "Europe/Budapest"
+	GPSLookup       bool                 `yaml:"gps_lookup"` // gazetteer of the geocode step, embedded offline table
+	Paths           []TimezonePathConfig `yaml:"paths"`
+	DefaultLocation *time.Location       `yaml:"-"`
+}
+
+type TimezonePathConfig struct {
+	Root     string         `yaml:"root"`
+	Path     string         `yaml:"path"` // path prefix, empty: whole root
+	Zone     string         `yaml:"zone"` // IANA name
+	Location *time.Location `yaml:"-"`
+}
+
+// VideoConfig enables the indexing of video files.
+// Poster frames (derivatives) are extracted with ffmpeg.
+type VideoConfig struct {
+	Extensions           []string            `yaml:"extensions"` // ["mp4","mov","m4v"]
+	FFmpeg               string              `yaml:"ffmpeg"`     // pl: "/usr/bin/ffmpeg", default: $FFMPEG_PATH / PATH
+	PosterAt             time.Duration       `yaml:"poster_at"`  // position of the poster frame, default 1s
+	Timeout              time.Duration       `yaml:"timeout"`    // ffmpeg run, default 30s
+	NormalizedExtensions map[string]struct{} `yaml:"-"`
+	ResolvedFFmpeg       string              `yaml:"-"`
+}
+
+// IsVideo reports whether the normalized extension is a configured video extension.
+func (v *VideoConfig) IsVideo(ext string) bool {
+	if v == nil {
+		return false
+	}
+	_, ok := v.NormalizedExtensions[ext]
+	return ok
+}
+
+// PathLocation returns the configured timezone of a file, the longest matching path wins.
+// nil if no path matches, the default is not included.
+func (t *TimezoneConfig) PathLocation(root, path string) *time.Location {
+	var ret *time.Location
+	matchLen := -1
+	for _, p := range t.Paths {
+		if p.Location == nil || p.Root != root || !strings.HasPrefix(path, p.Path) {
+			continue
+		}
+		if len(p.Path) > matchLen {
+			ret = p.Location
+			matchLen = len(p.Path)
+		}
+	}
+	return ret
+}
+
+// OverrideConfig changes the metadata of the matching files during the sync,
+// the originals are not touched. Overrides run after the timezone, GPX and
+// geocode steps in config order, before the filter, ACL and album steps.
+// The derived fields of an overridden time or position are computed again.
+type OverrideConfig struct {
+	Name    string               `yaml:"name"` // shown in the sync trace, default: overrides[index]
+	Rules   ruleengine.RuleGroup `yaml:"rules"`
+	Actions []OverrideAction     `yaml:"actions"`
+}
+
+// OverrideAction is one change of an override, exactly one field is set.
+type OverrideAction struct {
+	AddTag        string        `yaml:"add_tag"`         // tag path, e.g. "Scans/Undated"
+	ShiftTakenAt  time.Duration `yaml:"shift_taken_at"`  // added to taken_at, can be negative
+	Set           *OverrideSet  `yaml:"set"`             // replaces a metadata field
+	TitleFromPath *int          `yaml:"title_from_path"` // folder index in the path, negative from the end (-1: folder of the file)
+}
+
+type OverrideSet struct {
+	Field string `yaml:"field"` // metadata alias, the field needs a type
+	Value any    `yaml:"value"` // converted to the type of the field
+}
+
 type ACLRules []ACLRule
 
 type ACLRule struct {
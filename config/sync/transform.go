@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/ignisVeneficus/lumenta/utils"
 )
@@ -35,6 +36,22 @@ func (sc *SyncConfig) TransformBeforeValidation() error {
 	sc.NormalizedExtensions = ret
 
 	_ = sc.Exiftool.TransformBeforeValidation()
+	if sc.Geocode != nil {
+		_ = sc.Geocode.TransformBeforeValidation()
+	}
+	if sc.GPX != nil {
+		_ = sc.GPX.TransformBeforeValidation()
+	}
+	if sc.Timezone != nil {
+		_ = sc.Timezone.TransformBeforeValidation()
+	}
+	if sc.Video != nil {
+		_ = sc.Video.TransformBeforeValidation()
+		// videos go through the same walk
+		for ext := range sc.Video.NormalizedExtensions {
+			sc.NormalizedExtensions[ext] = struct{}{}
+		}
+	}
 
 	return nil
 }
@@ -42,7 +59,18 @@ func (sc *SyncConfig) TransformAfterValidation() error {
 	// merge medata config with the hardoded metadata configs
 	sc.MergedMetadata = MergeMetadataConfig(DefaultDBMetadataConfig(), sc.Metadata)
 
-	metadataHash, err := utils.ComputeYAMLHash(sc.Metadata)
+	// geocode / gpx / timezone / override output is stored with the metadata, so changing them must re-read the files
+	var hashSource any = sc.Metadata
+	if sc.Geocode != nil || sc.GPX != nil || sc.Timezone != nil || len(sc.Overrides) > 0 {
+		hashSource = struct {
+			Metadata  MetadataConfig   `yaml:"metadata"`
+			Geocode   *GeocodeConfig   `yaml:"geocode,omitempty"`
+			GPX       *GPXConfig       `yaml:"gpx,omitempty"`
+			Timezone  *TimezoneConfig  `yaml:"timezone,omitempty"`
+			Overrides []OverrideConfig `yaml:"overrides,omitempty"`
+		}{sc.Metadata, sc.Geocode, sc.GPX, sc.Timezone, sc.Overrides}
+	}
+	metadataHash, err := utils.ComputeYAMLHash(hashSource)
 	if err == nil {
 		sc.MetadataHash = metadataHash
 	}
@@ -69,3 +97,65 @@ func (etC *ExiftoolConfig) TransformBeforeValidation() error {
 	etC.ResolvedPath = ResolveExiftoolPath(etC.Path)
 	return nil
 }
+
+func (g *GeocodeConfig) TransformBeforeValidation() error {
+	if g.MaxDistance == 0 {
+		g.MaxDistance = 25
+	}
+	g.TagRoot = strings.Trim(g.TagRoot, "/")
+	return nil
+}
+
+func ResolveFFmpegPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if env := os.Getenv("FFMPEG_PATH"); env != "" {
+		return env
+	}
+
+	if p, err := exec.LookPath("ffmpeg"); err == nil {
+		return p
+	}
+
+	return ""
+}
+
+func (vc *VideoConfig) TransformBeforeValidation() error {
+	ret := map[string]struct{}{}
+	for _, e := range vc.Extensions {
+		key := strings.TrimPrefix(strings.ToLower(e), ".")
+		ret[key] = struct{}{}
+	}
+	vc.NormalizedExtensions = ret
+	vc.ResolvedFFmpeg = ResolveFFmpegPath(vc.FFmpeg)
+	if vc.PosterAt == 0 {
+		vc.PosterAt = time.Second
+	}
+	if vc.Timeout == 0 {
+		vc.Timeout = 30 * time.Second
+	}
+	return nil
+}
+
+func (g *GPXConfig) TransformBeforeValidation() error {
+	if g.MaxGap == 0 {
+		g.MaxGap = 5 * time.Minute
+	}
+	return nil
+}
+
+func (t *TimezoneConfig) TransformBeforeValidation() error {
+	if t.Default != "" {
+		if loc, err := time.LoadLocation(t.Default); err == nil {
+			t.DefaultLocation = loc
+		}
+	}
+	for i := range t.Paths {
+		t.Paths[i].Path = strings.Trim(t.Paths[i].Path, "/")
+		if loc, err := time.LoadLocation(t.Paths[i].Zone); err == nil && t.Paths[i].Zone != "" {
+			t.Paths[i].Location = loc
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package sync
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ignisVeneficus/lumenta/config/validate"
 	"github.com/ignisVeneficus/lumenta/data"
@@ -32,6 +33,33 @@ func (s *SyncConfig) Validate(v *validate.ValidationErrors, path string) {
 	if s.Panorama != nil {
 		validateFilterGroup(s.Panorama, v, path+"/panorama")
 	}
+	if s.Geocode != nil {
+		s.Geocode.validate(v, path+"/geocode")
+	}
+	if s.GPX != nil {
+		s.GPX.validate(v, path+"/gpx")
+	}
+	if s.Timezone != nil {
+		s.Timezone.validate(v, path+"/timezone")
+	}
+	if s.Video != nil {
+		s.Video.validate(v, path+"/video")
+	}
+	if len(s.Overrides) > 0 {
+		fields := MergeMetadataConfig(DefaultDBMetadataConfig(), s.Metadata).Fields
+		for i := range s.Overrides {
+			s.Overrides[i].validate(v, path, i, fields)
+		}
+	}
+	if s.RefreshInterval != 0 {
+		if s.RefreshInterval < time.Minute {
+			err := errors.New("must be at least 1m")
+			validate.LogConfigError(path+"/refresh_interval", s.RefreshInterval, err)
+			v.Add(fmt.Errorf("%s/refresh_interval %w", path, err))
+		} else {
+			validate.LogConfigOK(path+"/refresh_interval", s.RefreshInterval)
+		}
+	}
 	for k, pl := range s.Pipeline {
 		if _, ok := ValidStepName[k]; !ok {
 			err := validate.ErrRequired("invalid pipeline step")
@@ -52,7 +80,7 @@ func (p *PathFilterConfig) validate(v *validate.ValidationErrors, basePath strin
 
 func validateFilterGroup(fg *ruleengine.RuleGroup, v *validate.ValidationErrors, path string) {
 	switch fg.Op {
-	case ruleengine.OpAll, ruleengine.OpAny:
+	case ruleengine.OpAll, ruleengine.OpAny, ruleengine.OpNot:
 		validate.LogConfigOK(path+"/op", fg.Op)
 	default:
 		err := errors.New("invalid filter group op")
@@ -76,7 +104,62 @@ func validateFilterGroup(fg *ruleengine.RuleGroup, v *validate.ValidationErrors,
 		}
 
 		validate.RequireString(v, fmt.Sprintf("%s/filters[%d]/type", path, i), f.FilterType())
+		if nested, ok := f.(*ruleengine.GroupFilter); ok {
+			group := nested.Group()
+			validateFilterGroup(&group, v, fmt.Sprintf("%s/filters[%d]", path, i))
+		}
+	}
+}
+
+func (o *OverrideConfig) validate(v *validate.ValidationErrors, basePath string, idx int, fields map[string]MetadataFieldConfig) {
+	path := fmt.Sprintf("%s/overrides[%d]", basePath, idx)
+	validateFilterGroup(&o.Rules, v, path+"/rules")
+	if len(o.Actions) == 0 {
+		err := validate.ErrRequired(path + "/actions")
+		validate.LogConfigError(path+"/actions", nil, err)
+		v.Add(err)
+	}
+	for i, a := range o.Actions {
+		a.validate(v, fmt.Sprintf("%s/actions[%d]", path, i), fields)
+	}
+}
+
+func (a *OverrideAction) validate(v *validate.ValidationErrors, path string, fields map[string]MetadataFieldConfig) {
+	set := 0
+	if a.AddTag != "" {
+		set++
+	}
+	if a.ShiftTakenAt != 0 {
+		set++
+	}
+	if a.Set != nil {
+		set++
+		if field, ok := fields[a.Set.Field]; !ok {
+			err := errors.New("unknown metadata field")
+			validate.LogConfigError(path+"/set/field", a.Set.Field, err)
+			v.Add(fmt.Errorf("%s/set/field %w", path, err))
+		} else if field.Type == "" {
+			// the value is converted to the type of the field
+			err := errors.New("metadata field has no type")
+			validate.LogConfigError(path+"/set/field", a.Set.Field, err)
+			v.Add(fmt.Errorf("%s/set/field %w", path, err))
+		}
+		if a.Set.Value == nil {
+			err := validate.ErrRequired(path + "/set/value")
+			validate.LogConfigError(path+"/set/value", nil, err)
+			v.Add(err)
+		}
+	}
+	if a.TitleFromPath != nil {
+		set++
 	}
+	if set != 1 {
+		err := errors.New("exactly one of add_tag, shift_taken_at, set, title_from_path needed")
+		validate.LogConfigError(path, set, err)
+		v.Add(fmt.Errorf("%s %w", path, err))
+		return
+	}
+	validate.LogConfigOK(path, *a)
 }
 
 func (m *MetadataConfig) validate(v *validate.ValidationErrors, path string) {
@@ -132,6 +215,53 @@ func (c *ExiftoolConfig) validate(v *validate.ValidationErrors, path string) {
 	}
 }
 
+func (g *GeocodeConfig) validate(v *validate.ValidationErrors, path string) {
+	validate.CheckFile(path+"/cities", g.Cities, true, v)
+	validate.CheckFile(path+"/admin1", g.Admin1, false, v)
+	validate.CheckFile(path+"/countries", g.Countries, false, v)
+	if g.MaxDistance <= 0 {
+		err := errors.New("must be > 0")
+		validate.LogConfigError(path+"/max_distance", g.MaxDistance, err)
+		v.Add(err)
+	}
+}
+
+func (g *GPXConfig) validate(v *validate.ValidationErrors, path string) {
+	validate.CheckDir(path+"/path", g.Path, true, v)
+	validate.CheckDuration(v, path+"/max_gap", g.MaxGap)
+}
+
+func (t *TimezoneConfig) validate(v *validate.ValidationErrors, path string) {
+	if t.Default != "" && t.DefaultLocation == nil {
+		err := errors.New("unknown timezone")
+		validate.LogConfigError(path+"/default", t.Default, err)
+		v.Add(err)
+	}
+	for i, p := range t.Paths {
+		pPath := fmt.Sprintf("%s/paths[%d]", path, i)
+		validate.RequireString(v, pPath+"/root", p.Root)
+		if p.Location == nil {
+			err := errors.New("unknown timezone")
+			validate.LogConfigError(pPath+"/zone", p.Zone, err)
+			v.Add(err)
+		}
+	}
+}
+
+func (vc *VideoConfig) validate(v *validate.ValidationErrors, path string) {
+	if len(vc.Extensions) == 0 {
+		err := validate.ErrRequired(path + "/extensions")
+		validate.LogConfigError(path+"/extensions", nil, err)
+		v.Add(err)
+	}
+	if vc.ResolvedFFmpeg == "" {
+		err := errors.New("invalid ffmpeg path")
+		validate.LogConfigError(path+"/ffmpeg", vc.FFmpeg, err)
+		v.Add(err)
+	}
+	validate.CheckDuration(v, path+"/timeout", vc.Timeout)
+}
+
 func (ac *ACLRules) validate(v *validate.ValidationErrors, path string) {
 	for i, r := range *ac {
 		r.validate(v, path, i)
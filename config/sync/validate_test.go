@@ -0,0 +1,34 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/ignisVeneficus/lumenta/config/validate"
+	"github.com/ignisVeneficus/lumenta/data"
+)
+
+func TestOverrideSetField(t *testing.T) {
+	fields := map[string]MetadataFieldConfig{
+		data.MetaTitle: {Type: data.MetaString},
+		"custom":       {Sources: []MetadataSourceConfig{{Ref: "XMP:Custom"}}},
+	}
+	tests := []struct {
+		name    string
+		field   string
+		wantErr bool
+	}{
+		{"typed", data.MetaTitle, false},
+		{"unknown", "no_such_field", true},
+		{"untyped", "custom", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validate.ValidationErrors
+			a := OverrideAction{Set: &OverrideSet{Field: tt.field, Value: "x"}}
+			a.validate(&v, "sync/overrides[0]/actions[0]", fields)
+			if v.HasErrors() != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, v.Error())
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 func (c *Config) TransformBeforeValidation() error {
 	_ = c.Sync.TransformBeforeValidation()
+	_ = c.Decoder.TransformBeforeValidation()
+	_ = c.Derivatives.TransformBeforeValidation()
 
 	return nil
 }
@@ -12,6 +12,7 @@ func (c *Config) Validate() error {
 	c.Auth.Validate(&verr, "auth")
 	c.Database.Validate(&verr, "database")
 	c.Derivatives.Validate(&verr, "derivatives")
+	c.Decoder.Validate(&verr, "decoder")
 	c.Sync.Validate(&verr, "sync")
 	c.Site.Validate(&verr, "site")
 	c.Presentation.Validate(&verr, "presentation")
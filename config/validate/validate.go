@@ -140,6 +140,35 @@ func CheckDir(pathKey string, dir string, required bool, v *ValidationErrors) {
 	LogConfigOK(pathKey, dir)
 }
 
+func CheckFile(pathKey string, file string, required bool, v *ValidationErrors) {
+	if file == "" {
+		if required {
+			err := errors.New("file must be set")
+			LogConfigError(pathKey, file, err)
+			v.Add(fmt.Errorf("%s: %w", pathKey, err))
+		} else {
+			log.Info().Str("config", pathKey).Msg("file not set (optional)")
+		}
+		return
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		LogConfigError(pathKey, file, err)
+		v.Add(fmt.Errorf("%s: %w", pathKey, err))
+		return
+	}
+
+	if info.IsDir() {
+		err := errors.New("not a file")
+		LogConfigError(pathKey, file, err)
+		v.Add(fmt.Errorf("%s: %w", pathKey, err))
+		return
+	}
+
+	LogConfigOK(pathKey, file)
+}
+
 func CheckDuration(v *ValidationErrors, path string, d time.Duration) {
 	if d <= 0 {
 		err := errors.New("must be > 0")
@@ -32,6 +32,28 @@ const (
 	MetaMaker        = "maker" // not in db
 	MetaExposureTime = "exposure_time"
 	MetaSize         = "size" //not in db
+
+	// reverse geocoding, not in db
+	MetaCountry   = "country"
+	MetaRegion    = "region"
+	MetaCity      = "city"
+	MetaPlaceTags = "place_tags"
+
+	// sync overrides, not in db
+	MetaOverrideTags = "override_tags"
+
+	// gpx track correlation, not in db
+	MetaGPSTrack = "gps_track"
+
+	// capture timezone
+	MetaOffsetTime = "offset_time" // not in db
+	MetaTakenAtTZ  = "taken_at_tz"
+
+	// video
+	MetaDuration      = "duration"
+	MetaVideoCodec    = "video_codec"
+	MetaAudioCodec    = "audio_codec"    // not in db
+	MetaVideoRotation = "video_rotation" // not in db, display matrix of the video track
 )
 
 var MetadataInDB = []string{
@@ -41,6 +63,7 @@ var MetadataInDB = []string{
 	MetaRating,
 
 	MetaTakenAt,
+	MetaTakenAtTZ,
 	MetaLatitude,
 	MetaLongitude,
 
@@ -54,10 +77,14 @@ var MetadataInDB = []string{
 	MetaWidth,
 	MetaHeight,
 	MetaRotation,
+
+	MetaDuration,
+	MetaVideoCodec,
 }
 
 var MetadataSetInDB = map[string]struct{}{
 	MetaTakenAt:      {},
+	MetaTakenAtTZ:    {},
 	MetaCamera:       {},
 	MetaLens:         {},
 	MetaFocalLength:  {},
@@ -73,6 +100,8 @@ var MetadataSetInDB = map[string]struct{}{
 	MetaHeight:       {},
 	MetaWidth:        {},
 	MetaExposureTime: {},
+	MetaDuration:     {},
+	MetaVideoCodec:   {},
 }
 
 type MetadataType string
@@ -108,6 +137,34 @@ func (mt *Metadata) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolo
 
 type MetadataSource string
 
+const (
+	MetadataSourceGeocode  MetadataSource = "geocode"
+	MetadataSourceGPX      MetadataSource = "gpx"
+	MetadataSourceTZ       MetadataSource = "timezone"
+	MetadataSourceOverride MetadataSource = "override"
+)
+
+// FloatingZone is the location of times read without offset.
+// Until the timezone step resolves them they are handled as UTC.
+var FloatingZone = time.FixedZone("floating", 0)
+
+// FloatingUTCZone is the location of times known as UTC instant but without
+// the local timezone (QuickTime dates are stored in UTC).
+var FloatingUTCZone = time.FixedZone("floating-utc", 0)
+
+func IsFloating(t time.Time) bool {
+	return t.Location() == FloatingZone || t.Location() == FloatingUTCZone
+}
+
+// InZone resolves a floating time in loc: the wall clock is kept for
+// FloatingZone, the instant is kept for FloatingUTCZone.
+func InZone(t time.Time, loc *time.Location) time.Time {
+	if t.Location() == FloatingUTCZone {
+		return t.In(loc)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
 type MetadataValue struct {
 	Alias  string         `json:"alias"` // user-defined (pl "focal_length")
 	Ref    string         `json:"ref"`   // EXIF:FocalLength
@@ -190,6 +247,9 @@ func (m Metadata) GetCaption() *string {
 func (m Metadata) GetTakenAt() *time.Time {
 	return m.getTime(MetaTakenAt)
 }
+func (m Metadata) GetTakenAtTZ() *string {
+	return m.getString(MetaTakenAtTZ)
+}
 func (m Metadata) GetMaker() *string {
 	return m.getString(MetaMaker)
 }
@@ -249,10 +309,23 @@ func (m Metadata) GetRotation() *int16 {
 func (m Metadata) GetRating() *uint16 {
 	return m.getUint16(MetaRating)
 }
+
+// isPortraitRotated reports whether width and height are swapped on display,
+// by the EXIF orientation or by the rotation of the video track.
+func (m Metadata) isPortraitRotated() bool {
+	if r := m.GetRotation(); r != nil && (*r == 90 || *r == 270) {
+		return true
+	}
+	if v, ok := m[MetaVideoRotation]; ok {
+		if r, ok := v.AsInt(); ok && (r == 90 || r == 270 || r == -90) {
+			return true
+		}
+	}
+	return false
+}
 func (m Metadata) GetWidth() uint32 {
-	r := m.GetRotation()
 	var v *uint32
-	if r == nil || *r == 0 || *r == 180 {
+	if !m.isPortraitRotated() {
 		v = m.getUint32(MetaWidth)
 	} else {
 		v = m.getUint32(MetaHeight)
@@ -263,9 +336,8 @@ func (m Metadata) GetWidth() uint32 {
 	return *v
 }
 func (m Metadata) GetHeight() uint32 {
-	r := m.GetRotation()
 	var v *uint32
-	if r == nil || *r == 0 || *r == 180 {
+	if !m.isPortraitRotated() {
 		v = m.getUint32(MetaHeight)
 	} else {
 		v = m.getUint32(MetaWidth)
@@ -284,6 +356,31 @@ func (m Metadata) GetTags() []string {
 	}
 	return nil
 }
+func (m Metadata) GetCountry() *string {
+	return m.getString(MetaCountry)
+}
+func (m Metadata) GetRegion() *string {
+	return m.getString(MetaRegion)
+}
+func (m Metadata) GetCity() *string {
+	return m.getString(MetaCity)
+}
+func (m Metadata) GetPlaceTags() []string {
+	if v, ok := m[MetaPlaceTags]; ok {
+		if tags, ok := v.AsList(); ok {
+			return tags
+		}
+	}
+	return nil
+}
+func (m Metadata) GetOverrideTags() []string {
+	if v, ok := m[MetaOverrideTags]; ok {
+		if tags, ok := v.AsList(); ok {
+			return tags
+		}
+	}
+	return nil
+}
 func (m Metadata) GetExposure() *float64 {
 	str := m.getString(MetaExposureTime)
 	if str == nil {
@@ -296,6 +393,23 @@ func (m Metadata) GetExposure() *float64 {
 	return &value
 }
 
+// GetDuration returns the playing time of a video in seconds.
+func (m Metadata) GetDuration() *float64 {
+	str := m.getString(MetaDuration)
+	if str == nil {
+		return nil
+	}
+	value, err := parseDuration(*str)
+	if err != nil {
+		log.Logger.Warn().Err(err).Str("duration", *str).Msg("can't convert")
+		return nil
+	}
+	return &value
+}
+func (m Metadata) GetVideoCodec() *string {
+	return m.getString(MetaVideoCodec)
+}
+
 func (m MetadataValue) AsFloat() (float64, bool) {
 	if m.Type != MetaFloat {
 		return 0, false
@@ -342,6 +456,31 @@ func (m MetadataValue) AsInt() (int64, bool) {
 	return 0, false
 }
 
+// AsNumber returns int, float and rational values as float64.
+// Rationals may be stored as "a/b" strings.
+func (m MetadataValue) AsNumber() (float64, bool) {
+	switch m.Type {
+	case MetaInt:
+		i, ok := m.AsInt()
+		return float64(i), ok
+	case MetaFloat:
+		return m.AsFloat()
+	case MetaRational:
+		switch v := m.Value.(type) {
+		case string:
+			f, err := parseFloatOrFraction(v)
+			return f, err == nil
+		case float64:
+			return v, true
+		case int:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		}
+	}
+	return 0, false
+}
+
 func (m MetadataValue) AsString() (string, bool) {
 	switch v := m.Value.(type) {
 	case time.Time:
@@ -539,6 +678,30 @@ func rotationFromExifOrientation(v string) int16 {
 	}
 }
 
+// parseDuration parses the exiftool duration formats:
+//
+//	"12.53 s", "12.53", "0:01:05", "1:02:03.5"
+//
+// into seconds
+func parseDuration(s string) (float64, error) {
+	str := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "s"))
+	if str == "" {
+		return 0, errors.New("empty value")
+	}
+	if !strings.Contains(str, ":") {
+		return strconv.ParseFloat(str, 64)
+	}
+	ret := 0.0
+	for _, part := range strings.Split(str, ":") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return 0, err
+		}
+		ret = ret*60 + v
+	}
+	return ret, nil
+}
+
 // parseFloatOrFraction parses strings like:
 //
 //	"0.15", "3.5", "1/500"
@@ -577,6 +740,11 @@ func parseFloatOrFraction(s string) (float64, error) {
 	return strconv.ParseFloat(str, 64)
 }
 
+// ParseRational parses a decimal number or an "a/b" fraction.
+func ParseRational(s string) (float64, error) {
+	return parseFloatOrFraction(s)
+}
+
 func abs(x float64) float64 {
 	if x < 0 {
 		return -x
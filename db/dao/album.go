@@ -11,18 +11,25 @@ import (
 	"database/sql"
 )
 
-const albumFields = `a.id, a.parent_id, a.name, a.description, a.rank, a.ancestor_ids, a.rule_json, a.cover_image_id, a.acl_level, a.acl_user_id, a.updated_at`
+const albumFields = `a.id, a.parent_id, a.name, a.description, a.rank, a.sort_order, a.image_limit, a.group_by, a.group_param, a.generator_key, a.ancestor_ids, a.rule_json, a.cover_image_id, a.acl_level, a.acl_user_id, a.updated_at`
 
 const getAlbumByID = `SELECT ` + albumFields + ` FROM albums a WHERE a.id=?`
-const createAlbum = `INSERT INTO albums (parent_id, name, description, rank, rule_json, acl_level, acl_user_id, ancestor_ids) VALUES (?,?,?,?,?,?,?, JSON_ARRAY())`
-const updateAlbum = `UPDATE albums SET parent_id=?, name=?, description=?, rank=?, rule_json=?, acl_level=?, acl_user_id=?, cover_image_id=?  WHERE id=?`
+const createAlbum = `INSERT INTO albums (parent_id, name, description, rank, sort_order, image_limit, group_by, group_param, generator_key, rule_json, acl_level, acl_user_id, ancestor_ids) VALUES (?,?,?,?,?,?,?,?,?,?,?,?, JSON_ARRAY())`
+const updateAlbum = `UPDATE albums SET parent_id=?, name=?, description=?, rank=?, sort_order=?, image_limit=?, group_by=?, group_param=?, rule_json=?, acl_level=?, acl_user_id=?, cover_image_id=?  WHERE id=?`
 const deleteAlbum = `DELETE FROM albums WHERE id=?`
 
 const queryAlbum = `SELECT ` + albumFields + ` FROM albums as a`
 
-const bindAlbumImage = `INSERT INTO album_images (album_id, image_id, position) VALUES (?,?,?)`
+// a new image of a limited album stays hidden until the next reorder places it
+const bindAlbumImage = `INSERT INTO album_images (album_id, image_id, position, over_limit)
+VALUES (?,?,?, (SELECT a.image_limit IS NOT NULL FROM albums a WHERE a.id = ?))`
 const breakAlbumImage = `DELETE FROM album_images WHERE album_id = ? AND image_id = ?`
 
+// an emptied generated album is kept with its edited fields, but hidden
+// until its key has images again
+const albumVisible = ` AND (a.generator_key IS NULL OR EXISTS (
+    SELECT 1 FROM album_images va WHERE va.album_id = a.id AND va.over_limit = FALSE)) `
+
 // children by parent (parent_id may be NULL)
 const queryAlbumByParentACLBegin = `
 SELECT ` + albumFields + `
@@ -30,7 +37,7 @@ FROM albums a
 WHERE `
 
 const queryAlbumByParentACLPagedEnd = `
-AND %s 
+AND %s ` + albumVisible + `
 ORDER BY a.rank ASC, a.name ASC
 LIMIT ?, ?
 `
@@ -45,8 +52,7 @@ FROM albums a
 WHERE `
 
 const countAlbumByParentACLEnd = `
-AND %s 
-`
+AND %s ` + albumVisible
 const countAlbumByParentACL = countAlbumByParentACLBegin +
 	` a.parent_id = ? ` + countAlbumByParentACLEnd
 const countAlbumByParentACLRoot = countAlbumByParentACLBegin +
@@ -64,7 +70,7 @@ const updateAlbumQuick = `UPDATE albums SET name = ?, description = ? WHERE id =
 const queryAlbumDescendantIDsByACL = `SELECT a.id
 FROM albums a
 WHERE JSON_CONTAINS(a.ancestor_ids, ?)
-AND %s `
+AND %s ` + albumVisible
 
 const queryAlbumGraph = `SELECT a.id, a.parent_id, a.name FROM albums AS a`
 
@@ -92,48 +98,101 @@ SELECT COUNT(*) FROM albums;
 `
 
 const queryAlbumIDByImageID = `
-SELECT ai.album_id FROM album_images ai WHERE ai.image_id = ?;
+SELECT ai.album_id FROM album_images ai WHERE ai.image_id = ? AND ai.over_limit = FALSE;
+`
+const queryAlbumBindingsByImageID = `
+SELECT ai.album_id, ai.over_limit FROM album_images ai WHERE ai.image_id = ?;
+`
+const queryAlbumImageBindings = `
+SELECT ai.image_id, ai.album_id, ai.over_limit FROM album_images ai;
 `
 const queryAlbumsByImageIDACL = `
 SELECT ` + albumFields + ` FROM album_images ai 
 JOIN albums a
 ON ai.album_id = a.id
-WHERE ai.image_id = ? AND %s ;
+WHERE ai.image_id = ? AND ai.over_limit = FALSE AND %s ;
 `
 
-const reorderAllImages = `
+// the sort_order of the album picks one of the CASE keys, the others are NULL;
+// the images after the album limit are flagged, the relations are kept, so the
+// next sync does not bind them again
+const reorderImagesBegin = `
 UPDATE album_images ai
 JOIN (
     SELECT
         ai.album_id,
         ai.image_id,
+        a.image_limit,
         ROW_NUMBER() OVER (
             PARTITION BY ai.album_id
-            ORDER BY i.order_date, i.filename, i.id
+            ORDER BY
+                CASE WHEN a.sort_order = 'taken_at_desc' THEN i.order_date END DESC,
+                CASE WHEN a.sort_order = 'rating_desc' THEN i.rating END DESC,
+                CASE WHEN a.sort_order = 'rating_asc' THEN i.rating IS NULL END,
+                CASE WHEN a.sort_order = 'rating_asc' THEN i.rating END ASC,
+                CASE WHEN a.sort_order = 'filename_asc' THEN i.filename END ASC,
+                CASE WHEN a.sort_order = 'filename_desc' THEN i.filename END DESC,
+                CASE WHEN a.sort_order = 'random_daily' THEN CRC32(CONCAT(i.id, '-', CURRENT_DATE())) END,
+                i.order_date, i.filename, i.id
         ) AS new_position
     FROM album_images ai
     JOIN images i ON i.id = ai.image_id
+    JOIN albums a ON a.id = ai.album_id
+`
+const reorderImagesEnd = `
 ) x
 ON ai.album_id = x.album_id
 AND ai.image_id = x.image_id
-SET ai.position = x.new_position;
+SET ai.position = x.new_position,
+    ai.over_limit = (x.image_limit IS NOT NULL AND x.new_position > x.image_limit);
+`
+const reorderAllImages = reorderImagesBegin + reorderImagesEnd
+const reorderGeneratedImages = reorderImagesBegin + `WHERE a.generator_key IS NOT NULL` + reorderImagesEnd
+
+// generator albums: key values of the album images within the album limit
+const queryAlbumGroupKeysByTag = `
+SELECT ai.image_id, t.name FROM album_images ai
+JOIN image_tags it ON it.image_id = ai.image_id
+JOIN tags t ON t.id = it.tag_id
+WHERE ai.album_id = ? AND ai.over_limit = FALSE AND t.parent_id = ?;
+`
+const queryAlbumGroupKeysByTakenAt = `
+SELECT ai.image_id, DATE_FORMAT(i.taken_at_local, ?) FROM album_images ai
+JOIN images i ON i.id = ai.image_id
+WHERE ai.album_id = ? AND ai.over_limit = FALSE AND i.taken_at_local IS NOT NULL;
+`
+const queryAlbumGroupKeysByPath = `
+SELECT ai.image_id, i.path FROM album_images ai
+JOIN images i ON i.id = ai.image_id
+WHERE ai.album_id = ? AND ai.over_limit = FALSE;
+`
+const queryImageIDsByAlbum = `
+SELECT ai.image_id FROM album_images ai WHERE ai.album_id = ?;
+`
+const updateGeneratedAlbums = `
+UPDATE albums c
+JOIN albums p ON p.id = c.parent_id
+SET c.sort_order = p.sort_order, c.image_limit = p.image_limit,
+    c.acl_level = p.acl_level, c.acl_user_id = p.acl_user_id
+WHERE c.parent_id = ? AND c.generator_key IS NOT NULL;
 `
 
 const countAlbumDescendantIDsByACL = `SELECT count(a.id)
 FROM albums a
 WHERE JSON_CONTAINS(a.ancestor_ids, ?)
-AND %s `
+AND %s ` + albumVisible
 
 const getAlbumByIDACL = `
 SELECT ` + albumFields + ` FROM albums a WHERE 
 a.id=?
-AND %s `
+AND %s ` + albumVisible
 
 const updateAlbumCountersBydepth = `
 UPDATE albums parent
 LEFT JOIN (
     SELECT album_id, COUNT(*) AS own_images
     FROM album_images
+    WHERE over_limit = FALSE
     GROUP BY album_id
 ) own ON own.album_id = parent.id
 
@@ -169,7 +228,7 @@ WHERE JSON_LENGTH(parent.ancestor_ids) = ?;
 func parseAlbum(row *sql.Row) (dbo.Album, error) {
 	var a dbo.Album
 	var ancestors []byte
-	err := row.Scan(&a.ID, &a.ParentID, &a.Name, &a.Description, &a.Rank, &ancestors, &a.RuleJSON, &a.CoverImageID,
+	err := row.Scan(&a.ID, &a.ParentID, &a.Name, &a.Description, &a.Rank, &a.Sort, &a.Limit, &a.GroupBy, &a.GroupParam, &a.GeneratorKey, &ancestors, &a.RuleJSON, &a.CoverImageID,
 		&a.ACLLevel, &a.ACLUserID, &a.UpdatedAt)
 	if err != nil {
 		return a, err
@@ -191,7 +250,7 @@ func parseAlbums(rows *sql.Rows) ([]dbo.Album, error) {
 	for rows.Next() {
 		var a dbo.Album
 		var ancestors []byte
-		err := rows.Scan(&a.ID, &a.ParentID, &a.Name, &a.Description, &a.Rank, &ancestors, &a.RuleJSON, &a.CoverImageID,
+		err := rows.Scan(&a.ID, &a.ParentID, &a.Name, &a.Description, &a.Rank, &a.Sort, &a.Limit, &a.GroupBy, &a.GroupParam, &a.GeneratorKey, &ancestors, &a.RuleJSON, &a.CoverImageID,
 			&a.ACLLevel, &a.ACLUserID, &a.UpdatedAt)
 		if err != nil {
 			return nil, err
@@ -227,7 +286,7 @@ func (q *Queries) GetAlbumByID(ctx context.Context, albumID dbo.AlbumID) (dbo.Al
 // Output:
 //   - error: exec error, if any.
 func (q *Queries) CreateAlbum(ctx context.Context, a dbo.Album) error {
-	_, err := q.db.ExecContext(ctx, createAlbum, a.ParentID, a.Name, a.Description, a.Rank, a.RuleJSON, a.ACLLevel, a.ACLUserID)
+	_, err := q.db.ExecContext(ctx, createAlbum, a.ParentID, a.Name, a.Description, a.Rank, a.GetSort(), a.Limit, a.GroupBy, a.GroupParam, a.GeneratorKey, a.RuleJSON, a.ACLLevel, a.ACLUserID)
 	return err
 }
 
@@ -240,7 +299,7 @@ func (q *Queries) CreateAlbum(ctx context.Context, a dbo.Album) error {
 // Output:
 //   - error: exec error, if any.
 func (q *Queries) UpdateAlbum(ctx context.Context, a dbo.Album) error {
-	_, err := q.db.ExecContext(ctx, updateAlbum, a.ParentID, a.Name, a.Description, a.Rank, a.RuleJSON, a.ACLLevel, a.ACLUserID, a.CoverImageID, a.ID)
+	_, err := q.db.ExecContext(ctx, updateAlbum, a.ParentID, a.Name, a.Description, a.Rank, a.GetSort(), a.Limit, a.GroupBy, a.GroupParam, a.RuleJSON, a.ACLLevel, a.ACLUserID, a.CoverImageID, a.ID)
 	return err
 }
 
@@ -285,7 +344,7 @@ func (q *Queries) QueryAlbum(ctx context.Context) ([]dbo.Album, error) {
 // Output:
 //   - error: exec error, if any.
 func (q *Queries) BindAlbumImage(ctx context.Context, albumID dbo.AlbumID, imageID dbo.ImageID, pos *uint32) error {
-	_, err := q.db.ExecContext(ctx, bindAlbumImage, albumID, imageID, pos)
+	_, err := q.db.ExecContext(ctx, bindAlbumImage, albumID, imageID, pos, albumID)
 	return err
 }
 
@@ -566,7 +625,8 @@ func (q *Queries) CountAlbum(ctx context.Context) (uint64, error) {
 	return count, err
 }
 
-// QueryAlbumsIDByImageID reads album IDs containing an image.
+// QueryAlbumsIDByImageID reads album IDs containing an image, the relations
+// after the album limit are left out.
 //
 // Input:
 //   - ctx: request context.
@@ -593,6 +653,62 @@ func (q *Queries) QueryAlbumsIDByImageID(ctx context.Context, imageID dbo.ImageI
 	return ids, rows.Err()
 }
 
+// QueryAlbumBindingsByImageID reads every album relation of an image,
+// including the ones after the album limit.
+//
+// Input:
+//   - ctx: request context.
+//   - imageID: image ID to look up.
+//
+// Output:
+//   - []dbo.AlbumBinding: relations of the image.
+//   - error: query, scan, or row iteration error.
+func (q *Queries) QueryAlbumBindingsByImageID(ctx context.Context, imageID dbo.ImageID) ([]dbo.AlbumBinding, error) {
+	rows, err := q.db.QueryContext(ctx, queryAlbumBindingsByImageID, imageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]dbo.AlbumBinding, 0)
+	for rows.Next() {
+		var b dbo.AlbumBinding
+		if err := rows.Scan(&b.AlbumID, &b.OverLimit); err != nil {
+			return nil, err
+		}
+		ret = append(ret, b)
+	}
+	return ret, rows.Err()
+}
+
+// QueryAlbumImageBindings reads every album relation grouped by image,
+// including the ones after the album limit.
+//
+// Input:
+//   - ctx: request context.
+//
+// Output:
+//   - map[dbo.ImageID][]dbo.AlbumBinding: relations per image.
+//   - error: query, scan, or row iteration error.
+func (q *Queries) QueryAlbumImageBindings(ctx context.Context) (map[dbo.ImageID][]dbo.AlbumBinding, error) {
+	rows, err := q.db.QueryContext(ctx, queryAlbumImageBindings)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make(map[dbo.ImageID][]dbo.AlbumBinding)
+	for rows.Next() {
+		var imageID dbo.ImageID
+		var b dbo.AlbumBinding
+		if err := rows.Scan(&imageID, &b.AlbumID, &b.OverLimit); err != nil {
+			return nil, err
+		}
+		ret[imageID] = append(ret[imageID], b)
+	}
+	return ret, rows.Err()
+}
+
 // QueryAlbumsByImageIDACL reads albums containing an image and visible through ACL.
 //
 // Input:
@@ -617,7 +733,8 @@ func (q *Queries) QueryAlbumsByImageIDACL(ctx context.Context, imageID dbo.Image
 	return parseAlbums(rows)
 }
 
-// ReorderAllImage recalculates album-image positions.
+// ReorderAllImage recalculates album-image positions and flags the relations
+// after the album limit.
 //
 // Input:
 //   - ctx: request context.
@@ -629,6 +746,19 @@ func (q *Queries) ReorderAllImage(ctx context.Context) error {
 	return err
 }
 
+// ReorderGeneratedImages recalculates album-image positions of the generated
+// albums.
+//
+// Input:
+//   - ctx: request context.
+//
+// Output:
+//   - error: exec error, if any.
+func (q *Queries) ReorderGeneratedImages(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, reorderGeneratedImages)
+	return err
+}
+
 // CountAlbumDescendantIDsByACL counts descendant albums visible through ACL.
 //
 // Input:
@@ -1228,7 +1358,8 @@ func CountAlbum(db *sql.DB, c context.Context) (uint64, error) {
 	return qty, nil
 }
 
-// QueryAlbumsIDByImageID reads album IDs containing an image.
+// QueryAlbumsIDByImageID reads album IDs containing an image, the relations
+// after the album limit are left out.
 //
 // Input:
 //   - db: database handle.
@@ -1252,6 +1383,51 @@ func QueryAlbumsIDByImageID(db *sql.DB, c context.Context, imageID dbo.ImageID)
 	return albums, nil
 }
 
+// QueryAlbumBindingsByImageID reads every album relation of an image with logging.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//   - imageID: image ID to look up.
+//
+// Output:
+//   - []dbo.AlbumBinding: relations of the image.
+//   - error: query, scan, or row iteration error.
+func QueryAlbumBindingsByImageID(db *sql.DB, c context.Context, imageID dbo.ImageID) ([]dbo.AlbumBinding, error) {
+	logScope, ctx := logging.Enter(c, "dao/album/query/binding/byImage", imageID, map[string]any{
+		"image_id": imageID,
+	})
+	q := NewQueries(db)
+	bindings, err := q.QueryAlbumBindingsByImageID(ctx, imageID)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"found": len(bindings)})
+	return bindings, nil
+}
+
+// QueryAlbumImageBindings reads every album relation grouped by image with logging.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//
+// Output:
+//   - map[dbo.ImageID][]dbo.AlbumBinding: relations per image.
+//   - error: query, scan, or row iteration error.
+func QueryAlbumImageBindings(db *sql.DB, c context.Context) (map[dbo.ImageID][]dbo.AlbumBinding, error) {
+	logScope, ctx := logging.Enter(c, "dao/album/query/bindings", nil, nil)
+	q := NewQueries(db)
+	ret, err := q.QueryAlbumImageBindings(ctx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"found": len(ret)})
+	return ret, nil
+}
+
 // QueryAlbumsByImageIDACL reads albums containing an image and visible through ACL.
 //
 // Input:
@@ -1278,7 +1454,8 @@ func QueryAlbumsByImageIDACL(db *sql.DB, c context.Context, imageID dbo.ImageID,
 	return albums, nil
 }
 
-// ReorderAllImages recalculates album-image positions.
+// ReorderAllImages recalculates album-image positions by the album sort order
+// and hides the images after the album limit.
 //
 // Input:
 //   - db: database handle.
@@ -1289,8 +1466,22 @@ func QueryAlbumsByImageIDACL(db *sql.DB, c context.Context, imageID dbo.ImageID,
 func ReorderAllImages(db *sql.DB, c context.Context) error {
 	logScope, ctx := logging.Enter(c, "dao/album/update/image/reorder", nil, nil)
 	q := NewQueries(db)
-	err := q.ReorderAllImage(ctx)
-	return logging.Return(logScope, err)
+	return logging.Return(logScope, q.ReorderAllImage(ctx))
+}
+
+// ReorderGeneratedImages recalculates album-image positions of the generated
+// albums by their sort order.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//
+// Output:
+//   - error: exec error, if any.
+func ReorderGeneratedImages(db *sql.DB, c context.Context) error {
+	logScope, ctx := logging.Enter(c, "dao/album/update/image/reorder/generated", nil, nil)
+	q := NewQueries(db)
+	return logging.Return(logScope, q.ReorderGeneratedImages(ctx))
 }
 
 // CountAlbumDescendantIDsByACL counts descendant albums visible through ACL.
@@ -1339,3 +1530,192 @@ func GetAlbumByIDACL(db *sql.DB, c context.Context, albumID dbo.AlbumID, acl dbo
 	album, err := q.GetAlbumByIDACL(ctx, albumID, acl)
 	return album, returnWrapNotFound(logScope, err, "album")
 }
+
+// QueryAlbumGroupKeys reads (image ID, key) pairs of a generator album query.
+//
+// Input:
+//   - ctx: request context.
+//   - query: one of the queryAlbumGroupKeysBy* queries.
+//   - args: query parameters.
+//
+// Output:
+//   - map[string][]dbo.ImageID: image IDs per key.
+//   - error: query, scan, or row iteration error.
+func (q *Queries) QueryAlbumGroupKeys(ctx context.Context, query string, args ...any) (map[string][]dbo.ImageID, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make(map[string][]dbo.ImageID)
+	for rows.Next() {
+		var imageID dbo.ImageID
+		var key string
+		if err := rows.Scan(&imageID, &key); err != nil {
+			return nil, err
+		}
+		ret[key] = append(ret[key], imageID)
+	}
+	return ret, rows.Err()
+}
+
+// QueryImageIDsByAlbum reads the image IDs bound to an album.
+//
+// Input:
+//   - ctx: request context.
+//   - albumID: album ID to look up.
+//
+// Output:
+//   - []dbo.ImageID: bound image IDs.
+//   - error: query, scan, or row iteration error.
+func (q *Queries) QueryImageIDsByAlbum(ctx context.Context, albumID dbo.AlbumID) ([]dbo.ImageID, error) {
+	rows, err := q.db.QueryContext(ctx, queryImageIDsByAlbum, albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]dbo.ImageID, 0)
+	for rows.Next() {
+		var id dbo.ImageID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdateGeneratedAlbums copies the inherited fields (sort, limit, ACL) of a
+// generator album to its generated children.
+//
+// Input:
+//   - ctx: request context.
+//   - parentID: generator album ID.
+//
+// Output:
+//   - error: exec error, if any.
+func (q *Queries) UpdateGeneratedAlbums(ctx context.Context, parentID dbo.AlbumID) error {
+	_, err := q.db.ExecContext(ctx, updateGeneratedAlbums, parentID)
+	return err
+}
+
+// QueryAlbumGroupKeysByTag groups the images of an album by the child tags of a tag.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//   - albumID: generator album ID.
+//   - tagID: root tag ID, the names of its children are the keys.
+//
+// Output:
+//   - map[string][]dbo.ImageID: image IDs per child tag name.
+//   - error: query, scan, or row iteration error.
+func QueryAlbumGroupKeysByTag(db *sql.DB, c context.Context, albumID dbo.AlbumID, tagID dbo.TagID) (map[string][]dbo.ImageID, error) {
+	logScope, ctx := logging.Enter(c, "dao/album/query/group/tag", albumID, map[string]any{
+		"album_id": albumID,
+		"tag_id":   tagID,
+	})
+	q := NewQueries(db)
+	ret, err := q.QueryAlbumGroupKeys(ctx, queryAlbumGroupKeysByTag, albumID, tagID)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"keys": len(ret)})
+	return ret, nil
+}
+
+// QueryAlbumGroupKeysByTakenAt groups the images of an album by the local capture time.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//   - albumID: generator album ID.
+//   - format: MySQL DATE_FORMAT pattern of the key, e.g. "%Y".
+//
+// Output:
+//   - map[string][]dbo.ImageID: image IDs per formatted date, images without time are skipped.
+//   - error: query, scan, or row iteration error.
+func QueryAlbumGroupKeysByTakenAt(db *sql.DB, c context.Context, albumID dbo.AlbumID, format string) (map[string][]dbo.ImageID, error) {
+	logScope, ctx := logging.Enter(c, "dao/album/query/group/taken_at", albumID, map[string]any{
+		"album_id": albumID,
+		"format":   format,
+	})
+	q := NewQueries(db)
+	ret, err := q.QueryAlbumGroupKeys(ctx, queryAlbumGroupKeysByTakenAt, format, albumID)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"keys": len(ret)})
+	return ret, nil
+}
+
+// QueryAlbumGroupKeysByPath groups the images of an album by their relative path.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//   - albumID: generator album ID.
+//
+// Output:
+//   - map[string][]dbo.ImageID: image IDs per path.
+//   - error: query, scan, or row iteration error.
+func QueryAlbumGroupKeysByPath(db *sql.DB, c context.Context, albumID dbo.AlbumID) (map[string][]dbo.ImageID, error) {
+	logScope, ctx := logging.Enter(c, "dao/album/query/group/path", albumID, map[string]any{
+		"album_id": albumID,
+	})
+	q := NewQueries(db)
+	ret, err := q.QueryAlbumGroupKeys(ctx, queryAlbumGroupKeysByPath, albumID)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"keys": len(ret)})
+	return ret, nil
+}
+
+// QueryImageIDsByAlbum reads the image IDs bound to an album with logging.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//   - albumID: album ID to look up.
+//
+// Output:
+//   - []dbo.ImageID: bound image IDs.
+//   - error: query, scan, or row iteration error.
+func QueryImageIDsByAlbum(db *sql.DB, c context.Context, albumID dbo.AlbumID) ([]dbo.ImageID, error) {
+	logScope, ctx := logging.Enter(c, "dao/album/query/image_ids", albumID, map[string]any{
+		"album_id": albumID,
+	})
+	q := NewQueries(db)
+	ids, err := q.QueryImageIDsByAlbum(ctx, albumID)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"found": len(ids)})
+	return ids, nil
+}
+
+// UpdateGeneratedAlbums copies the inherited fields (sort, limit, ACL) of a
+// generator album to its generated children with logging.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//   - parentID: generator album ID.
+//
+// Output:
+//   - error: exec error, if any.
+func UpdateGeneratedAlbums(db *sql.DB, c context.Context, parentID dbo.AlbumID) error {
+	logScope, ctx := logging.Enter(c, "dao/album/update/generated", parentID, map[string]any{
+		"parent_id": parentID,
+	})
+	q := NewQueries(db)
+	err := q.UpdateGeneratedAlbums(ctx, parentID)
+	return logging.Return(logScope, err)
+}
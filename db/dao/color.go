@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
+const upsertColorWarning = `INSERT INTO color_warnings (image_id, profile, message) VALUES (?,?,?)
+ON DUPLICATE KEY UPDATE profile = VALUES(profile), message = VALUES(message), created_at = NOW()`
+
+const deleteColorWarning = `DELETE FROM color_warnings WHERE image_id = ?`
+
+const queryColorWarnings = `SELECT cw.image_id, i.root, i.path, i.filename, i.ext, cw.profile, cw.message, cw.created_at
+FROM color_warnings cw
+JOIN images i ON i.id = cw.image_id
+ORDER BY cw.created_at DESC
+LIMIT ?`
+
+const countColorWarnings = `SELECT count(*) FROM color_warnings`
+
+func (q *Queries) UpsertColorWarning(ctx context.Context, imageID dbo.ImageID, profile, message string) error {
+	_, err := q.db.ExecContext(ctx, upsertColorWarning, imageID, profile, message)
+	return err
+}
+
+func (q *Queries) DeleteColorWarning(ctx context.Context, imageID dbo.ImageID) error {
+	_, err := q.db.ExecContext(ctx, deleteColorWarning, imageID)
+	return err
+}
+
+func (q *Queries) QueryColorWarnings(ctx context.Context, qty uint64) ([]dbo.ColorWarning, error) {
+	rows, err := q.db.QueryContext(ctx, queryColorWarnings, qty)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ret := []dbo.ColorWarning{}
+	for rows.Next() {
+		var w dbo.ColorWarning
+		if err := rows.Scan(&w.ImageID, &w.Root, &w.Path, &w.Filename, &w.Ext, &w.Profile, &w.Message, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		ret = append(ret, w)
+	}
+	return ret, rows.Err()
+}
+
+func (q *Queries) CountColorWarnings(ctx context.Context) (uint64, error) {
+	row := q.db.QueryRowContext(ctx, countColorWarnings)
+	var count uint64
+	err := row.Scan(&count)
+	return count, err
+}
+
+//
+// =========================================================
+// Public API functions
+// =========================================================
+//
+
+func UpsertColorWarning(db *sql.DB, c context.Context, imageID dbo.ImageID, profile, message string) error {
+	logScope, ctx := logging.Enter(c, "dao/color_warning/upsert", imageID, map[string]any{"image_id": imageID, "profile": profile, "message": message})
+	q := NewQueries(db)
+	err := q.UpsertColorWarning(ctx, imageID, profile, message)
+	return logScope.Return(err)
+}
+
+func DeleteColorWarning(db *sql.DB, c context.Context, imageID dbo.ImageID) error {
+	logScope, ctx := logging.Enter(c, "dao/color_warning/delete", imageID, map[string]any{"image_id": imageID})
+	q := NewQueries(db)
+	err := q.DeleteColorWarning(ctx, imageID)
+	return logScope.Return(err)
+}
+
+func QueryColorWarnings(db *sql.DB, c context.Context, qty uint64) ([]dbo.ColorWarning, error) {
+	logScope, ctx := logging.Enter(c, "dao/color_warning/query", nil, map[string]any{"qty": qty})
+	q := NewQueries(db)
+	warnings, err := q.QueryColorWarnings(ctx, qty)
+	if err != nil {
+		logScope.ExitErr(err)
+		return nil, err
+	}
+	logScope.Exit("ok", map[string]any{"found": len(warnings)})
+	return warnings, nil
+}
+
+func CountColorWarnings(db *sql.DB, c context.Context) (uint64, error) {
+	logScope, ctx := logging.Enter(c, "dao/color_warning/count", nil, nil)
+	q := NewQueries(db)
+	qty, err := q.CountColorWarnings(ctx)
+	if err != nil {
+		logScope.ExitErr(err)
+		return 0, err
+	}
+	logScope.Exit("ok", map[string]any{"return": qty})
+	return qty, nil
+}
@@ -17,8 +17,9 @@ const imageFields = `
 i.id, i.root, i.path, i.filename, i.ext,
 i.file_size, i.mtime, i.file_hash, i.meta_hash,
 i.title, i.caption,
-i.taken_at, i.camera, i.lens, i.focal_length, i.aperture, i.exposure, i.iso,
-i.latitude, i.longitude, i.rotation, i.rating, i.width, i.height, i.panorama,
+i.taken_at, i.taken_at_local, i.taken_at_tz, i.camera, i.lens, i.focal_length, i.aperture, i.exposure, i.iso,
+i.latitude, i.longitude, i.gps_source, i.rotation, i.rating, i.width, i.height, i.panorama,
+i.media_type, i.duration, i.video_codec,
 i.focus_x, i.focus_y, i.focus_mode, i.focus_source,
 i.exif_json,
 i.acl_level, i.acl_user_id, i.acl_source,
@@ -50,13 +51,14 @@ INSERT INTO images (
   root, path, filename, ext,
   file_size, mtime, file_hash, meta_hash,
   title, caption,
-  taken_at, order_date, 
+  taken_at, order_date, taken_at_local, taken_at_tz,
   camera, lens, focal_length, aperture, exposure, iso,
-  latitude, longitude, rotation, rating, width, height, panorama,
+  latitude, longitude, gps_source, rotation, rating, width, height, panorama,
+  media_type, duration, video_codec,
   focus_x, focus_y, focus_mode, focus_source,
   exif_json,
   acl_level, acl_user_id, acl_source, last_seen_sync
-) VALUES (?,?,?,?,?,?,?,?,?,?,?,IFNULL(taken_at, '1000-01-01 00:00:00'),?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,IFNULL(taken_at, '1000-01-01 00:00:00'),?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
 
 const deleteImage = `DELETE FROM images WHERE id=?`
 
@@ -65,9 +67,10 @@ UPDATE images SET
   root=?, path=?, filename=?, ext=?,
   file_size=?, mtime=?, file_hash=?, meta_hash=?,
   title=?, caption=?,
-  taken_at=?, order_date = IFNULL(taken_at, '1000-01-01 00:00:00'),
+  taken_at=?, order_date = IFNULL(taken_at, '1000-01-01 00:00:00'), taken_at_local=?, taken_at_tz=?,
   camera=?, lens=?, focal_length=?, aperture=?, exposure=?, iso=?,
-  latitude=?, longitude=?, rotation=?, rating=?, width=?, height=?, panorama=?,
+  latitude=?, longitude=?, gps_source=?, rotation=?, rating=?, width=?, height=?, panorama=?,
+  media_type=?, duration=?, video_codec=?,
   focus_x=?, focus_y=?, focus_mode=?, focus_source=?, 
   exif_json=?,
   acl_level=?, acl_user_id=?, acl_source=?, last_seen_sync=?
@@ -85,6 +88,7 @@ SELECT COUNT(DISTINCT ai.image_id)
 FROM album_images ai
 JOIN images i ON i.id = ai.image_id
 WHERE ai.album_id IN (%s)
+AND ai.over_limit = FALSE
 AND %s `
 
 const queryImageIDsByAlbumsPage = `
@@ -92,6 +96,7 @@ SELECT ai.image_id
 FROM album_images ai
 JOIN images i ON i.id = ai.image_id
 WHERE ai.album_id IN (%s)
+AND ai.over_limit = FALSE
 AND ai.image_id > ?
 AND %s
 GROUP BY ai.image_id
@@ -136,7 +141,7 @@ const queryImageRoots = `SELECT DISTINCT i.root FROM images i order by i.root`
 const queryImageIDByTagACLNext = `SELECT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name ` + imageByTagACLWhere + " AND " + imageWhereNext + defaultImageOrderForward + ` LIMIT ?, ?`
 const queryImageIDByTagACLPrev = `SELECT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name ` + imageByTagACLWhere + " AND " + imageWherePrev + defaultImageOrderBackward + ` LIMIT ?, ?`
 
-const queryImageCoordByTagACL = `SELECT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name, i.latitude, i.longitude` + imageByTagACLWhere + defaultImageOrderForward
+const queryImageCoordByTagACL = `SELECT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name, i.latitude, i.longitude, i.gps_source` + imageByTagACLWhere + defaultImageOrderForward
 
 const queryImageRandomByHashByACLForward = `
 SELECT ` + imageFields + ` FROM images AS i WHERE i.file_hash >=? AND %s ORDER BY i.file_hash, i.id LIMIT ? `
@@ -152,6 +157,9 @@ SELECT COUNT(*) FROM images AS i WHERE last_seen_sync = ?`
 const countImage = `
 SELECT COUNT(*) FROM images`
 
+// keyset paging: a row deleted meanwhile does not shift the next page
+const queryImageAfterID = `SELECT ` + imageFields + ` FROM images i WHERE i.id > ? ORDER BY i.id LIMIT ?`
+
 const countImageACLLevels = `
 SELECT i.acl_level, COUNT(*) FROM images AS i GROUP BY i.acl_level`
 
@@ -159,38 +167,38 @@ const countImageByAlbumACL = `SELECT count(i.id)
 FROM album_images ai
 JOIN images i ON i.id = ai.image_id
 AND %s 
-WHERE ai.album_id=? `
+WHERE ai.album_id=? AND ai.over_limit = FALSE `
 
 const queryImageByAlbumACLPaged = `SELECT ` + imageFields + `
 FROM album_images ai
 JOIN images i ON i.id = ai.image_id
 AND %s 
-WHERE ai.album_id=? 
+WHERE ai.album_id=? AND ai.over_limit = FALSE
 ORDER BY ai.position
 LIMIT ?,?
 `
 
 const countImagesByAlbumDescendantIDsByACL = `SELECT count(DISTINCT i.id)
 FROM albums a
-JOIN album_images ai ON a.ID = ai.album_id
+JOIN album_images ai ON a.ID = ai.album_id AND ai.over_limit = FALSE
 JOIN images i ON i.id = ai.image_id
 AND %s 
 WHERE JSON_CONTAINS(a.ancestor_ids, ?)
 AND %s `
 
-const queryImageCoordByAlbumDescendantIDsByACL = `SELECT DISTINCT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name, i.latitude, i.longitude
+const queryImageCoordByAlbumDescendantIDsByACL = `SELECT DISTINCT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name, i.latitude, i.longitude, i.gps_source
 FROM albums a
-JOIN album_images ai ON a.ID = ai.album_id
+JOIN album_images ai ON a.ID = ai.album_id AND ai.over_limit = FALSE
 JOIN images i ON i.id = ai.image_id
 AND %s 
 WHERE JSON_CONTAINS(a.ancestor_ids, ?)
 AND %s `
 
 const queryImageCoordByAlbumRootByACL = `
-SELECT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name, i.latitude, i.longitude
+SELECT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name, i.latitude, i.longitude, i.gps_source
 FROM images i
 INNER JOIN album_images AS ai
-ON i.id = ai.image_id
+ON i.id = ai.image_id AND ai.over_limit = FALSE
 WHERE 1=1
 AND %s
 `
@@ -199,7 +207,7 @@ const queryImageIDByAlbumACLBegin = `
 SELECT i.id,COALESCE(NULLIF(i.title,''), i.filename) AS display_name FROM album_images AS ai
 JOIN images AS i
 ON i.id = ai.image_id
-WHERE ai.album_id = ?
+WHERE ai.album_id = ? AND ai.over_limit = FALSE
 AND %s
 `
 
@@ -230,6 +238,7 @@ FROM images i
 LEFT JOIN (
     SELECT DISTINCT image_id
     FROM album_images
+    WHERE over_limit = FALSE
 ) ai ON ai.image_id = i.id;
 `
 
@@ -262,6 +271,8 @@ func parseImage(row *sql.Row) (dbo.Image, error) {
 		&i.Title,
 		&i.Caption,
 		&i.TakenAt,
+		&i.TakenAtLocal,
+		&i.TakenAtTZ,
 		&i.Camera,
 		&i.Lens,
 		&i.FocalLength,
@@ -270,11 +281,15 @@ func parseImage(row *sql.Row) (dbo.Image, error) {
 		&i.ISO,
 		&i.Latitude,
 		&i.Longitude,
+		&i.GPSSource,
 		&i.Rotation,
 		&i.Rating,
 		&i.Width,
 		&i.Height,
 		&i.Panorama,
+		&i.MediaType,
+		&i.Duration,
+		&i.VideoCodec,
 		&i.FocusX,
 		&i.FocusY,
 		&i.FocusMode,
@@ -335,6 +350,8 @@ func parseImageRows(rows *sql.Rows) ([]dbo.Image, error) {
 			&i.Title,
 			&i.Caption,
 			&i.TakenAt,
+			&i.TakenAtLocal,
+			&i.TakenAtTZ,
 			&i.Camera,
 			&i.Lens,
 			&i.FocalLength,
@@ -343,11 +360,15 @@ func parseImageRows(rows *sql.Rows) ([]dbo.Image, error) {
 			&i.ISO,
 			&i.Latitude,
 			&i.Longitude,
+			&i.GPSSource,
 			&i.Rotation,
 			&i.Rating,
 			&i.Width,
 			&i.Height,
 			&i.Panorama,
+			&i.MediaType,
+			&i.Duration,
+			&i.VideoCodec,
 			&i.FocusX,
 			&i.FocusY,
 			&i.FocusMode,
@@ -422,6 +443,8 @@ func (q *Queries) CreateImage(ctx context.Context, i dbo.Image) error {
 		i.Title,
 		i.Caption,
 		i.TakenAt,
+		i.TakenAtLocal,
+		i.TakenAtTZ,
 		i.Camera,
 		i.Lens,
 		i.FocalLength,
@@ -430,11 +453,15 @@ func (q *Queries) CreateImage(ctx context.Context, i dbo.Image) error {
 		i.ISO,
 		i.Latitude,
 		i.Longitude,
+		i.GPSSource,
 		i.Rotation,
 		i.Rating,
 		i.Width,
 		i.Height,
 		i.Panorama,
+		i.MediaType,
+		i.Duration,
+		i.VideoCodec,
 		i.FocusX,
 		i.FocusY,
 		i.FocusMode,
@@ -487,6 +514,8 @@ func (q *Queries) UpdateImage(ctx context.Context, i dbo.Image) error {
 		i.Title,
 		i.Caption,
 		i.TakenAt,
+		i.TakenAtLocal,
+		i.TakenAtTZ,
 		i.Camera,
 		i.Lens,
 		i.FocalLength,
@@ -495,11 +524,15 @@ func (q *Queries) UpdateImage(ctx context.Context, i dbo.Image) error {
 		i.ISO,
 		i.Latitude,
 		i.Longitude,
+		i.GPSSource,
 		i.Rotation,
 		i.Rating,
 		i.Width,
 		i.Height,
 		i.Panorama,
+		i.MediaType,
+		i.Duration,
+		i.VideoCodec,
 		i.FocusX,
 		i.FocusY,
 		i.FocusMode,
@@ -710,6 +743,8 @@ func (q *Queries) QueryImageWLastSyncByWUserPathPaged(ctx context.Context, root,
 			&i.Title,
 			&i.Caption,
 			&i.TakenAt,
+			&i.TakenAtLocal,
+			&i.TakenAtTZ,
 			&i.Camera,
 			&i.Lens,
 			&i.FocalLength,
@@ -718,11 +753,15 @@ func (q *Queries) QueryImageWLastSyncByWUserPathPaged(ctx context.Context, root,
 			&i.ISO,
 			&i.Latitude,
 			&i.Longitude,
+			&i.GPSSource,
 			&i.Rotation,
 			&i.Rating,
 			&i.Width,
 			&i.Height,
 			&i.Panorama,
+			&i.MediaType,
+			&i.Duration,
+			&i.VideoCodec,
 			&i.FocusX,
 			&i.FocusY,
 			&i.FocusMode,
@@ -1099,7 +1138,7 @@ func (q *Queries) QueryImageCoordByTagACL(ctx context.Context, tagID dbo.TagID,
 	out := make([]dbo.ImageCoord, 0)
 	for rows.Next() {
 		var i dbo.ImageCoord
-		if err := rows.Scan(&i.ID, &i.Title, &i.Latitude, &i.Longitude); err != nil {
+		if err := rows.Scan(&i.ID, &i.Title, &i.Latitude, &i.Longitude, &i.GPSSource); err != nil {
 			return nil, err
 		}
 		out = append(out, i)
@@ -1209,6 +1248,25 @@ func (q *Queries) CountImage(ctx context.Context) (uint64, error) {
 	return count, err
 }
 
+// QueryImageAfterID reads the next page of all images ordered by ID.
+//
+// Input:
+//   - ctx: request context.
+//   - after: last image ID of the previous page, 0 for the first page.
+//   - qty: maximum number of rows.
+//
+// Output:
+//   - []dbo.Image: images of the page.
+//   - error: query, scan, or row iteration error.
+func (q *Queries) QueryImageAfterID(ctx context.Context, after dbo.ImageID, qty uint64) ([]dbo.Image, error) {
+	rows, err := q.db.QueryContext(ctx, queryImageAfterID, after, qty)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return parseImageRows(rows)
+}
+
 // CountImageACLLevels counts images grouped by ACL level.
 //
 // Input:
@@ -1329,7 +1387,7 @@ func (q *Queries) QueryImageCoordByAlbumDescendantIDsByACL(ctx context.Context,
 	out := make([]dbo.ImageCoord, 0)
 	for rows.Next() {
 		var i dbo.ImageCoord
-		if err := rows.Scan(&i.ID, &i.Title, &i.Latitude, &i.Longitude); err != nil {
+		if err := rows.Scan(&i.ID, &i.Title, &i.Latitude, &i.Longitude, &i.GPSSource); err != nil {
 			return nil, err
 		}
 		out = append(out, i)
@@ -1351,7 +1409,7 @@ func (q *Queries) QueryImageCoordByAlbumRootByACL(ctx context.Context, acl dbo.A
 	out := make([]dbo.ImageCoord, 0)
 	for rows.Next() {
 		var i dbo.ImageCoord
-		if err := rows.Scan(&i.ID, &i.Title, &i.Latitude, &i.Longitude); err != nil {
+		if err := rows.Scan(&i.ID, &i.Title, &i.Latitude, &i.Longitude, &i.GPSSource); err != nil {
 			return nil, err
 		}
 		out = append(out, i)
@@ -2565,6 +2623,32 @@ func CountImage(db *sql.DB, c context.Context) (uint64, error) {
 	return qty, nil
 }
 
+// QueryImageAfterID reads the next page of all images ordered by ID with logging.
+//
+// Input:
+//   - db: database handle.
+//   - c: request context.
+//   - after: last image ID of the previous page, 0 for the first page.
+//   - qty: maximum number of rows.
+//
+// Output:
+//   - []dbo.Image: images of the page.
+//   - error: query, scan, or row iteration error.
+func QueryImageAfterID(db *sql.DB, c context.Context, after dbo.ImageID, qty uint64) ([]dbo.Image, error) {
+	logScope, ctx := logging.Enter(c, "dao/image/query/after", nil, map[string]any{
+		"after": after,
+		"qty":   qty,
+	})
+	q := NewQueries(db)
+	images, err := q.QueryImageAfterID(ctx, after, qty)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"found": len(images)})
+	return images, nil
+}
+
 // CountImageACLLevels counts images grouped by ACL level with logging.
 //
 // Input:
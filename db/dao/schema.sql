@@ -45,7 +45,11 @@ CREATE TABLE IF NOT EXISTS images (
     COMMENT 'SHA-256 hash of sidecar file',
 
   taken_at DATETIME NULL
-    COMMENT 'Photo capture timestamp (EXIF)',
+    COMMENT 'Photo capture timestamp (EXIF), UTC',
+  taken_at_local DATETIME NULL
+    COMMENT 'Photo capture timestamp in the local time of the capture',
+  taken_at_tz VARCHAR(64) NULL
+    COMMENT 'Capture timezone: offset (+02:00) or IANA name, NULL if unknown (taken_at is the wall clock)',
   camera VARCHAR(128) NULL 
     COMMENT 'Camera model',
   lens VARCHAR(128) NULL 
@@ -63,6 +67,8 @@ CREATE TABLE IF NOT EXISTS images (
     COMMENT 'GPS latitude (WGS84)',
   longitude DOUBLE NULL
     COMMENT 'GPS longitude (WGS84)',
+  gps_source ENUM('exif','gpx') NULL
+    COMMENT 'Origin of the coordinates: file metadata or GPX track correlation',
 
   rotation SMALLINT NULL
     COMMENT 'Image rotation / orientation',
@@ -76,6 +82,13 @@ CREATE TABLE IF NOT EXISTS images (
   panorama TINYINT NOT NULL
     COMMENT '1 if marked panorama',
 
+  media_type ENUM('image','video') NOT NULL DEFAULT 'image'
+    COMMENT 'Kind of the original file, derivatives of videos are poster frames',
+  duration DECIMAL(12,3) NULL
+    COMMENT 'Video length in seconds',
+  video_codec VARCHAR(64) NULL
+    COMMENT 'Video codec (e.g. avc1, hvc1)',
+
   title VARCHAR(255) NULL 
     COMMENT 'Human-readable image title',
   caption TEXT NULL 
@@ -188,6 +201,16 @@ CREATE TABLE IF NOT EXISTS albums (
     COMMENT 'Serialized dynamic rule tree defining album contents',
   rank INT NOT NULL DEFAULT 0 
     COMMENT 'Sibling ordering index within the same parent album',
+  sort_order VARCHAR(32) NOT NULL DEFAULT 'taken_at_asc'
+    COMMENT 'Image order inside the album (taken_at_asc, taken_at_desc, rating_desc, rating_asc, filename_asc, filename_desc, random_daily)',
+  image_limit INT UNSIGNED NULL
+    COMMENT 'Keep only the first N images by sort_order, NULL for no limit',
+  group_by VARCHAR(16) NOT NULL DEFAULT ''
+    COMMENT 'Generator album grouping key (tag, year, month, path), empty for normal albums',
+  group_param VARCHAR(255) NOT NULL DEFAULT ''
+    COMMENT 'Parameter of the grouping key: tag path or path segment index',
+  generator_key VARCHAR(255) NULL
+    COMMENT 'Key value of a generated child album, NULL for normal albums',
   cover_image_id BIGINT UNSIGNED NULL 
     COMMENT 'Optional fixed cover image overriding automatic selection',
   acl_level INT NOT NULL DEFAULT 0
@@ -199,6 +222,7 @@ CREATE TABLE IF NOT EXISTS albums (
   FOREIGN KEY (parent_id) REFERENCES albums(id) ON DELETE CASCADE,
   FOREIGN KEY (cover_image_id) REFERENCES images(id) ON DELETE SET NULL,
 
+  UNIQUE KEY uniq_albums_generator (parent_id, generator_key),
   INDEX idx_albums_parent (parent_id),
   INDEX idx_albums_acl (acl_level, acl_user_id),
   INDEX idx_albumc_cover (cover_image_id)
@@ -216,6 +240,8 @@ CREATE TABLE IF NOT EXISTS album_images (
 
   position INT UNSIGNED NULL 
     COMMENT 'Optional ordering position inside album',
+  over_limit BOOLEAN NOT NULL DEFAULT FALSE
+    COMMENT 'Matches the album rule but is after the album image limit, hidden from the album',
   computed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     COMMENT 'Timestamp when album rules were evaluated',
 
@@ -242,8 +268,8 @@ CREATE TABLE IF NOT EXISTS tags (
   parent_id BIGINT
     COMMENT 'Parent tag ID for hierarchy, 0 for root items',
 
-  source ENUM('digikam') NOT NULL DEFAULT 'digikam'
-    COMMENT 'Origin of the tag taxonomy',
+  source ENUM('digikam','geocode','override') NOT NULL DEFAULT 'digikam'
+    COMMENT 'Origin of the tag taxonomy (geocode: generated location tags, override: sync overrides)',
 
   UNIQUE KEY uniq_parent_name (parent_id, name),
   INDEX idx_tags_name (name)
@@ -267,6 +293,23 @@ CREATE TABLE IF NOT EXISTS image_tags (
   INDEX idx_image_tags_tag (tag_id, image_id)
 ) ENGINE=InnoDB COMMENT='Assignment of tags to images';
 
+-- =========================================================
+-- COLOR WARNINGS
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS color_warnings (
+  image_id BIGINT UNSIGNED NOT NULL PRIMARY KEY
+    COMMENT 'Referenced image ID',
+  profile VARCHAR(255) NOT NULL
+    COMMENT 'Description of the embedded ICC profile',
+  message VARCHAR(1000) NOT NULL
+    COMMENT 'Why the profile was not converted to sRGB',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+    COMMENT 'Time of the last failed derivative generation',
+
+  FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE
+) ENGINE=InnoDB COMMENT='Images whose derivatives are not color managed';
+
 -- =========================================================
 -- SYNC RUNS
 -- =========================================================
@@ -290,6 +333,13 @@ CREATE TABLE IF NOT EXISTS sync_runs (
     COMMENT 'Number of images observed during sync',
   total_deleted INT UNSIGNED NOT NULL DEFAULT 0
     COMMENT 'Number of images removed during sync',
+
+  derivatives_queued INT UNSIGNED NOT NULL DEFAULT 0
+    COMMENT 'Pregenerated derivative jobs submitted (one per image)',
+  derivatives_done INT UNSIGNED NOT NULL DEFAULT 0
+    COMMENT 'Pregenerated derivative jobs finished',
+  derivatives_failed INT UNSIGNED NOT NULL DEFAULT 0
+    COMMENT 'Pregenerated derivative jobs failed',
     
   status ENUM('running','finished','failed') NOT NULL DEFAULT 'running'
     COMMENT 'Final execution status of the sync run',
@@ -322,6 +372,7 @@ CREATE TABLE IF NOT EXISTS sync_files (
 
   status VARCHAR(50) NOT NULL,
   dirty_reason VARCHAR(64) NULL,
+  gps_track VARCHAR(600) NULL,
 
   ruleresults_json   JSON NULL,
 
@@ -334,4 +385,4 @@ CREATE TABLE IF NOT EXISTS sync_files (
   CONSTRAINT fk_sync_files_run
     FOREIGN KEY (sync_id) REFERENCES sync_runs(id)
     ON DELETE CASCADE
-) ENGINE=InnoDB ;
\ No newline at end of file
+) ENGINE=InnoDB ;
\ No newline at end of file
@@ -8,7 +8,7 @@ import (
 	"github.com/ignisVeneficus/lumenta/db/dbo"
 )
 
-const syncRunFields = `s.id, s.is_active, s.started_at, s.finished_at, s.mode, s.total_seen, s.total_deleted, s.status, s.error, s.meta_hash `
+const syncRunFields = `s.id, s.is_active, s.started_at, s.finished_at, s.mode, s.total_seen, s.total_deleted, s.derivatives_queued, s.derivatives_done, s.derivatives_failed, s.status, s.error, s.meta_hash `
 
 const createSyncRun = `INSERT INTO sync_runs (started_at, mode, meta_hash) VALUES (NOW(), ?, ?)`
 
@@ -20,6 +20,12 @@ const closeSyncRunSuccess = `UPDATE sync_runs SET
   is_active = null
 WHERE id = ?`
 
+const updateSyncRunDerivatives = `UPDATE sync_runs SET
+  derivatives_queued = ?,
+  derivatives_done = ?,
+  derivatives_failed = ?
+WHERE id = ?`
+
 const closeSyncRunError = `UPDATE sync_runs
 SET
   finished_at = NOW(),
@@ -28,7 +34,7 @@ SET
   is_active = null
 WHERE id = ?`
 
-const getSyncRunLastHash = "SELECT meta_hash FROM sync_runs WHERE status='finished' ORDER BY started_at desc LIMIT 1"
+const getSyncRunLastHash = "SELECT meta_hash FROM sync_runs WHERE status='finished' AND mode <> 'refresh' ORDER BY started_at desc LIMIT 1"
 
 const getSyncRunByID = `SELECT ` + syncRunFields + ` FROM sync_runs s WHERE s.id = ?`
 
@@ -51,7 +57,7 @@ WHERE
 
 func parseSyncRunRow(row *sql.Row) (dbo.SyncRun, error) {
 	var s dbo.SyncRun
-	err := row.Scan(&s.ID, &s.IsActive, &s.StartedAt, &s.FinishedAt, &s.Mode, &s.TotalSeen, &s.TotalDeleted, &s.Status, &s.Error, &s.MetaHash)
+	err := row.Scan(&s.ID, &s.IsActive, &s.StartedAt, &s.FinishedAt, &s.Mode, &s.TotalSeen, &s.TotalDeleted, &s.DerivativesQueued, &s.DerivativesDone, &s.DerivativesFailed, &s.Status, &s.Error, &s.MetaHash)
 	return s, err
 }
 
@@ -59,7 +65,7 @@ func parseSyncRunRows(rows *sql.Rows) ([]dbo.SyncRun, error) {
 	out := make([]dbo.SyncRun, 0)
 	for rows.Next() {
 		var s dbo.SyncRun
-		err := rows.Scan(&s.ID, &s.IsActive, &s.StartedAt, &s.FinishedAt, &s.Mode, &s.TotalSeen, &s.TotalDeleted, &s.Status, &s.Error, &s.MetaHash)
+		err := rows.Scan(&s.ID, &s.IsActive, &s.StartedAt, &s.FinishedAt, &s.Mode, &s.TotalSeen, &s.TotalDeleted, &s.DerivativesQueued, &s.DerivativesDone, &s.DerivativesFailed, &s.Status, &s.Error, &s.MetaHash)
 		if err != nil {
 			return nil, err
 		}
@@ -83,6 +89,11 @@ func (q *Queries) CloseSyncRunSuccess(ctx context.Context, syncRunID dbo.SyncRun
 	return err
 }
 
+func (q *Queries) UpdateSyncRunDerivatives(ctx context.Context, syncRunID dbo.SyncRunID, queued, done, failed uint64) error {
+	_, err := q.db.ExecContext(ctx, updateSyncRunDerivatives, queued, done, failed, syncRunID)
+	return err
+}
+
 func (q *Queries) CloseSyncRunError(ctx context.Context, syncRunID dbo.SyncRunID, errorMsg string) error {
 	_, err := q.db.ExecContext(ctx, closeSyncRunError, errorMsg, syncRunID)
 	return err
@@ -165,6 +176,17 @@ func CloseSyncRunSuccess(db *sql.DB, c context.Context, syncRunID dbo.SyncRunID,
 	}
 	return logScope.Return(tx.Commit())
 }
+func UpdateSyncRunDerivatives(db *sql.DB, c context.Context, syncRunID dbo.SyncRunID, queued, done, failed uint64) error {
+	logScope, ctx := logging.Enter(c, "dao/sync_run/update/derivatives", syncRunID, map[string]any{
+		"sync_id": syncRunID,
+		"queued":  queued,
+		"done":    done,
+		"failed":  failed,
+	})
+	q := NewQueries(db)
+	err := q.UpdateSyncRunDerivatives(ctx, syncRunID, queued, done, failed)
+	return logScope.Return(err)
+}
 func CloseSyncRunError(db *sql.DB, c context.Context, syncRunID dbo.SyncRunID, errorMsg string) error {
 	logScope, ctx := logging.Enter(c, "dao/sync_run/update/close/error", syncRunID, map[string]any{"sync_id": syncRunID, "error": errorMsg})
 	tx, err := GetTx(db, ctx)
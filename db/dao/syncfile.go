@@ -13,7 +13,7 @@ import (
 const syncFileFields = `
 f.id, f.sync_id,
 f.root, f.path, f.filename, f.ext,
-f.status, f.dirty_reason,
+f.status, f.dirty_reason, f.gps_track,
 f.ruleresults_json,
 f.created_at
 `
@@ -22,9 +22,9 @@ const createSyncFile = `
 INSERT INTO sync_files (
   sync_id,
   root, path, filename, ext,
-  status, dirty_reason,
+  status, dirty_reason, gps_track,
   ruleresults_json
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 const getSyncFileById = `SELECT ` + syncFileFields + ` FROM sync_files f WHERE f.id = ?`
 
@@ -126,6 +126,7 @@ func parseSyncFile(row *sql.Row) (dbo.SyncFile, error) {
 
 		&f.Status,
 		&f.DirtyReason,
+		&f.GPSTrack,
 
 		&f.RuleResultsJSON,
 
@@ -149,6 +150,7 @@ func parseSyncFiles(rows *sql.Rows) ([]dbo.SyncFile, error) {
 
 			&f.Status,
 			&f.DirtyReason,
+			&f.GPSTrack,
 
 			&f.RuleResultsJSON,
 
@@ -166,7 +168,7 @@ func (q *Queries) CreateSyncFile(ctx context.Context, f dbo.SyncFile) error {
 	_, err := q.db.ExecContext(ctx, createSyncFile,
 		f.SyncID,
 		f.Root, f.Path, f.Filename, f.Ext,
-		f.Status, f.DirtyReason,
+		f.Status, f.DirtyReason, f.GPSTrack,
 		f.RuleResultsJSON,
 	)
 	return err
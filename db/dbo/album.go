@@ -13,6 +13,72 @@ type AlbumID uint64
 
 type AncestorIDs []AlbumID
 
+// AlbumSort is the image order inside an album, the positions are computed
+// at the end of the sync.
+type AlbumSort string
+
+const (
+	AlbumSortTakenAtAsc   AlbumSort = "taken_at_asc"
+	AlbumSortTakenAtDesc  AlbumSort = "taken_at_desc"
+	AlbumSortRatingDesc   AlbumSort = "rating_desc"
+	AlbumSortRatingAsc    AlbumSort = "rating_asc"
+	AlbumSortFilenameAsc  AlbumSort = "filename_asc"
+	AlbumSortFilenameDesc AlbumSort = "filename_desc"
+	// shuffled, the order changes once a day (on the next sync)
+	AlbumSortRandomDaily AlbumSort = "random_daily"
+)
+
+var AlbumSorts = []AlbumSort{
+	AlbumSortTakenAtAsc,
+	AlbumSortTakenAtDesc,
+	AlbumSortRatingDesc,
+	AlbumSortRatingAsc,
+	AlbumSortFilenameAsc,
+	AlbumSortFilenameDesc,
+	AlbumSortRandomDaily,
+}
+
+// AlbumGroupBy is the grouping key of a generator album: the images of the
+// album are split into generated child albums, one per key value.
+type AlbumGroupBy string
+
+const (
+	AlbumGroupByNone AlbumGroupBy = ""
+	// children of the tag in GroupParam (tag path)
+	AlbumGroupByTag AlbumGroupBy = "tag"
+	// year / year-month of taken_at (local time)
+	AlbumGroupByYear  AlbumGroupBy = "year"
+	AlbumGroupByMonth AlbumGroupBy = "month"
+	// folder of the path, GroupParam is the index (negative from the end)
+	AlbumGroupByPath AlbumGroupBy = "path"
+)
+
+var AlbumGroupBys = []AlbumGroupBy{
+	AlbumGroupByNone,
+	AlbumGroupByTag,
+	AlbumGroupByYear,
+	AlbumGroupByMonth,
+	AlbumGroupByPath,
+}
+
+func ValidateAlbumGroupBy(groupBy AlbumGroupBy) bool {
+	for _, g := range AlbumGroupBys {
+		if g == groupBy {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidateAlbumSort(sort AlbumSort) bool {
+	for _, s := range AlbumSorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
 type Album struct {
 	ID          *AlbumID
 	ParentID    *AlbumID
@@ -21,6 +87,15 @@ type Album struct {
 
 	Rank uint64
 
+	Sort AlbumSort
+	// only the first Limit images by Sort are shown, nil: no limit
+	Limit *uint32
+
+	GroupBy    AlbumGroupBy
+	GroupParam string
+	// key of a generated child album, nil for normal albums
+	GeneratorKey *string
+
 	AncestorIDs  AncestorIDs
 	RuleJSON     json.RawMessage
 	CoverImageID *ImageID
@@ -33,13 +108,29 @@ type Album struct {
 	Images []Image
 }
 
+// AlbumBinding is an album-image relation as the sync sees it: the images
+// after the album limit stay bound, but they are not in the album.
+type AlbumBinding struct {
+	AlbumID   AlbumID
+	OverLimit bool
+}
+
 func (a *Album) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Level) {
 	if level <= zerolog.DebugLevel {
 		e.Str(string(definitions.AlbumFieldName), a.Name).
+			Str(string(definitions.AlbumFieldSort), string(a.Sort)).
 			Uint64(string(definitions.AlbumFieldACLLevel), uint64(a.ACLLevel)).
 			Uint64(string(definitions.AlbumFieldACLUserID), uint64(a.ACLUserID))
 		logging.Uint64If(e, string(definitions.AlbumFieldParentID), (*uint64)(a.ParentID))
 		logging.Uint64If(e, string(definitions.AlbumFieldID), (*uint64)(a.ID))
+		if a.Limit != nil {
+			e.Uint32(string(definitions.AlbumFieldLimit), *a.Limit)
+		}
+		if a.GroupBy != AlbumGroupByNone {
+			e.Str(string(definitions.AlbumFieldGroupBy), string(a.GroupBy)).
+				Str(string(definitions.AlbumFieldGroupParam), a.GroupParam)
+		}
+		logging.StrIf(e, string(definitions.AlbumFieldGeneratorKey), a.GeneratorKey)
 	}
 	if level == zerolog.TraceLevel {
 		e.RawJSON(string(definitions.AlbumFieldRuleJSON), a.RuleJSON).
@@ -73,6 +164,19 @@ func (a *Album) AncestorPrefixJSON() string {
 	return string(b[:len(b)-1])
 }
 
+// IsGenerated reports whether the album is a generated child of a generator album.
+func (a *Album) IsGenerated() bool {
+	return a.GeneratorKey != nil
+}
+
+// GetSort returns the image order of the album, taken_at_asc if not set.
+func (a *Album) GetSort() AlbumSort {
+	if a.Sort == "" {
+		return AlbumSortTakenAtAsc
+	}
+	return a.Sort
+}
+
 func (a *Album) GetSorting() string {
 	return a.Name
 }
@@ -58,8 +58,11 @@ const (
 	SyncModeFull        SyncMode = "full"
 	SyncModeIncremental SyncMode = "incremental"
 	SyncModePartial     SyncMode = "partial"
+	SyncModeRefresh     SyncMode = "refresh" // album refresh without the filesystem
 
-	TagSourceDigikam TagSource = "digikam"
+	TagSourceDigikam  TagSource = "digikam"
+	TagSourceGeocode  TagSource = "geocode"
+	TagSourceOverride TagSource = "override"
 
 	DBACLLevelPublic        DBACLLevel = 0
 	DBACLLevelAuthenticated DBACLLevel = 1
@@ -144,9 +147,13 @@ type SyncRun struct {
 	Mode         SyncMode
 	TotalSeen    uint32
 	TotalDeleted uint32
-	Status       SyncStatus
-	Error        *string
-	MetaHash     *string
+	// pregenerated derivative jobs
+	DerivativesQueued uint32
+	DerivativesDone   uint32
+	DerivativesFailed uint32
+	Status            SyncStatus
+	Error             *string
+	MetaHash          *string
 }
 
 func (s *SyncRun) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Level) {
@@ -158,6 +165,9 @@ func (s *SyncRun) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.
 			Str("mode", string(s.Mode)).
 			Uint32("total_seen", s.TotalSeen).
 			Uint32("total_deleted", s.TotalDeleted).
+			Uint32("derivatives_queued", s.DerivativesQueued).
+			Uint32("derivatives_done", s.DerivativesDone).
+			Uint32("derivatives_failed", s.DerivativesFailed).
 			Str("status", string(s.Status))
 
 		logging.TimeIf(e, "finished_at", s.FinishedAt)
@@ -202,6 +212,7 @@ type SyncFile struct {
 
 	Status      SyncFileStatus
 	DirtyReason *string
+	GPSTrack    *string
 
 	RuleResultsJSON []byte
 
@@ -220,6 +231,7 @@ func (s *SyncFile) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog
 			Str("status", string(s.Status))
 
 		logging.StrIf(e, "dirty_reason", s.DirtyReason)
+		logging.StrIf(e, "gps_track", s.GPSTrack)
 
 		e.Time("created_at", s.CreatedAt)
 
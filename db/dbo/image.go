@@ -15,6 +15,8 @@ type ImageID uint64
 
 type ValueSource string
 type ImageFocusMode string
+type GPSSource string
+type MediaType string
 
 var (
 	ValueSourceFilesystem ValueSource = "filesystem"
@@ -27,6 +29,12 @@ var (
 	ImageFocusModeBottom ImageFocusMode = "bottom"
 	ImageFocusModeLeft   ImageFocusMode = "left"
 	ImageFocusModeRight  ImageFocusMode = "right"
+
+	GPSSourceExif GPSSource = "exif"
+	GPSSourceGPX  GPSSource = "gpx"
+
+	MediaTypeImage MediaType = "image"
+	MediaTypeVideo MediaType = "video"
 )
 
 type Image struct {
@@ -45,7 +53,10 @@ type Image struct {
 	Title   *string
 	Caption *string
 
-	TakenAt     *time.Time
+	TakenAt      *time.Time // UTC
+	TakenAtLocal *time.Time // wall clock of the capture place
+	TakenAtTZ    *string    // offset (+02:00) or IANA name of the capture timezone
+
 	Camera      *string
 	Lens        *string
 	FocalLength *float32
@@ -55,6 +66,7 @@ type Image struct {
 
 	Latitude  *float64
 	Longitude *float64
+	GPSSource *GPSSource
 
 	Rotation *int16
 	Rating   *uint16
@@ -64,6 +76,10 @@ type Image struct {
 
 	Panorama int8
 
+	MediaType  MediaType
+	Duration   *float64 // video length in seconds
+	VideoCodec *string
+
 	FocusX      *float32
 	FocusY      *float32
 	FocusMode   ImageFocusMode
@@ -89,6 +105,9 @@ func (i Image) GetTitle() string {
 	}
 	return i.Filename
 }
+func (i Image) IsVideo() bool {
+	return i.MediaType == MediaTypeVideo
+}
 func (i Image) PathFull() string {
 	return BuildFullPath(i.Root, i.Path, i.Filename, i.Ext)
 }
@@ -116,6 +135,7 @@ func (i *Image) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Le
 			Uint32("width", i.Width).
 			Uint32("height", i.Height).
 			Int8("panorama", i.Panorama).
+			Str("media_type", string(i.MediaType)).
 			Str("focus_source", string(i.FocusSource)).
 			Str("focus_mode", string(i.FocusMode))
 
@@ -156,6 +176,19 @@ type ImageCoord struct {
 	Title     string
 	Latitude  *float64
 	Longitude *float64
+	GPSSource *GPSSource
 }
 
 type ImageACLCount map[DBACLLevel]uint64
+
+// ColorWarning: the derivatives of the image are not converted to sRGB.
+type ColorWarning struct {
+	ImageID   ImageID
+	Root      string
+	Path      string
+	Filename  string
+	Ext       string
+	Profile   string
+	Message   string
+	CreatedAt time.Time
+}
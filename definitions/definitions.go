@@ -16,6 +16,11 @@ const (
 	AlbumFieldACLLevel        FieldName = "acl_level"
 	AlbumFieldACLUserID       FieldName = "acl_user_id"
 	AlbumFieldUpdatedAt       FieldName = "updated_at"
+	AlbumFieldSort            FieldName = "sort_order"
+	AlbumFieldLimit           FieldName = "image_limit"
+	AlbumFieldGroupBy         FieldName = "group_by"
+	AlbumFieldGroupParam      FieldName = "group_param"
+	AlbumFieldGeneratorKey    FieldName = "generator_key"
 
 	ImageFieldID           FieldName = "id"
 	ImageFieldRoot         FieldName = "root"
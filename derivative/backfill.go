@@ -0,0 +1,57 @@
+package derivative
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ignisVeneficus/logging"
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
+	fsConfig "github.com/ignisVeneficus/lumenta/config/filesystem"
+	"github.com/ignisVeneficus/lumenta/db/dao"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
+const backfillPageSize uint64 = 500
+
+// Backfill generates the derivatives of every image in the database.
+// missing: only the derivatives without a file, otherwise all are regenerated.
+// progress, if given, is called periodically while the jobs run.
+func Backfill(c context.Context, database *sql.DB, cfgs []derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, missing bool, every time.Duration, progress func(BatchStats)) (BatchStats, []Result, error) {
+	logScope, ctx := logging.Enter(c, "derivative/backfill", nil, map[string]any{"missing": missing})
+	batch := Get().NewBatch()
+	defer batch.Close()
+	var after dbo.ImageID
+	for {
+		images, err := dao.QueryImageAfterID(database, ctx, after, backfillPageSize)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return batch.Stats(), nil, err
+		}
+		for _, img := range images {
+			job, ok, err := ImageJob(ctx, img, cfgs, roots, missing)
+			if err != nil {
+				logging.ErrorContinue(logScope, err, map[string]any{"image_id": img.ID})
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if err := batch.Submit(job); err != nil {
+				logging.ExitErr(logScope, err)
+				return batch.Stats(), nil, err
+			}
+		}
+		if uint64(len(images)) < backfillPageSize {
+			break
+		}
+		after = *images[len(images)-1].ID
+	}
+	stats, err := batch.Wait(ctx, every, progress)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return stats, batch.Failures(), err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"stats": &stats})
+	return stats, batch.Failures(), nil
+}
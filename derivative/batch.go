@@ -0,0 +1,169 @@
+package derivative
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// BatchStats is the progress of a batch.
+type BatchStats struct {
+	Queued  uint64 // submitted jobs
+	Done    uint64 // finished without error
+	Failed  uint64
+	Skipped uint64 // already queued by someone else
+}
+
+func (b BatchStats) Finished() bool {
+	return b.Done+b.Failed >= b.Queued
+}
+
+func (b *BatchStats) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Level) {
+	e.Uint64("queued", b.Queued).
+		Uint64("done", b.Done).
+		Uint64("failed", b.Failed).
+		Uint64("skipped", b.Skipped)
+}
+
+// Batch submits jobs to the service and counts their results,
+// used by the sync pregeneration and the backfill command.
+// Close must be called on every path, it ends the collector.
+type Batch struct {
+	service *Service
+	results chan Result
+	slots   chan struct{} // the jobs not collected yet, at most the results buffer
+	stop    chan struct{}
+	closed  sync.Once
+	pending sync.WaitGroup
+
+	queued  atomic.Uint64
+	done    atomic.Uint64
+	failed  atomic.Uint64
+	skipped atomic.Uint64
+
+	mu       sync.Mutex
+	failures []Result
+}
+
+// maxBatchFailures: the failed results kept for reporting
+const maxBatchFailures = 100
+
+// batchResults: the results buffer of a batch, the workers never wait on it
+const batchResults = 64
+
+func (s *Service) NewBatch() *Batch {
+	b := &Batch{
+		service: s,
+		results: make(chan Result, batchResults),
+		slots:   make(chan struct{}, batchResults),
+		stop:    make(chan struct{}),
+	}
+	go b.collect()
+	return b
+}
+
+// collect counts the results until the batch is closed. The results channel
+// is never closed, the workers may still send the results of queued jobs.
+func (b *Batch) collect() {
+	for {
+		var r Result
+		select {
+		case r = <-b.results:
+		case <-b.stop:
+			return
+		}
+		if r.Err != nil {
+			b.failed.Add(1)
+			b.mu.Lock()
+			if len(b.failures) < maxBatchFailures {
+				b.failures = append(b.failures, r)
+			}
+			b.mu.Unlock()
+		} else {
+			b.done.Add(1)
+		}
+		<-b.slots
+		b.pending.Done()
+	}
+}
+
+// Submit queues the job in bulk priority, waiting while the queue is full or
+// the results buffer could fill up. A job already queued by someone else is skipped.
+func (b *Batch) Submit(j Job) error {
+	select {
+	case b.slots <- struct{}{}:
+	case <-b.stop:
+		return ErrClosed
+	}
+	j.Done = b.results
+	j.Priority = PriorityBulk
+	b.pending.Add(1)
+	if _, err := b.service.Submit(j); err != nil {
+		<-b.slots
+		b.pending.Done()
+		if errors.Is(err, ErrDuplicate) {
+			b.skipped.Add(1)
+			return nil
+		}
+		return err
+	}
+	b.queued.Add(1)
+	return nil
+}
+
+func (b *Batch) Stats() BatchStats {
+	return BatchStats{
+		Queued:  b.queued.Load(),
+		Done:    b.done.Load(),
+		Failed:  b.failed.Load(),
+		Skipped: b.skipped.Load(),
+	}
+}
+
+// Failures returns the first failed results.
+func (b *Batch) Failures() []Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Result(nil), b.failures...)
+}
+
+// Close ends the collector, the results of the jobs still running are not
+// counted anymore. Safe to call more than once.
+func (b *Batch) Close() {
+	b.closed.Do(func() {
+		close(b.stop)
+	})
+}
+
+// Wait blocks until every submitted job is finished or the context is done.
+// progress, if given, is called periodically with the current state.
+// No job may be submitted after Wait.
+func (b *Batch) Wait(c context.Context, every time.Duration, progress func(BatchStats)) (BatchStats, error) {
+	finished := make(chan struct{})
+	go func() {
+		b.pending.Wait()
+		close(finished)
+	}()
+	var tick <-chan time.Time
+	if progress != nil && every > 0 {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-finished:
+			b.Close()
+			return b.Stats(), nil
+		case <-tick:
+			progress(b.Stats())
+		case <-c.Done():
+			// the workers still send the results of the queued jobs
+			return b.Stats(), c.Err()
+		}
+	}
+}
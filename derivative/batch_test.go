@@ -0,0 +1,90 @@
+package derivative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBatchSlots(t *testing.T) {
+	release := make(chan struct{})
+	s := NewService(func(j *Job) error {
+		<-release
+		if j.Image%2 == 0 {
+			return errors.New("broken")
+		}
+		return nil
+	}, 2, 0)
+	runService(t, s)
+
+	b := s.NewBatch()
+	defer b.Close()
+	for i := 0; i < batchResults; i++ {
+		if err := b.Submit(testJob(Key(fmt.Sprint(i)), PriorityInteractive, uint64(i))); err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+	}
+
+	// every slot is taken, the next submit waits for a collected result
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- b.Submit(testJob("last", PriorityBulk, batchResults+1))
+	}()
+	select {
+	case err := <-submitted:
+		t.Fatalf("submit did not wait for a slot: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-submitted; err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	stats, err := b.Wait(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if stats.Queued != batchResults+1 || stats.Done != batchResults/2+1 || stats.Failed != batchResults/2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if len(b.Failures()) != batchResults/2 {
+		t.Fatalf("expected %d failures, got %d", batchResults/2, len(b.Failures()))
+	}
+	if s.Status().Queued[PriorityInteractive] != 0 {
+		t.Fatalf("batch jobs not submitted in bulk priority")
+	}
+}
+
+func TestBatchDuplicateSkipped(t *testing.T) {
+	s := NewService(func(*Job) error { return nil }, 1, 0)
+	if _, err := s.Submit(testJob("a", PriorityInteractive, 1)); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	b := s.NewBatch()
+	defer b.Close()
+	if err := b.Submit(testJob("a", PriorityBulk, 1)); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if stats := b.Stats(); stats.Skipped != 1 || stats.Queued != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	// the skipped job released its slot
+	if len(b.slots) != 0 {
+		t.Fatalf("slot not released")
+	}
+}
+
+func TestBatchClosed(t *testing.T) {
+	s := NewService(func(*Job) error { return nil }, 1, 0)
+	b := s.NewBatch()
+	b.Close()
+	b.Close()
+	for i := 0; i < batchResults; i++ {
+		b.slots <- struct{}{}
+	}
+	if err := b.Submit(testJob("a", PriorityBulk, 1)); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
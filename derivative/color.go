@@ -0,0 +1,181 @@
+package derivative
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/config"
+	"github.com/ignisVeneficus/lumenta/db"
+	"github.com/ignisVeneficus/lumenta/db/dao"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
+const DecodeColor DecodePath = "color"
+
+// maxWarningMessage: the size of color_warnings.message
+const maxWarningMessage = 1000
+
+// sourceProfile returns the description of the embedded ICC profile, empty if none.
+func sourceProfile(c context.Context, exiftool string, path string) (string, error) {
+	raw, err := runCommand(c, previewTimeout, exiftool, "-s3", "-ICC_Profile:ProfileDescription", path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// isSRGB: untagged images are sRGB for the browsers too.
+func isSRGB(profile string) bool {
+	return profile == "" || strings.Contains(strings.ToLower(strings.ReplaceAll(profile, " ", "")), "srgb")
+}
+
+// decodeManaged decodes the original in sRGB: an image with another embedded
+// profile is converted by the configured tool. Without it, or if it fails, the
+// image is decoded as it is and a warning is recorded for the admins.
+// Without color management the profile is not probed at all.
+func decodeManaged(c context.Context, j *Job) (image.Image, DecodePath, error) {
+	logScope, ctx := logging.Enter(c, "service/derivative/task/color", j.SourcePath, map[string]any{"path": j.SourcePath})
+	cfg := config.Global()
+
+	if !cfg.Decoder.Color.Enabled() {
+		img, decoder, err := decodeImage(ctx, j.SourcePath)
+		logScope.Return(err)
+		return img, decoder, err
+	}
+
+	profile, profileErr := sourceProfile(ctx, cfg.Sync.Exiftool.ResolvedPath, j.SourcePath)
+	if profileErr != nil {
+		// without the profile the image is handled as untagged
+		logging.ErrorContinue(logScope, profileErr, nil)
+	}
+	if isSRGB(profile) {
+		img, decoder, err := decodeImage(ctx, j.SourcePath)
+		if err == nil && profileErr == nil {
+			// the warning of an earlier profile or setting is stale
+			clearColorWarning(logScope, ctx, j.Image)
+		}
+		logScope.Return(err)
+		return img, decoder, err
+	}
+
+	conv := cfg.Decoder.Color.Convert
+	if conv == nil {
+		warnColor(logScope, ctx, j.Image, profile, errors.New("no color converter configured"))
+		img, decoder, err := decodeImage(ctx, j.SourcePath)
+		logScope.Return(err)
+		return img, decoder, err
+	}
+	img, err := decodeExternal(ctx, conv.Command, conv.Args, j.SourcePath, conv.Timeout)
+	if err != nil {
+		warnColor(logScope, ctx, j.Image, profile, err)
+		img, decoder, err := decodeImage(ctx, j.SourcePath)
+		logScope.Return(err)
+		return img, decoder, err
+	}
+	clearColorWarning(logScope, ctx, j.Image)
+	logging.Exit(logScope, "ok", map[string]any{"profile": profile, "decoder": DecodeColor})
+	return img, DecodeColor, nil
+}
+
+func clearColorWarning(logScope logging.LogScope, c context.Context, imageID uint64) {
+	if err := dao.DeleteColorWarning(db.GetDatabase(), c, dbo.ImageID(imageID)); err != nil {
+		logging.ErrorContinue(logScope, err, nil)
+	}
+}
+
+func warnColor(logScope logging.LogScope, c context.Context, imageID uint64, profile string, cause error) {
+	logging.ErrorContinue(logScope, cause, map[string]any{"profile": profile})
+	msg := cause.Error()
+	if len(msg) > maxWarningMessage {
+		msg = strings.ToValidUTF8(msg[:maxWarningMessage], "")
+	}
+	if err := dao.UpsertColorWarning(db.GetDatabase(), c, dbo.ImageID(imageID), profile, msg); err != nil {
+		logging.ErrorContinue(logScope, err, nil)
+	}
+}
+
+// encodeJPEG writes the jpeg, tagged with the sRGB profile.
+func encodeJPEG(w io.Writer, img image.Image, quality int) error {
+	icc := config.Global().Decoder.Color.SRGBProfileData
+	if len(icc) == 0 {
+		return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(quality))
+	}
+	var b bytes.Buffer
+	if err := imaging.Encode(&b, img, imaging.JPEG, imaging.JPEGQuality(quality)); err != nil {
+		return err
+	}
+	tagged, err := embedICC(b.Bytes(), icc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(tagged)
+	return err
+}
+
+// iccChunk: the profile data of one APP2 segment, 65535 - length - "ICC_PROFILE\0" - sequence and count
+const iccChunk = 65535 - 2 - 12 - 2
+
+// embedICC inserts the profile into the jpeg as APP2 segments after the SOI marker.
+func embedICC(jpeg []byte, icc []byte) ([]byte, error) {
+	if len(jpeg) < 2 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return nil, errors.New("not a jpeg")
+	}
+	count := (len(icc) + iccChunk - 1) / iccChunk
+	if count > 255 {
+		return nil, fmt.Errorf("icc profile too large: %d bytes", len(icc))
+	}
+	var b bytes.Buffer
+	b.Grow(len(jpeg) + len(icc) + count*18)
+	b.Write(jpeg[:2])
+	for i := 0; i < count; i++ {
+		chunk := icc[i*iccChunk : min((i+1)*iccChunk, len(icc))]
+		size := 2 + 12 + 2 + len(chunk)
+		b.Write([]byte{0xFF, 0xE2, byte(size >> 8), byte(size)})
+		b.WriteString("ICC_PROFILE\x00")
+		b.Write([]byte{byte(i + 1), byte(count)})
+		b.Write(chunk)
+	}
+	b.Write(jpeg[2:])
+	return b.Bytes(), nil
+}
+
+// pngHeaderEnd: the signature and the IHDR chunk, always the first one
+const pngHeaderEnd = 8 + 4 + 4 + 13 + 4
+
+// embedPNGICC inserts the profile into the png as an iCCP chunk after IHDR.
+// The png is the input of the external encoders, they carry the profile over.
+func embedPNGICC(png []byte, icc []byte) ([]byte, error) {
+	if len(png) < pngHeaderEnd || !bytes.HasPrefix(png, []byte("\x89PNG\r\n\x1a\n")) || string(png[12:16]) != "IHDR" {
+		return nil, errors.New("not a png")
+	}
+	var data bytes.Buffer
+	// profile name, null separator, compression method 0 (zlib)
+	data.WriteString("sRGB\x00\x00")
+	zw := zlib.NewWriter(&data)
+	if _, err := zw.Write(icc); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.Grow(len(png) + data.Len() + 12)
+	b.Write(png[:pngHeaderEnd])
+	chunk := append([]byte("iCCP"), data.Bytes()...)
+	_ = binary.Write(&b, binary.BigEndian, uint32(data.Len()))
+	b.Write(chunk)
+	_ = binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	b.Write(png[pngHeaderEnd:])
+	return b.Bytes(), nil
+}
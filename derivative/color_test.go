@@ -0,0 +1,137 @@
+package derivative
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// iccSegments reads the ICC_PROFILE APP2 segments of a jpeg, in file order.
+func iccSegments(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var ret [][]byte
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF && data[i+1] != 0xDA; {
+		size := int(data[i+2])<<8 | int(data[i+3])
+		if i+2+size > len(data) {
+			t.Fatalf("segment at %d overruns the file", i)
+		}
+		payload := data[i+4 : i+2+size]
+		if data[i+1] == 0xE2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")) {
+			ret = append(ret, payload[12:])
+		}
+		i += 2 + size
+	}
+	return ret
+}
+
+func TestEmbedICC(t *testing.T) {
+	var src bytes.Buffer
+	if err := jpeg.Encode(&src, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	profile := func(size int) []byte {
+		p := make([]byte, size)
+		for i := range p {
+			p[i] = byte(i % 251)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		icc    []byte
+		chunks int
+	}{
+		{"small profile", profile(560), 1},
+		{"exactly one chunk", profile(iccChunk), 1},
+		{"split profile", profile(iccChunk*2 + 10), 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := embedICC(src.Bytes(), tc.icc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.HasPrefix(out, []byte{0xFF, 0xD8, 0xFF, 0xE2}) {
+				t.Fatalf("profile is not right after SOI: % x", out[:4])
+			}
+			segments := iccSegments(t, out)
+			if len(segments) != tc.chunks {
+				t.Fatalf("expected %d segments, got %d", tc.chunks, len(segments))
+			}
+			var joined []byte
+			for i, s := range segments {
+				if int(s[0]) != i+1 || int(s[1]) != tc.chunks {
+					t.Fatalf("segment %d: sequence %d of %d", i, s[0], s[1])
+				}
+				joined = append(joined, s[2:]...)
+			}
+			if !bytes.Equal(joined, tc.icc) {
+				t.Fatalf("profile differs after reassembly")
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+				t.Fatalf("tagged jpeg does not decode: %v", err)
+			}
+		})
+	}
+
+	t.Run("not a jpeg", func(t *testing.T) {
+		if _, err := embedICC([]byte("\x89PNG"), profile(10)); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+	t.Run("profile too large", func(t *testing.T) {
+		if _, err := embedICC(src.Bytes(), profile(iccChunk*255+1)); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
+
+func TestEmbedPNGICC(t *testing.T) {
+	var src bytes.Buffer
+	if err := png.Encode(&src, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	icc := bytes.Repeat([]byte("profile"), 100)
+
+	out, err := embedPNGICC(src.Bytes(), icc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Fatalf("tagged png does not decode: %v", err)
+	}
+	size := binary.BigEndian.Uint32(out[pngHeaderEnd:])
+	chunk := out[pngHeaderEnd+4 : pngHeaderEnd+8+int(size)]
+	if string(chunk[:4]) != "iCCP" {
+		t.Fatalf("iCCP is not right after IHDR: %q", chunk[:4])
+	}
+	if crc := binary.BigEndian.Uint32(out[pngHeaderEnd+8+int(size):]); crc != crc32.ChecksumIEEE(chunk) {
+		t.Fatalf("bad chunk crc")
+	}
+	name, compressed, ok := bytes.Cut(chunk[4:], []byte{0})
+	if !ok || string(name) != "sRGB" || compressed[0] != 0 {
+		t.Fatalf("bad profile name or compression method")
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed[1:]))
+	if err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	if !bytes.Equal(got, icc) {
+		t.Fatalf("profile differs after decompression")
+	}
+
+	if _, err := embedPNGICC([]byte{0xFF, 0xD8}, icc); err == nil {
+		t.Fatalf("expected error for a jpeg")
+	}
+}
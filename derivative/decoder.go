@@ -0,0 +1,120 @@
+package derivative
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/config"
+	"github.com/ignisVeneficus/lumenta/utils"
+	_ "golang.org/x/image/webp"
+)
+
+type DecodePath string
+
+const (
+	DecodeNative   DecodePath = "native"
+	DecodePreview  DecodePath = "preview"
+	DecodeExternal DecodePath = "external"
+	DecodeVideo    DecodePath = "video"
+)
+
+// embedded previews, the largest first
+var previewTags = []string{"JpgFromRaw", "PreviewImage"}
+
+const previewTimeout = 30 * time.Second
+
+// decodeImage runs the decoder chain: native Go decoders, embedded JPEG
+// preview, external converter. The first successful decoder wins, the error
+// contains the failure of every tried decoder.
+func decodeImage(c context.Context, path string) (image.Image, DecodePath, error) {
+	logScope, ctx := logging.Enter(c, "service/derivative/task/decode", path, map[string]any{"path": path})
+	cfg := config.Global()
+	var errs []error
+
+	img, err := imaging.Open(path, imaging.AutoOrientation(false))
+	if err == nil {
+		logging.Exit(logScope, "ok", map[string]any{"decoder": DecodeNative})
+		return img, DecodeNative, nil
+	}
+	errs = append(errs, fmt.Errorf("%s: %w", DecodeNative, err))
+
+	if cfg.Decoder.PreviewEnabled {
+		img, err = decodePreview(ctx, cfg.Sync.Exiftool.ResolvedPath, path)
+		if err == nil {
+			logging.Exit(logScope, "ok", map[string]any{"decoder": DecodePreview})
+			return img, DecodePreview, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", DecodePreview, err))
+	}
+
+	ext := cfg.Decoder.External
+	if ext.Accepts(utils.NormalizeExt(filepath.Ext(path))) {
+		img, err = decodeExternal(ctx, ext.Command, ext.Args, path, ext.Timeout)
+		if err == nil {
+			logging.Exit(logScope, "ok", map[string]any{"decoder": DecodeExternal})
+			return img, DecodeExternal, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", DecodeExternal, err))
+	}
+
+	err = errors.Join(errs...)
+	logging.ExitErr(logScope, err)
+	return nil, "", err
+}
+
+// decodePreview extracts the embedded JPEG of RAW / HEIC files with exiftool.
+func decodePreview(c context.Context, exiftool string, path string) (image.Image, error) {
+	if exiftool == "" {
+		return nil, errors.New("exiftool not available")
+	}
+	for _, tag := range previewTags {
+		raw, err := runCommand(c, previewTimeout, exiftool, "-b", "-"+tag, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) == 0 {
+			continue
+		}
+		return imaging.Decode(bytes.NewReader(raw))
+	}
+	return nil, errors.New("no embedded preview")
+}
+
+func decodeExternal(c context.Context, command string, args []string, path string, timeout time.Duration) (image.Image, error) {
+	cmdArgs := make([]string, len(args))
+	for i, a := range args {
+		cmdArgs[i] = strings.ReplaceAll(a, "{input}", path)
+	}
+	raw, err := runCommand(c, timeout, command, cmdArgs...)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("empty output")
+	}
+	return imaging.Decode(bytes.NewReader(raw))
+}
+
+// runCommand runs an external tool (decoder, encoder, ffmpeg) and returns its stdout.
+func runCommand(c context.Context, timeout time.Duration, command string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(c, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", filepath.Base(command), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
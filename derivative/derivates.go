@@ -5,7 +5,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ignisVeneficus/logging"
 	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
@@ -35,38 +37,78 @@ func (i *ImageParams) MarshalZerologObjectWithLevel(e *zerolog.Event, level zero
 	}
 }
 
+// Output is one encoded file of a task.
+type Output struct {
+	Format string
+	Path   string
+}
+
 type Task struct {
-	Mode       derivativeConfig.DerivativeConfig
-	TargetPath string
+	Key     Key // the derivative variant, shared by every job generating it
+	Mode    derivativeConfig.DerivativeConfig
+	Outputs []Output
 }
 
 func (t *Task) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Level) {
 	if level <= zerolog.DebugLevel {
-		e.Str("derivative", t.Mode.Name).
-			Str("path", t.TargetPath)
+		paths := make([]string, len(t.Outputs))
+		for i, o := range t.Outputs {
+			paths[i] = o.Path
+		}
+		e.Str("key", string(t.Key)).
+			Str("derivative", t.Mode.Name).
+			Strs("paths", paths)
 	}
 
 }
 
+// Priority: the jobs of a higher priority are started first.
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota // a page request waits for the file
+	PriorityBulk                        // pregeneration and backfill
+	priorityCount
+)
+
+func (p Priority) String() string {
+	if p == PriorityInteractive {
+		return "interactive"
+	}
+	return "bulk"
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
 type Job struct {
-	Key Key
+	Key      Key
+	Priority Priority
 
 	Image      uint64
 	SourcePath string
+	Video      bool // source is a video, the derivatives are made from the poster frame
 
 	Tasks []Task
 
 	ImageParams ImageParams
 	Ctx         context.Context
 
-	//	Done chan Result
+	// optional, receives the result of the job. It must be buffered: the
+	// worker does not wait, the result is dropped if the channel is full.
+	Done chan<- Result
+
+	queuedAt time.Time
 }
 
 func (j *Job) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Level) {
 	if level <= zerolog.DebugLevel {
 		e.Str("key", string(j.Key)).
+			Str("priority", j.Priority.String()).
 			Uint64("image_id", j.Image).
-			Str("path", j.SourcePath)
+			Str("path", j.SourcePath).
+			Bool("video", j.Video)
 	}
 	if level <= zerolog.TraceLevel {
 		a := zerolog.Arr()
@@ -77,20 +119,87 @@ func (j *Job) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Leve
 	}
 }
 
-/*
-	type Result struct {
-		Key      Key
-		Duration time.Duration
-		Err      error
+// Failure is the last failed generation of a derivative variant, keyed by the task key.
+type Failure struct {
+	Key        Key       `json:"key"`
+	Image      uint64    `json:"image_id"`
+	Error      string    `json:"error"`
+	SourcePath string    `json:"source_path"`
+	At         time.Time `json:"at"`
+
+	err error
+}
+
+// Err returns the error of the failed run, classified for the response.
+func (f Failure) Err() error {
+	return failureError(f.err)
+}
+
+// failureError: a decode error is ErrDecodeFailed, any other ErrGenerateFailed.
+func failureError(err error) error {
+	if err == nil || errors.Is(err, ErrDecodeFailed) {
+		return err
 	}
-*/
+	return fmt.Errorf("%w: %w", ErrGenerateFailed, err)
+}
+
+// Outcome is a finished job, kept for the status.
+type Outcome struct {
+	Key        Key       `json:"key"`
+	Image      uint64    `json:"image_id"`
+	SourcePath string    `json:"source_path"`
+	Priority   Priority  `json:"priority"`
+	WaitMs     int64     `json:"wait_ms"` // in the queue
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// Status is the state of the service.
+type Status struct {
+	Workers       int              `json:"workers"`
+	Running       int              `json:"running"`
+	QueueSize     int              `json:"queue_size"`
+	Queued        map[Priority]int `json:"queued"`
+	Done          uint64           `json:"done"`
+	Failed        uint64           `json:"failed"`
+	AvgDurationMs int64            `json:"avg_duration_ms"`
+	Recent        []Outcome        `json:"recent"`   // newest first
+	Failures      []Failure        `json:"failures"` // variants failed on their last run, newest first
+}
+
+// maxOutcomes: the finished jobs kept for the status
+const maxOutcomes = 100
+
+// failureRetryAfter: a failed source is not submitted again before it elapses
+const failureRetryAfter = 10 * time.Minute
+
+type Result struct {
+	Key        Key
+	Image      uint64
+	SourcePath string
+	Duration   time.Duration
+	Err        error
+}
+
 type Step func(j *Job) error
 
 type Service struct {
-	mu      sync.Mutex
-	cond    *sync.Cond
-	queue   *list.List       // FIFO
-	pending map[Key]struct{} // dedup:
+	mu        sync.Mutex
+	cond      *sync.Cond                // a job is queued
+	space     *sync.Cond                // a job is taken from a queue
+	queues    [priorityCount]*list.List // FIFO per priority
+	queueSize int
+	pending   map[Key]struct{}      // dedup: the task keys queued or in-flight
+	waiters   map[Key][]chan Result // task key -> waiting requests
+	running   int
+
+	failures map[Key]Failure // task key -> last failure
+	outcomes []Outcome       // ring of the last finished jobs
+	next     int             // next slot of the ring
+	done     uint64
+	failed   uint64
+	busy     time.Duration // sum of the finished jobs
 
 	step    Step
 	workers int
@@ -101,23 +210,72 @@ type Service struct {
 var (
 	ErrClosed    = errors.New("derivative service is closed")
 	ErrDuplicate = errors.New("job already queued/in-flight")
+	ErrQueueFull = errors.New("derivative queue is full")
+
+	ErrDecodeFailed   = errors.New("source cannot be decoded")
+	ErrGenerateFailed = errors.New("derivative generation failed")
 )
 
-func NewService(step Step, workers int) *Service {
+// NewService creates the service, queueSize: the jobs queued per priority, 0: unbounded.
+func NewService(step Step, workers int, queueSize int) *Service {
 	if workers <= 0 {
 		workers = 1
 	}
 	s := &Service{
-		queue:   list.New(),
-		pending: make(map[Key]struct{}, 1024),
-		step:    step,
-		workers: workers,
+		queueSize: queueSize,
+		pending:   make(map[Key]struct{}, 1024),
+		waiters:   make(map[Key][]chan Result),
+		failures:  make(map[Key]Failure),
+		outcomes:  make([]Outcome, 0, maxOutcomes),
+		step:      step,
+		workers:   workers,
 	}
-	log.Logger.Info().Int("workers", workers).Msg("image derivative service created")
+	for i := range s.queues {
+		s.queues[i] = list.New()
+	}
+	log.Logger.Info().Int("workers", workers).Int("queue_size", queueSize).Msg("image derivative service created")
 	s.cond = sync.NewCond(&s.mu)
+	s.space = sync.NewCond(&s.mu)
 	return s
 }
 
+// keys: the keys the job is deduplicated and reported on, one per task.
+func (j *Job) keys() []Key {
+	if len(j.Tasks) == 0 {
+		return []Key{j.Key}
+	}
+	ret := make([]Key, len(j.Tasks))
+	for i, t := range j.Tasks {
+		ret[i] = t.Key
+		if ret[i] == "" {
+			ret[i] = j.Key
+		}
+	}
+	return ret
+}
+
+// notPending returns the tasks of the job no other job is generating,
+// nil if there is nothing left. A job without tasks is checked by its key.
+func (s *Service) notPending(j *Job) []Task {
+	if len(j.Tasks) == 0 {
+		if _, exists := s.pending[j.Key]; exists {
+			return nil
+		}
+		return []Task{}
+	}
+	var ret []Task
+	for i, k := range j.keys() {
+		if _, exists := s.pending[k]; !exists {
+			ret = append(ret, j.Tasks[i])
+		}
+	}
+	return ret
+}
+
+// Submit queues the job. If the queue of its priority is full, an interactive
+// job is refused with ErrQueueFull, a bulk one waits for free space.
+// The tasks already queued or in-flight in another job are dropped,
+// ErrDuplicate if none is left.
 func (s *Service) Submit(j Job) (bool, error) {
 	logg, _ := logging.Enter(j.Ctx, "derivative/service/submit", j.Key, map[string]any{"job": j})
 	if j.Key == "" {
@@ -128,17 +286,37 @@ func (s *Service) Submit(j Job) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.closed {
-		logging.ExitErr(logg, ErrClosed)
-		return false, ErrClosed
+	if j.Priority < 0 || j.Priority >= priorityCount {
+		j.Priority = PriorityBulk
 	}
-	if _, exists := s.pending[j.Key]; exists {
-		logging.Exit(logg, "duplicated", nil)
-		return false, ErrDuplicate
+	queue := s.queues[j.Priority]
+	var tasks []Task
+	for {
+		if s.closed {
+			logging.ExitErr(logg, ErrClosed)
+			return false, ErrClosed
+		}
+		if tasks = s.notPending(&j); tasks == nil {
+			logging.Exit(logg, "duplicated", nil)
+			return false, ErrDuplicate
+		}
+		if s.queueSize <= 0 || queue.Len() < s.queueSize {
+			break
+		}
+		if j.Priority == PriorityInteractive {
+			logging.ExitErr(logg, ErrQueueFull)
+			return false, ErrQueueFull
+		}
+		// backpressure: the bulk submitter waits for the workers
+		s.space.Wait()
 	}
 
-	s.pending[j.Key] = struct{}{}
-	s.queue.PushBack(&j)
+	j.Tasks = tasks
+	j.queuedAt = time.Now()
+	for _, k := range j.keys() {
+		s.pending[k] = struct{}{}
+	}
+	queue.PushBack(&j)
 
 	s.cond.Signal()
 	logging.Exit(logg, "", nil)
@@ -150,6 +328,7 @@ func (s *Service) Close() {
 	s.closed = true
 	s.mu.Unlock()
 	s.cond.Broadcast()
+	s.space.Broadcast()
 }
 
 func (s *Service) Run(ctx context.Context) {
@@ -164,7 +343,8 @@ func (s *Service) Run(ctx context.Context) {
 	}
 
 	<-ctx.Done()
-	s.cond.Broadcast()
+	// the waiting bulk submitters are released, nobody takes their jobs
+	s.Close()
 	wg.Wait()
 }
 
@@ -182,26 +362,176 @@ func (s *Service) workerLoop(ctx context.Context, workerID int) {
 			"image": j.Image})
 
 		res := "ok"
+		start := time.Now()
 		err := s.execute(j)
+		duration := time.Since(start)
 		if err != nil {
 			logging.ErrorContinue(loopLogScope, err, nil)
 			res = "error"
 		}
 
-		s.mu.Lock()
-		delete(s.pending, j.Key)
-		s.mu.Unlock()
+		result := s.finish(j, start, duration, err)
 
-		/*
-			if j.Done != nil {
-				select {
-				case j.Done <- Result{Key: j.Key, Duration: time.Since(start), Err: err}:
-				default:
-				}
+		if j.Done != nil {
+			select {
+			case j.Done <- result:
+			default:
+				logging.Info(loopLogScope, "result dropped, the done channel is full", nil)
 			}
-		*/
-		logging.Exit(loopLogScope, res, nil)
+		}
+		logging.Exit(loopLogScope, res, map[string]any{"duration": duration})
+	}
+}
+
+// finish records the outcome of the job and notifies the waiters.
+func (s *Service) finish(j *Job, start time.Time, duration time.Duration, err error) Result {
+	result := Result{Key: j.Key, Image: j.Image, SourcePath: j.SourcePath, Duration: duration, Err: err}
+	now := time.Now()
+	o := Outcome{
+		Key:        j.Key,
+		Image:      j.Image,
+		SourcePath: j.SourcePath,
+		Priority:   j.Priority,
+		WaitMs:     start.Sub(j.queuedAt).Milliseconds(),
+		DurationMs: duration.Milliseconds(),
+		At:         now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range j.keys() {
+		delete(s.pending, k)
+		for _, w := range s.waiters[k] {
+			w <- result
+		}
+		delete(s.waiters, k)
+		if err != nil {
+			s.failures[k] = Failure{
+				Key:        k,
+				Image:      j.Image,
+				Error:      err.Error(),
+				SourcePath: j.SourcePath,
+				At:         now,
+				err:        err,
+			}
+		} else {
+			delete(s.failures, k)
+		}
+	}
+	s.running--
+	s.busy += duration
+	if err != nil {
+		o.Error = err.Error()
+		s.failed++
+	} else {
+		s.done++
+	}
+	if len(s.outcomes) < maxOutcomes {
+		s.outcomes = append(s.outcomes, o)
+	} else {
+		s.outcomes[s.next] = o
+	}
+	s.next = (s.next + 1) % maxOutcomes
+	return result
+}
+
+// Wait blocks until the queued or running job of the task key is finished, at most
+// for the timeout. Every waiter of a job gets its result, the error classified
+// as ErrDecodeFailed or ErrGenerateFailed.
+// false if there is no such job or it is not finished in time.
+func (s *Service) Wait(c context.Context, key Key, timeout time.Duration) (Result, bool) {
+	s.mu.Lock()
+	if _, ok := s.pending[key]; !ok {
+		s.mu.Unlock()
+		return Result{}, false
+	}
+	ch := make(chan Result, 1)
+	s.waiters[key] = append(s.waiters[key], ch)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		r.Err = failureError(r.Err)
+		return r, true
+	case <-timer.C:
+	case <-c.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case r := <-ch:
+		// finished meanwhile
+		r.Err = failureError(r.Err)
+		return r, true
+	default:
+	}
+	waiters := s.waiters[key]
+	for i, w := range waiters {
+		if w == ch {
+			s.waiters[key] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
 	}
+	if len(s.waiters[key]) == 0 {
+		delete(s.waiters, key)
+	}
+	return Result{}, false
+}
+
+// Status returns the queue depths, the counters and the last outcomes.
+func (s *Service) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := Status{
+		Workers:   s.workers,
+		Running:   s.running,
+		QueueSize: s.queueSize,
+		Queued:    make(map[Priority]int, priorityCount),
+		Done:      s.done,
+		Failed:    s.failed,
+		Recent:    make([]Outcome, 0, len(s.outcomes)),
+		Failures:  make([]Failure, 0, len(s.failures)),
+	}
+	for p, q := range s.queues {
+		ret.Queued[Priority(p)] = q.Len()
+	}
+	if finished := s.done + s.failed; finished > 0 {
+		ret.AvgDurationMs = (s.busy / time.Duration(finished)).Milliseconds()
+	}
+	for i := 1; i <= len(s.outcomes); i++ {
+		ret.Recent = append(ret.Recent, s.outcomes[(s.next-i+len(s.outcomes))%len(s.outcomes)])
+	}
+	for _, f := range s.failures {
+		ret.Failures = append(ret.Failures, f)
+	}
+	sort.Slice(ret.Failures, func(i, j int) bool { return ret.Failures[i].At.After(ret.Failures[j].At) })
+	return ret
+}
+
+// Failure returns the last failure of the task key, if its last run failed.
+func (s *Service) Failure(key Key) (Failure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.failures[key]
+	return f, ok
+}
+
+// ImageFailure returns the newest failure among the derivatives of the image.
+func (s *Service) ImageFailure(imageID uint64) (Failure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ret Failure
+	found := false
+	for _, f := range s.failures {
+		if f.Image == imageID && (!found || f.At.After(ret.At)) {
+			ret = f
+			found = true
+		}
+	}
+	return ret, found
 }
 
 func (s *Service) pop(ctx context.Context) *Job {
@@ -209,9 +539,13 @@ func (s *Service) pop(ctx context.Context) *Job {
 	defer s.mu.Unlock()
 
 	for {
-		if e := s.queue.Front(); e != nil {
-			s.queue.Remove(e)
-			return e.Value.(*Job)
+		for _, q := range s.queues {
+			if e := q.Front(); e != nil {
+				q.Remove(e)
+				s.running++
+				s.space.Broadcast()
+				return e.Value.(*Job)
+			}
 		}
 
 		if s.closed {
@@ -229,10 +563,10 @@ func (s *Service) execute(j *Job) error {
 	return s.step(j)
 }
 
-func Init(c context.Context, workers int) {
-	logScope, ctx := logging.Enter(c, "service/derivative/init", nil, nil)
+func Init(c context.Context, workers int, queueSize int) {
+	logScope, ctx := logging.Enter(c, "service/derivative/init", nil, map[string]any{"workers": workers, "queue_size": queueSize})
 	globalOnce.Do(func() {
-		global = NewService(GenerateDerivativeStep, workers)
+		global = NewService(GenerateDerivativeStep, workers, queueSize)
 		go global.Run(ctx)
 	})
 	logging.Exit(logScope, "started", nil)
@@ -0,0 +1,301 @@
+package derivative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedStep records the started jobs and blocks each until released.
+type gatedStep struct {
+	mu      sync.Mutex
+	started []Job
+	startCh chan Key
+	release chan error
+}
+
+func newGatedStep() *gatedStep {
+	return &gatedStep{startCh: make(chan Key, 16), release: make(chan error)}
+}
+
+func (g *gatedStep) step(j *Job) error {
+	g.mu.Lock()
+	g.started = append(g.started, *j)
+	g.mu.Unlock()
+	g.startCh <- j.Key
+	return <-g.release
+}
+
+func (g *gatedStep) waitStart(t *testing.T) Key {
+	t.Helper()
+	select {
+	case k := <-g.startCh:
+		return k
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no job started")
+		return ""
+	}
+}
+
+// runService starts the workers, they stop at the end of the test.
+func runService(t *testing.T, s *Service) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+}
+
+func testJob(key Key, p Priority, image uint64, tasks ...Key) Job {
+	j := Job{Key: key, Priority: p, Image: image, Ctx: context.Background()}
+	for _, k := range tasks {
+		j.Tasks = append(j.Tasks, Task{Key: k})
+	}
+	return j
+}
+
+func TestServicePriorities(t *testing.T) {
+	g := newGatedStep()
+	s := NewService(g.step, 1, 0)
+	runService(t, s)
+
+	if _, err := s.Submit(testJob("blocker", PriorityBulk, 1)); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	g.waitStart(t)
+	for _, j := range []Job{
+		testJob("bulk-1", PriorityBulk, 2),
+		testJob("bulk-2", PriorityBulk, 3),
+		testJob("interactive", PriorityInteractive, 4),
+	} {
+		if _, err := s.Submit(j); err != nil {
+			t.Fatalf("submit %s: %v", j.Key, err)
+		}
+	}
+	if q := s.Status().Queued; q[PriorityBulk] != 2 || q[PriorityInteractive] != 1 {
+		t.Fatalf("unexpected queue depths: %v", q)
+	}
+
+	want := []Key{"interactive", "bulk-1", "bulk-2"}
+	for _, w := range want {
+		g.release <- nil
+		if got := g.waitStart(t); got != w {
+			t.Fatalf("expected %s to start, got %s", w, got)
+		}
+	}
+	g.release <- nil
+}
+
+func TestServiceQueueFull(t *testing.T) {
+	// no workers run, the queue only fills
+	s := NewService(func(*Job) error { return nil }, 1, 1)
+
+	if _, err := s.Submit(testJob("a", PriorityInteractive, 1)); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := s.Submit(testJob("b", PriorityInteractive, 2)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	// the queues are per priority
+	if _, err := s.Submit(testJob("c", PriorityBulk, 3)); err != nil {
+		t.Fatalf("submit bulk: %v", err)
+	}
+
+	// a bulk submitter waits for the space
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := s.Submit(testJob("d", PriorityBulk, 4))
+		submitted <- err
+	}()
+	select {
+	case err := <-submitted:
+		t.Fatalf("bulk submit did not wait: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.Close()
+	if err := <-submitted; !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestServiceFailuresByTask(t *testing.T) {
+	g := newGatedStep()
+	s := NewService(g.step, 1, 0)
+	runService(t, s)
+
+	done := make(chan Result, 1)
+	j := testJob("img-7", PriorityBulk, 7, "img-7/s", "img-7/m")
+	j.Done = done
+	if _, err := s.Submit(j); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	g.waitStart(t)
+	g.release <- errors.New("broken")
+	if r := <-done; r.Err == nil {
+		t.Fatalf("expected an error result")
+	}
+
+	for _, k := range []Key{"img-7/s", "img-7/m"} {
+		f, ok := s.Failure(k)
+		if !ok {
+			t.Fatalf("no failure for %s", k)
+		}
+		if !errors.Is(f.Err(), ErrGenerateFailed) {
+			t.Fatalf("expected ErrGenerateFailed, got %v", f.Err())
+		}
+	}
+	if _, ok := s.Failure("img-7"); ok {
+		t.Fatalf("failure keyed by the job")
+	}
+	if f, ok := s.ImageFailure(7); !ok || f.Image != 7 {
+		t.Fatalf("expected the failure of image 7, got %+v", f)
+	}
+
+	// a later run of one variant clears only its failure
+	j = testJob("img-7-again", PriorityBulk, 7, "img-7/s")
+	j.Done = done
+	if _, err := s.Submit(j); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	g.waitStart(t)
+	g.release <- nil
+	<-done
+	if _, ok := s.Failure("img-7/s"); ok {
+		t.Fatalf("failure of img-7/s not cleared")
+	}
+	if _, ok := s.Failure("img-7/m"); !ok {
+		t.Fatalf("failure of img-7/m cleared")
+	}
+	if st := s.Status(); st.Done != 1 || st.Failed != 1 || len(st.Failures) != 1 {
+		t.Fatalf("unexpected status: done %d failed %d failures %d", st.Done, st.Failed, len(st.Failures))
+	}
+}
+
+func TestServiceDecodeFailure(t *testing.T) {
+	f := Failure{err: errors.Join(ErrDecodeFailed, errors.New("bad file"))}
+	if err := f.Err(); !errors.Is(err, ErrDecodeFailed) || errors.Is(err, ErrGenerateFailed) {
+		t.Fatalf("expected only ErrDecodeFailed, got %v", err)
+	}
+}
+
+func TestServiceFullDoneChannel(t *testing.T) {
+	s := NewService(func(*Job) error { return nil }, 1, 0)
+	runService(t, s)
+
+	// unbuffered and never read: the worker must drop the result
+	j := testJob("a", PriorityBulk, 1)
+	j.Done = make(chan Result)
+	if _, err := s.Submit(j); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	done := make(chan Result, 1)
+	j = testJob("b", PriorityBulk, 2)
+	j.Done = done
+	if _, err := s.Submit(j); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("worker blocked on the done channel")
+	}
+}
+
+func TestServiceSharedGeneration(t *testing.T) {
+	g := newGatedStep()
+	s := NewService(g.step, 2, 0)
+	runService(t, s)
+
+	// the page request and the pregeneration share the variants
+	if _, err := s.Submit(testJob("img-1", PriorityInteractive, 1, "img-1/s")); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	g.waitStart(t)
+	if _, err := s.Submit(testJob("img-1-sync", PriorityBulk, 1, "img-1/s")); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+
+	// only the variant nobody generates is queued
+	if _, err := s.Submit(testJob("img-1-all", PriorityBulk, 1, "img-1/s", "img-1/m")); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	g.waitStart(t)
+	g.mu.Lock()
+	started := append([]Job(nil), g.started...)
+	g.mu.Unlock()
+	if len(started) != 2 || started[1].Key != "img-1-all" {
+		t.Fatalf("unexpected started jobs: %d", len(started))
+	}
+	if tasks := started[1].Tasks; len(tasks) != 1 || tasks[0].Key != "img-1/m" {
+		t.Fatalf("expected only img-1/m, got %v", tasks)
+	}
+	g.release <- nil
+	g.release <- nil
+}
+
+func TestServiceWait(t *testing.T) {
+	g := newGatedStep()
+	s := NewService(g.step, 1, 0)
+	runService(t, s)
+
+	if _, ok := s.Wait(context.Background(), "img-1/s", time.Second); ok {
+		t.Fatalf("waited for a job never queued")
+	}
+	if _, err := s.Submit(testJob("img-1", PriorityInteractive, 1, "img-1/s", "img-1/m")); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	g.waitStart(t)
+
+	// not finished in time: the request answers 202
+	if _, ok := s.Wait(context.Background(), "img-1/s", 20*time.Millisecond); ok {
+		t.Fatalf("wait finished before the job")
+	}
+	s.mu.Lock()
+	left := len(s.waiters["img-1/s"])
+	s.mu.Unlock()
+	if left != 0 {
+		t.Fatalf("timed out waiter kept")
+	}
+
+	// every waiter of every variant gets the result
+	results := make(chan Result, 2)
+	var wg sync.WaitGroup
+	for _, k := range []Key{"img-1/s", "img-1/m"} {
+		wg.Add(1)
+		go func(k Key) {
+			defer wg.Done()
+			r, ok := s.Wait(context.Background(), k, 2*time.Second)
+			if !ok {
+				t.Errorf("wait for %s timed out", k)
+			}
+			results <- r
+		}(k)
+	}
+	// both waiters registered before the release
+	for {
+		s.mu.Lock()
+		n := len(s.waiters)
+		s.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	g.release <- fmt.Errorf("%w: bad file", ErrDecodeFailed)
+	wg.Wait()
+	close(results)
+	for r := range results {
+		if !errors.Is(r.Err, ErrDecodeFailed) {
+			t.Fatalf("expected ErrDecodeFailed, got %v", r.Err)
+		}
+	}
+}
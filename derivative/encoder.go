@@ -0,0 +1,112 @@
+package derivative
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/config"
+)
+
+// writeEncoded writes the image in a format without Go encoder: the image is
+// saved as png and converted by the configured external encoder.
+// finish runs on the encoded temporary file before it is renamed to the path.
+func writeEncoded(c context.Context, img image.Image, o Output, quality int, finish func(tmp string) error) error {
+	logScope, ctx := logging.Enter(c, "service/derivative/task/encode", o.Path, map[string]any{"path": o.Path, "format": o.Format})
+
+	enc, ok := config.Global().Derivatives.Encoders[o.Format]
+	if !ok {
+		err := fmt.Errorf("no encoder for format: %s", o.Format)
+		logging.ExitErr(logScope, err)
+		return err
+	}
+
+	dir := filepath.Dir(o.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "create dirs"})
+		return err
+	}
+	// every writer has its own temporary files, both end with .tmp,
+	// a crash leftover is removed by the gc
+	input, err := tempName(dir, filepath.Base(o.Path)+".*.png.tmp")
+	if err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "create png"})
+		return err
+	}
+	defer os.Remove(input)
+	tmp, err := tempName(dir, filepath.Base(o.Path)+".*.tmp")
+	if err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "create file"})
+		return err
+	}
+	defer os.Remove(tmp)
+	if err := writePNG(img, input); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "write png"})
+		return err
+	}
+
+	args := make([]string, len(enc.Args))
+	r := strings.NewReplacer("{input}", input, "{output}", tmp, "{quality}", strconv.Itoa(quality))
+	for i, a := range enc.Args {
+		args[i] = r.Replace(a)
+	}
+	if _, err := runCommand(ctx, enc.Timeout, enc.Command, args...); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "encode"})
+		return err
+	}
+	if err := finish(tmp); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "finish"})
+		return err
+	}
+	// the temporary file is private
+	if err := os.Chmod(tmp, derivativePerm); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "chmod"})
+		return err
+	}
+	if err := os.Rename(tmp, o.Path); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "rename"})
+		return err
+	}
+	logging.Exit(logScope, "ok", nil)
+	return nil
+}
+
+// tempName creates an empty temporary file in the dir and returns its path.
+func tempName(dir string, pattern string) (string, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// writePNG: fast, the file is only the input of the encoder.
+// It is tagged with the sRGB profile, for the encoders keeping it.
+func writePNG(img image.Image, path string) error {
+	var b bytes.Buffer
+	if err := imaging.Encode(&b, img, imaging.PNG, imaging.PNGCompressionLevel(png.BestSpeed)); err != nil {
+		return err
+	}
+	data := b.Bytes()
+	if icc := config.Global().Decoder.Color.SRGBProfileData; len(icc) > 0 {
+		tagged, err := embedPNGICC(data, icc)
+		if err != nil {
+			return err
+		}
+		data = tagged
+	}
+	return os.WriteFile(path, data, 0600)
+}
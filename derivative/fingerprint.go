@@ -0,0 +1,68 @@
+package derivative
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ignisVeneficus/lumenta/config"
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
+	"github.com/ignisVeneficus/lumenta/data"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
+const fingerprintLength = 12
+
+func shortHash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])[:fingerprintLength]
+}
+
+// imageInputs: the image side of the derivatives, the source content,
+// the rotation and the focus point.
+func imageInputs(image dbo.Image) string {
+	rot := int16(0)
+	if image.Rotation != nil && !image.IsVideo() {
+		rot = *image.Rotation
+	}
+	focus := data.ResolveFocus(image.FocusX, image.FocusY, data.ImageFocusMode(image.FocusMode))
+	return fmt.Sprintf("%s|%d|%s|%.4f|%.4f", image.FileHash, rot, focus.FocusMode, focus.FocusX, focus.FocusY)
+}
+
+// configInputs: the settings of the derivative, with the policy of its role.
+func configInputs(cfg derivativeConfig.DerivativeConfig) string {
+	ret := fmt.Sprintf("%s|%s|%d|%d|%d", cfg.Name, cfg.Mode, cfg.MaxWidth, cfg.MaxHeight, cfg.JPGQuality)
+	if policy := cfg.PolicyConfig.Inputs(); policy != "" {
+		ret += "|" + policy
+	}
+	return ret
+}
+
+// Fingerprint identifies the content of one derivative of the image.
+// It is part of the file name: a new source, rotation, focus or derivative
+// setting gives a new file, generated on the next request.
+func Fingerprint(image dbo.Image, cfg derivativeConfig.DerivativeConfig) string {
+	parts := []string{imageInputs(image), configInputs(cfg)}
+	if color := config.Global().Decoder.Color.Inputs(); color != "" {
+		parts = append(parts, color)
+	}
+	return shortHash(parts...)
+}
+
+// Version is the cache-busting version of every derivative URL of the image.
+// It covers all derivative settings, a configuration change invalidates the browser caches too.
+func Version(image dbo.Image) string {
+	parts := []string{imageInputs(image)}
+	if color := config.Global().Decoder.Color.Inputs(); color != "" {
+		parts = append(parts, color)
+	}
+	for _, cfg := range config.Global().Derivatives.Sizes {
+		// a new format is served to the clients of a cached one too
+		parts = append(parts, strings.Join(cfg.Formats, ","))
+		for _, v := range cfg.Variants() {
+			parts = append(parts, configInputs(v))
+		}
+	}
+	return shortHash(parts...)
+}
@@ -0,0 +1,147 @@
+package derivative
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ignisVeneficus/logging"
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
+	fsConfig "github.com/ignisVeneficus/lumenta/config/filesystem"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
+// tmpMaxAge: an unfinished derivative (.tmp) older than this is left over by a crash
+const tmpMaxAge = time.Hour
+
+var ErrDerivativesOverlap = errors.New("derivative tree overlaps an originals root")
+
+type GCReport struct {
+	Scanned uint64   `json:"scanned"`
+	Orphans []string `json:"orphans"`
+	Bytes   int64    `json:"bytes"` // size of the orphans
+	Deleted uint64   `json:"deleted"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+// expectedDerivatives returns the path of every derivative of the current
+// images, sizes and fingerprints, the guest copy of the videos included.
+func expectedDerivatives(images imageSource, cfgs []derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig) (map[string]struct{}, error) {
+	ret := map[string]struct{}{}
+	err := images(func(img dbo.Image) {
+		if img.IsVideo() {
+			ret[guestVideoPath(img, roots)] = struct{}{}
+		}
+		for _, cfg := range cfgs {
+			for _, v := range cfg.Variants() {
+				for _, f := range v.Formats {
+					ret[derivativeFormatPath(img, v, roots, f)] = struct{}{}
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CollectGarbage deletes the files of the derivative tree not belonging to a
+// current derivative: deleted images, dropped sizes and outdated fingerprints.
+// Files modified after the start are kept, they may belong to a change made meanwhile.
+// It refuses to run when the derivative tree overlaps an originals root.
+// dryRun: only report the orphans.
+func CollectGarbage(c context.Context, database *sql.DB, cfgs []derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, dryRun bool) (GCReport, error) {
+	return collectGarbage(c, databaseImages(c, database), cfgs, roots, time.Now(), dryRun)
+}
+
+// collectGarbage: CollectGarbage of the images, started is the start of the run.
+func collectGarbage(c context.Context, images imageSource, cfgs []derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, started time.Time, dryRun bool) (GCReport, error) {
+	logScope, ctx := logging.Enter(c, "derivative/gc", nil, map[string]any{"root": roots.Derivatives, "dry_run": dryRun})
+	report := GCReport{DryRun: dryRun}
+	if name := roots.OverlappingOriginal(); name != "" {
+		err := fmt.Errorf("%w: originals[%s]", ErrDerivativesOverlap, name)
+		logging.ExitErr(logScope, err)
+		return report, err
+	}
+
+	expected, err := expectedDerivatives(images, cfgs, roots)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return report, err
+	}
+
+	// the expected paths are built with filepath.Join, so they are clean
+	root := filepath.Clean(roots.Derivatives)
+	var dirs []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if path != root {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		report.Scanned++
+		if _, ok := expected[path]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		maxTime := started
+		if strings.HasSuffix(path, ".tmp") {
+			maxTime = started.Add(-tmpMaxAge)
+		}
+		if info.ModTime().After(maxTime) {
+			return nil
+		}
+		report.Orphans = append(report.Orphans, path)
+		report.Bytes += info.Size()
+		if dryRun {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			logging.ErrorContinue(logScope, err, map[string]any{"path": path})
+			return nil
+		}
+		report.Deleted++
+		return nil
+	})
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return report, err
+	}
+
+	if !dryRun {
+		// deepest first, a non empty directory is not removed
+		sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+		for _, dir := range dirs {
+			_ = os.Remove(dir)
+		}
+	}
+
+	logging.Exit(logScope, "ok", map[string]any{
+		"scanned": report.Scanned,
+		"orphans": len(report.Orphans),
+		"deleted": report.Deleted,
+		"bytes":   report.Bytes,
+	})
+	return report, nil
+}
@@ -0,0 +1,165 @@
+package derivative
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ignisVeneficus/lumenta/config"
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
+	fsConfig "github.com/ignisVeneficus/lumenta/config/filesystem"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/utils"
+)
+
+func TestCollectGarbageRefusesOverlap(t *testing.T) {
+	base := t.TempDir()
+	photos := filepath.Join(base, "photos")
+	inside := filepath.Join(photos, "cache")
+	if err := os.MkdirAll(inside, 0o755); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	original := filepath.Join(photos, "img.jpg")
+	if err := os.WriteFile(original, []byte("photo"), 0o644); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	for name, derivatives := range map[string]string{
+		"equal":              photos,
+		"contains originals": base,
+		"inside originals":   inside,
+	} {
+		t.Run(name, func(t *testing.T) {
+			roots := fsConfig.FilesystemConfig{
+				Originals:   fsConfig.RootConfigs{"main": {Root: photos}},
+				Derivatives: derivatives,
+			}
+			// the database is never reached
+			_, err := CollectGarbage(context.Background(), nil, nil, roots, false)
+			if !errors.Is(err, ErrDerivativesOverlap) {
+				t.Fatalf("expected ErrDerivativesOverlap, got %v", err)
+			}
+			if _, err := os.Stat(original); err != nil {
+				t.Fatalf("original touched: %v", err)
+			}
+		})
+	}
+}
+
+// testGlobalConfig: the fingerprints read the color settings, none configured
+func testGlobalConfig() {
+	config.SetGlobal(&config.Config{})
+}
+
+func testImage(id dbo.ImageID, name string) dbo.Image {
+	return dbo.Image{ID: &id, Root: "main", Path: "a", Filename: name, Ext: "jpg", FileHash: "hash-" + name}
+}
+
+func testImages(images ...dbo.Image) imageSource {
+	return func(fn func(dbo.Image)) error {
+		for _, img := range images {
+			fn(img)
+		}
+		return nil
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	testGlobalConfig()
+	small := derivativeConfig.DerivativeConfig{Name: "small", Postfix: "s", MaxWidth: 100, Formats: []string{derivativeConfig.FormatJPEG}}
+	dropped := derivativeConfig.DerivativeConfig{Name: "old", Postfix: "o", MaxWidth: 50, Formats: []string{derivativeConfig.FormatJPEG}}
+	kept := testImage(1, "one")
+	deleted := testImage(2, "two")
+	clip := testImage(3, "clip")
+	clip.Ext = "mp4"
+	clip.MediaType = dbo.MediaTypeVideo
+	replaced := clip
+	replaced.FileHash = "hash-old"
+	started := time.Now().Add(-time.Minute)
+
+	files := []struct {
+		name   string
+		path   func(roots fsConfig.FilesystemConfig) string
+		age    time.Duration // before the start, negative: after
+		orphan bool
+	}{
+		{"current", func(r fsConfig.FilesystemConfig) string { return derivativePath(kept, small, r) }, time.Hour, false},
+		{"stale fingerprint", func(r fsConfig.FilesystemConfig) string {
+			return utils.ConcatGlobalDerivativePath(r.Derivatives, "main", "a", "one", "jpg", "s.000000000000", "jpg")
+		}, time.Hour, true},
+		{"dropped postfix", func(r fsConfig.FilesystemConfig) string { return derivativePath(kept, dropped, r) }, time.Hour, true},
+		{"deleted image", func(r fsConfig.FilesystemConfig) string { return derivativePath(deleted, small, r) }, time.Hour, true},
+		{"newer than the start", func(r fsConfig.FilesystemConfig) string { return derivativePath(deleted, dropped, r) }, -30 * time.Second, false},
+		{"guest video", func(r fsConfig.FilesystemConfig) string { return guestVideoPath(clip, r) }, time.Hour, false},
+		{"guest video of a replaced source", func(r fsConfig.FilesystemConfig) string { return guestVideoPath(replaced, r) }, time.Hour, true},
+		{"recent tmp", func(r fsConfig.FilesystemConfig) string {
+			return filepath.Join(r.Derivatives, "main", "a", "one.recent.tmp")
+		}, tmpMaxAge / 2, false},
+		{"old tmp", func(r fsConfig.FilesystemConfig) string {
+			return filepath.Join(r.Derivatives, "main", "a", "one.old.tmp")
+		}, 2 * tmpMaxAge, true},
+	}
+
+	for _, dryRun := range []bool{false, true} {
+		t.Run(map[bool]string{false: "delete", true: "dry run"}[dryRun], func(t *testing.T) {
+			base := t.TempDir()
+			roots := fsConfig.FilesystemConfig{
+				Originals:   fsConfig.RootConfigs{"main": {Root: filepath.Join(base, "photos")}},
+				Derivatives: filepath.Join(base, "cache"),
+			}
+			var bytes int64
+			for i, f := range files {
+				path := f.path(roots)
+				content := make([]byte, i+1)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatalf("setup failed: %v", err)
+				}
+				if err := os.WriteFile(path, content, 0o644); err != nil {
+					t.Fatalf("setup failed: %v", err)
+				}
+				mtime := started.Add(-f.age)
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatalf("setup failed: %v", err)
+				}
+				if f.orphan {
+					bytes += int64(len(content))
+				}
+			}
+
+			report, err := collectGarbage(context.Background(), testImages(kept, clip), []derivativeConfig.DerivativeConfig{small}, roots, started, dryRun)
+			if err != nil {
+				t.Fatalf("gc failed: %v", err)
+			}
+			if report.Scanned != uint64(len(files)) {
+				t.Fatalf("expected %d scanned, got %d", len(files), report.Scanned)
+			}
+			if report.Bytes != bytes {
+				t.Fatalf("expected %d orphan bytes, got %d", bytes, report.Bytes)
+			}
+			orphans := 0
+			for _, f := range files {
+				_, err := os.Stat(f.path(roots))
+				exists := err == nil
+				if f.orphan {
+					orphans++
+				}
+				if want := !f.orphan || dryRun; exists != want {
+					t.Fatalf("%s: expected exists %v, got %v", f.name, want, exists)
+				}
+			}
+			if len(report.Orphans) != orphans {
+				t.Fatalf("expected %d orphans, got %v", orphans, report.Orphans)
+			}
+			wantDeleted := uint64(orphans)
+			if dryRun {
+				wantDeleted = 0
+			}
+			if report.Deleted != wantDeleted {
+				t.Fatalf("expected %d deleted, got %d", wantDeleted, report.Deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package derivative
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+
+	"github.com/ignisVeneficus/logging"
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
+	fsConfig "github.com/ignisVeneficus/lumenta/config/filesystem"
+	"github.com/ignisVeneficus/lumenta/db/dao"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/utils"
+)
+
+type MigrateReport struct {
+	Moved   uint64 `json:"moved"`
+	Removed uint64 `json:"removed"` // old file not reusable, regenerated on request
+	Failed  uint64 `json:"failed"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+// legacyDerivativePath: the path before the source extension and the
+// fingerprint were added, <name>-<postfix>.jpg.
+func legacyDerivativePath(image dbo.Image, cfg derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig) string {
+	return filepath.Join(roots.Derivatives, image.Root, image.Path, image.Filename+"-"+cfg.Postfix+".jpg")
+}
+
+func sourceName(image dbo.Image) string {
+	return filepath.Join(image.Root, image.Path, image.Filename)
+}
+
+// imageSource calls fn with every image.
+type imageSource func(fn func(dbo.Image)) error
+
+func databaseImages(c context.Context, database *sql.DB) imageSource {
+	return func(fn func(dbo.Image)) error {
+		return forEachImage(c, database, fn)
+	}
+}
+
+// forEachImage calls fn with every image, paged by image ID.
+func forEachImage(c context.Context, database *sql.DB, fn func(dbo.Image)) error {
+	var after dbo.ImageID
+	for {
+		images, err := dao.QueryImageAfterID(database, c, after, backfillPageSize)
+		if err != nil {
+			return err
+		}
+		for _, img := range images {
+			fn(img)
+		}
+		if uint64(len(images)) < backfillPageSize {
+			return nil
+		}
+		after = *images[len(images)-1].ID
+	}
+}
+
+// MigratePaths relocates the derivative files of the old naming (<name>-<postfix>.jpg)
+// to the current one. The old name has no source extension: the file is moved
+// only if a single image has the name, the file of a name shared by more images
+// (photo.jpg, photo.cr2) may be of any of them, it is removed. The old files
+// have no watermark and no copied exif, they are moved to the variant of the
+// size without policy; the file of a size with a policy for every role is
+// removed. The removed files are regenerated on request. A moved file of a
+// non-sRGB original stays unconverted until it is regenerated (derivatives generate).
+// dryRun: only count the files.
+func MigratePaths(c context.Context, database *sql.DB, cfgs []derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, dryRun bool) (MigrateReport, error) {
+	return migratePaths(c, databaseImages(c, database), cfgs, roots, dryRun)
+}
+
+func migratePaths(c context.Context, images imageSource, cfgs []derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, dryRun bool) (MigrateReport, error) {
+	logScope, _ := logging.Enter(c, "derivative/migrate", nil, map[string]any{"root": roots.Derivatives, "dry_run": dryRun})
+	report := MigrateReport{DryRun: dryRun}
+
+	// images by the name without extension, the old derivative name
+	sharing := map[string]int{}
+	err := images(func(img dbo.Image) {
+		sharing[sourceName(img)]++
+	})
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return report, err
+	}
+
+	// the variant of every size the old file can be: the one without policy
+	plain := make([]*derivativeConfig.DerivativeConfig, len(cfgs))
+	for i, cfg := range cfgs {
+		variants := cfg.Variants()
+		for j := range variants {
+			if variants[j].PolicyConfig.Empty() {
+				plain[i] = &variants[j]
+				break
+			}
+		}
+	}
+
+	remove := func(path string) {
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				report.Failed++
+				logging.ErrorContinue(logScope, err, map[string]any{"path": path})
+				return
+			}
+		}
+		report.Removed++
+	}
+	move := func(from, to string) {
+		if !dryRun {
+			if err := os.Rename(from, to); err != nil {
+				report.Failed++
+				logging.ErrorContinue(logScope, err, map[string]any{"from": from, "to": to})
+				return
+			}
+		}
+		report.Moved++
+	}
+
+	// the same-named images share the old file, it is handled once
+	handled := map[string]struct{}{}
+	err = images(func(img dbo.Image) {
+		for i, cfg := range cfgs {
+			old := legacyDerivativePath(img, cfg, roots)
+			if _, ok := handled[old]; ok {
+				continue
+			}
+			if ok, _ := utils.FileExists(old); !ok {
+				continue
+			}
+			handled[old] = struct{}{}
+			if sharing[sourceName(img)] > 1 || plain[i] == nil {
+				remove(old)
+				continue
+			}
+			target := derivativePath(img, *plain[i], roots)
+			if ok, _ := utils.FileExists(target); ok {
+				remove(old)
+				continue
+			}
+			move(old, target)
+		}
+	})
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return report, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{
+		"moved":   report.Moved,
+		"removed": report.Removed,
+		"failed":  report.Failed,
+	})
+	return report, nil
+}
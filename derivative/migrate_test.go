@@ -0,0 +1,95 @@
+package derivative
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
+	fsConfig "github.com/ignisVeneficus/lumenta/config/filesystem"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/utils"
+)
+
+func TestMigratePaths(t *testing.T) {
+	testGlobalConfig()
+	small := derivativeConfig.DerivativeConfig{Name: "small", Postfix: "s", MaxWidth: 100, Formats: []string{derivativeConfig.FormatJPEG}}
+	marked := derivativeConfig.DerivativeConfig{Name: "marked", Postfix: "w", MaxWidth: 200, Formats: []string{derivativeConfig.FormatJPEG},
+		PolicyConfig: derivativeConfig.PolicyConfig{Watermark: &derivativeConfig.WatermarkConfig{Text: "(c)"}}}
+	cfgs := []derivativeConfig.DerivativeConfig{small, marked}
+
+	unique := testImage(1, "one")
+	done := testImage(2, "done")
+	sharedJPG := testImage(3, "two")
+	sharedRAW := testImage(4, "two")
+	sharedRAW.Ext = "cr2"
+	images := testImages(unique, done, sharedJPG, sharedRAW)
+
+	files := []struct {
+		name  string
+		image dbo.Image
+		cfg   derivativeConfig.DerivativeConfig
+		moved bool
+	}{
+		{"unique name", unique, small, true},
+		{"current file exists", done, small, false},
+		{"shared name", sharedJPG, small, false},
+		{"watermark only", unique, marked, false},
+	}
+
+	for _, dryRun := range []bool{false, true} {
+		t.Run(map[bool]string{false: "migrate", true: "dry run"}[dryRun], func(t *testing.T) {
+			roots := fsConfig.FilesystemConfig{Derivatives: t.TempDir()}
+			write := func(path, content string) {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatalf("setup failed: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatalf("setup failed: %v", err)
+				}
+			}
+			for _, f := range files {
+				write(legacyDerivativePath(f.image, f.cfg, roots), f.name)
+			}
+			current := derivativePath(done, small, roots)
+			write(current, "current")
+
+			report, err := migratePaths(context.Background(), images, cfgs, roots, dryRun)
+			if err != nil {
+				t.Fatalf("migrate failed: %v", err)
+			}
+			if report.Moved != 1 || report.Removed != 3 || report.Failed != 0 {
+				t.Fatalf("expected 1 moved, 3 removed, 0 failed, got %+v", report)
+			}
+
+			for _, f := range files {
+				old := legacyDerivativePath(f.image, f.cfg, roots)
+				if exists, _ := utils.FileExists(old); exists != dryRun {
+					t.Fatalf("%s: expected old file exists %v, got %v", f.name, dryRun, exists)
+				}
+				if !f.moved {
+					continue
+				}
+				content, err := os.ReadFile(derivativePath(f.image, f.cfg, roots))
+				if dryRun {
+					if err == nil {
+						t.Fatalf("%s: expected no file at the current path", f.name)
+					}
+					continue
+				}
+				if err != nil || string(content) != f.name {
+					t.Fatalf("%s: expected the old file at the current path, got %q, %v", f.name, content, err)
+				}
+			}
+			if content, err := os.ReadFile(current); err != nil || string(content) != "current" {
+				t.Fatalf("expected the current file kept, got %q, %v", content, err)
+			}
+			for _, img := range []dbo.Image{sharedJPG, sharedRAW} {
+				if exists, _ := utils.FileExists(derivativePath(img, small, roots)); exists {
+					t.Fatalf("expected no current file of the shared name %s.%s", img.Filename, img.Ext)
+				}
+			}
+		})
+	}
+}
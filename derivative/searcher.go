@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ignisVeneficus/logging"
 	authData "github.com/ignisVeneficus/lumenta/auth/data"
@@ -16,57 +17,161 @@ import (
 	"github.com/ignisVeneficus/lumenta/utils"
 )
 
-func GetDerivativesPathWithACL(c context.Context, acl authData.ACLContext, imageID uint64, cfg derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig) (string, error) {
-	logScope, ctx := logging.Enter(c, "middleware/derivatives", imageID, map[string]any{"image_id": imageID, "derivative": cfg.Name})
+// DerivativeFile is the derivative file selected for a request.
+type DerivativeFile struct {
+	Path        string
+	Format      string
+	Version     string // cache-busting version of the URL
+	Fingerprint string // of the role's variant
+	Key         Key    // the generation job of the missing file, to wait for
+}
+
+// GetDerivativesPathWithACL returns the first existing file of the formats (in order of
+// preference) and the URL version, the path of the first format if none exists.
+// The variant of the viewer's role is selected, the generation of the formats missing
+// for the current fingerprint is submitted.
+func GetDerivativesPathWithACL(c context.Context, acl authData.ACLContext, imageID uint64, cfg derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, formats []string) (DerivativeFile, error) {
+	logScope, ctx := logging.Enter(c, "middleware/derivatives", imageID, map[string]any{"image_id": imageID, "derivative": cfg.Name, "formats": formats})
+	cfg = cfg.ForRole(acl.Role)
 	db := db.GetDatabase()
 	image, err := dao.GetImageByIdACL(db, c, dbo.ImageID(imageID), acl.ACLContext)
 	if err != nil {
 		logging.ExitErr(logScope, err)
-		return "", err
+		return DerivativeFile{}, err
+	}
+	if len(formats) == 0 {
+		formats = []string{derivativeConfig.FormatJPEG}
+	}
+	file := DerivativeFile{Version: Version(image), Fingerprint: Fingerprint(image, cfg)}
+	for _, f := range formats {
+		path := derivativeFormatPath(image, cfg, roots, f)
+		if ok, _ := utils.FileExists(path); ok {
+			file.Path = path
+			file.Format = f
+			break
+		}
+	}
+	found := file.Path != ""
+	if !found {
+		file.Format = formats[0]
+		file.Path = derivativeFormatPath(image, cfg, roots, formats[0])
+	}
+
+	task, ok := derivativeTask(image, cfg, roots, true)
+	if !ok {
+		logging.Exit(logScope, "found", map[string]any{"path": file.Path})
+		return file, nil
+	}
+	job, err := newImageJob(image, roots)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return DerivativeFile{}, err
+	}
+	job.Key = task.Key
+	job.Tasks = []Task{task}
+	job.Priority = PriorityInteractive
+	job.Ctx = logging.Detach(ctx)
+	service := Get()
+	if f, failed := service.Failure(job.Key); failed && time.Since(f.At) < failureRetryAfter {
+		if found {
+			logging.Exit(logScope, "found", map[string]any{"path": file.Path})
+			return file, nil
+		}
+		err := f.Err()
+		logging.ExitErr(logScope, err)
+		return file, err
+	}
+	_, err = service.Submit(job)
+	switch {
+	case err == nil, errors.Is(err, ErrDuplicate):
+		if !found {
+			file.Key = job.Key
+		}
+	case errors.Is(err, ErrQueueFull) && found:
+		// the outdated formats are regenerated on a later request
+		logging.Exit(logScope, "found", map[string]any{"path": file.Path, "queue": "full"})
+		return file, nil
+	default:
+		logging.ExitErr(logScope, err)
+		return file, err
 	}
-	outPath := utils.ConcatGlobalDerivativePath(roots.Derivatives, image.Root, image.Path, image.Filename, cfg.Postfix, "jpg")
+	logging.Exit(logScope, "create", map[string]any{"path": file.Path, "found": found})
+	return file, nil
+}
 
-	ok, err := utils.FileExists(outPath)
-	if ok {
-		logging.Exit(logScope, "found", map[string]any{"path": outPath})
-		return outPath, nil
+// derivativePath: <name>.<ext>-<postfix>.<fingerprint>.jpg
+func derivativePath(image dbo.Image, cfg derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig) string {
+	return derivativeFormatPath(image, cfg, roots, derivativeConfig.FormatJPEG)
+}
+
+// derivativeFormatPath: <name>.<ext>-<postfix>.<fingerprint>.<format ext>,
+// every format shares the fingerprint.
+func derivativeFormatPath(image dbo.Image, cfg derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, format string) string {
+	return utils.ConcatGlobalDerivativePath(roots.Derivatives, image.Root, image.Path, image.Filename, image.Ext, cfg.Postfix+"."+Fingerprint(image, cfg), derivativeConfig.FormatExt(format))
+}
+
+// derivativeTask creates the task of every format of the derivative, keyed by
+// its jpeg path: the page requests and the bulk jobs share the generation.
+// missing: only the formats without a file, false if there is nothing to generate.
+func derivativeTask(image dbo.Image, cfg derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, missing bool) (Task, bool) {
+	task := Task{Key: Key(derivativePath(image, cfg, roots)), Mode: cfg}
+	for _, f := range cfg.Formats {
+		path := derivativeFormatPath(image, cfg, roots, f)
+		if missing {
+			if ok, _ := utils.FileExists(path); ok {
+				continue
+			}
+		}
+		task.Outputs = append(task.Outputs, Output{Format: f, Path: path})
 	}
+	return task, len(task.Outputs) > 0
+}
+
+// newImageJob creates a job of the image without tasks.
+func newImageJob(image dbo.Image, roots fsConfig.FilesystemConfig) (Job, error) {
 	imgRoot, ok := roots.Originals[image.Root]
 	if !ok {
-		err := fmt.Errorf("root not defined: %s", image.Root)
-		logging.ExitErr(logScope, err)
-		return "", err
+		return Job{}, fmt.Errorf("root not defined: %s", image.Root)
 	}
-	inPath := utils.ConcatGlobalPath(imgRoot.Root, image.Path, image.Filename, image.Ext)
 	rot := int16(0)
-	if image.Rotation != nil {
+	if image.Rotation != nil && !image.IsVideo() {
 		rot = *image.Rotation
 	}
-	imageParams := ImageParams{
-		Focus:    data.ResolveFocus(image.FocusX, image.FocusY, data.ImageFocusMode(image.FocusMode)),
-		Rotation: rot,
-	}
-	job := Job{
-		Key:        Key(outPath),
+	return Job{
 		Image:      uint64(*image.ID),
-		SourcePath: inPath,
-		Tasks: []Task{
-			Task{
-				Mode:       cfg,
-				TargetPath: outPath,
-			},
+		SourcePath: utils.ConcatGlobalPath(imgRoot.Root, image.Path, image.Filename, image.Ext),
+		Video:      image.IsVideo(),
+		ImageParams: ImageParams{
+			Focus:    data.ResolveFocus(image.FocusX, image.FocusY, data.ImageFocusMode(image.FocusMode)),
+			Rotation: rot,
 		},
-		ImageParams: imageParams,
-		Ctx:         logging.Detach(ctx),
-	}
-	service := Get()
-	ok, err = service.Submit(job)
+	}, nil
+}
+
+// ImageJob creates the job generating every given derivative of the image in
+// one decode. missing: only the derivatives without a file.
+// false if there is nothing to generate.
+func ImageJob(c context.Context, image dbo.Image, cfgs []derivativeConfig.DerivativeConfig, roots fsConfig.FilesystemConfig, missing bool) (Job, bool, error) {
+	logScope, ctx := logging.Enter(c, "derivative/job/image", image.ID, map[string]any{"image_id": image.ID, "missing": missing})
+	job, err := newImageJob(image, roots)
 	if err != nil {
-		if !errors.Is(err, ErrDuplicate) {
-			logging.ExitErr(logScope, err)
-			return outPath, err
+		logging.ExitErr(logScope, err)
+		return Job{}, false, err
+	}
+	for _, cfg := range cfgs {
+		for _, v := range cfg.Variants() {
+			if task, ok := derivativeTask(image, v, roots, missing); ok {
+				job.Tasks = append(job.Tasks, task)
+			}
 		}
 	}
-	logging.Exit(logScope, "create", map[string]any{"path": outPath})
-	return outPath, nil
+	if len(job.Tasks) == 0 {
+		logging.Exit(logScope, "nothing to do", nil)
+		return Job{}, false, nil
+	}
+	// the tasks carry the dedup keys, the job key is for the logs and the outcomes
+	job.Key = Key("image:" + job.SourcePath)
+	job.Ctx = logging.Detach(ctx)
+	logging.Exit(logScope, "ok", map[string]any{"tasks": len(job.Tasks)})
+	return job, true, nil
 }
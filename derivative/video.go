@@ -0,0 +1,159 @@
+package derivative
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/config"
+	fsConfig "github.com/ignisVeneficus/lumenta/config/filesystem"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/utils"
+)
+
+var ErrVideoDisabled = errors.New("video support is not configured")
+
+// guestVideoPostfix: the copy of a video without metadata, served to the guests
+const guestVideoPostfix = "guest"
+
+// remuxTimeout: the copy of the whole video, the streams are not re-encoded
+const remuxTimeout = 10 * time.Minute
+
+// videoMuxers: the ffmpeg muxer of a video extension, the temporary file has no usable extension
+var videoMuxers = map[string]string{
+	"mp4":  "mp4",
+	"m4v":  "mp4",
+	"mov":  "mov",
+	"qt":   "mov",
+	"webm": "webm",
+	"mkv":  "matroska",
+}
+
+// guestVideoLocks: path -> *sync.Mutex, one remux per file, the other requests wait for it
+var guestVideoLocks sync.Map
+
+// guestVideoPath: the file hash is the fingerprint, a new source gives a new copy.
+func guestVideoPath(image dbo.Image, roots fsConfig.FilesystemConfig) string {
+	return utils.ConcatGlobalDerivativePath(roots.Derivatives, image.Root, image.Path, image.Filename, image.Ext, guestVideoPostfix+"."+shortHash(image.FileHash), image.Ext)
+}
+
+// GuestVideo returns the copy of the video for the guests: the video and
+// audio streams of the source without the metadata, the location of the
+// phones included. It is made by ffmpeg on the first request and kept in the
+// derivative tree.
+func GuestVideo(c context.Context, image dbo.Image, source string, roots fsConfig.FilesystemConfig) (string, error) {
+	path := guestVideoPath(image, roots)
+	logScope, ctx := logging.Enter(c, "service/derivative/guestvideo", path, map[string]any{"source": source, "path": path})
+	lock, _ := guestVideoLocks.LoadOrStore(path, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if ok, _ := utils.FileExists(path); ok {
+		logging.Exit(logScope, "cached", nil)
+		return path, nil
+	}
+	cfg := config.Global().Sync.Video
+	if cfg == nil || cfg.ResolvedFFmpeg == "" {
+		logging.ExitErr(logScope, ErrVideoDisabled)
+		return "", ErrVideoDisabled
+	}
+	muxer, ok := videoMuxers[image.Ext]
+	if !ok {
+		err := fmt.Errorf("no muxer for video: %s", image.Ext)
+		logging.ExitErr(logScope, err)
+		return "", err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "create dirs"})
+		return "", err
+	}
+	// a crash leftover is removed by the gc
+	tmp, err := tempName(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "create file"})
+		return "", err
+	}
+	defer os.Remove(tmp)
+	_, err = runCommand(ctx, remuxTimeout, cfg.ResolvedFFmpeg,
+		"-hide_banner",
+		"-loglevel", "error",
+		"-y",
+		"-i", source,
+		"-map", "0:v",
+		"-map", "0:a?",
+		"-map_metadata", "-1",
+		"-map_chapters", "-1",
+		"-c", "copy",
+		"-f", muxer,
+		tmp,
+	)
+	if err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "remux"})
+		return "", err
+	}
+	// the temporary file is private
+	if err := os.Chmod(tmp, derivativePerm); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "chmod"})
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "rename"})
+		return "", err
+	}
+	logging.Exit(logScope, "ok", nil)
+	return path, nil
+}
+
+// extractPoster returns the poster frame of a video, decoded by ffmpeg.
+// ffmpeg applies the rotation of the video track, the frame is upright.
+func extractPoster(c context.Context, path string) (image.Image, error) {
+	logScope, ctx := logging.Enter(c, "service/derivative/task/poster", path, map[string]any{"path": path})
+	cfg := config.Global().Sync.Video
+	if cfg == nil || cfg.ResolvedFFmpeg == "" {
+		logging.ExitErr(logScope, ErrVideoDisabled)
+		return nil, ErrVideoDisabled
+	}
+
+	img, err := runFFmpeg(ctx, cfg.ResolvedFFmpeg, path, cfg.PosterAt, cfg.Timeout)
+	if err != nil && cfg.PosterAt > 0 {
+		// clip shorter than poster_at
+		logging.ErrorContinue(logScope, err, map[string]any{"at": cfg.PosterAt})
+		img, err = runFFmpeg(ctx, cfg.ResolvedFFmpeg, path, 0, cfg.Timeout)
+	}
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", nil)
+	return img, nil
+}
+
+func runFFmpeg(c context.Context, ffmpeg string, path string, at time.Duration, timeout time.Duration) (image.Image, error) {
+	raw, err := runCommand(c, timeout, ffmpeg,
+		"-hide_banner",
+		"-loglevel", "error",
+		"-ss", fmt.Sprintf("%.3f", at.Seconds()),
+		"-i", path,
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-vcodec", "png",
+		"-",
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("ffmpeg: no frame at %s", at)
+	}
+	return imaging.Decode(bytes.NewReader(raw))
+}
@@ -0,0 +1,38 @@
+package derivative
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fsConfig "github.com/ignisVeneficus/lumenta/config/filesystem"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+)
+
+func TestGuestVideo(t *testing.T) {
+	testGlobalConfig()
+	clip := testImage(1, "clip")
+	clip.Ext = "mp4"
+	clip.MediaType = dbo.MediaTypeVideo
+	roots := fsConfig.FilesystemConfig{Derivatives: t.TempDir()}
+	source := filepath.Join(t.TempDir(), "clip.mp4")
+
+	// no ffmpeg configured
+	if _, err := GuestVideo(context.Background(), clip, source, roots); !errors.Is(err, ErrVideoDisabled) {
+		t.Fatalf("expected ErrVideoDisabled, got %v", err)
+	}
+
+	cached := guestVideoPath(clip, roots)
+	if err := os.MkdirAll(filepath.Dir(cached), 0o755); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := os.WriteFile(cached, []byte("stripped"), 0o644); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	path, err := GuestVideo(context.Background(), clip, source, roots)
+	if err != nil || path != cached {
+		t.Fatalf("expected the cached copy %s, got %s, %v", cached, path, err)
+	}
+}
@@ -0,0 +1,145 @@
+package derivative
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/config"
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// watermarkMargin: the distance from the edges relative to the shorter side
+const watermarkMargin = 0.02
+
+var (
+	watermarkMu     sync.Mutex
+	watermarkImages = map[string]image.Image{} // png path -> decoded image
+)
+
+// watermarkSource returns the image of the watermark in its own size, the png
+// files are decoded once.
+func watermarkSource(w *derivativeConfig.WatermarkConfig) (image.Image, error) {
+	if w.Image == "" {
+		return renderText(w.Text), nil
+	}
+	watermarkMu.Lock()
+	defer watermarkMu.Unlock()
+	if img, ok := watermarkImages[w.Image]; ok {
+		return img, nil
+	}
+	img, err := imaging.Open(w.Image)
+	if err != nil {
+		return nil, err
+	}
+	watermarkImages[w.Image] = img
+	return img, nil
+}
+
+// renderText draws the text in white with a dark outline, in the bitmap font
+// scaled up later: no vector font rasterizer is available.
+func renderText(text string) image.Image {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil() + 2
+	height := face.Height + 2
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	d := font.Drawer{Dst: img, Face: face}
+	drawAt := func(x, y int, c color.Color) {
+		d.Src = image.NewUniform(c)
+		d.Dot = fixed.P(x, y+face.Ascent)
+		d.DrawString(text)
+	}
+	for _, off := range [][2]int{{0, 1}, {2, 1}, {1, 0}, {1, 2}} {
+		drawAt(off[0], off[1], color.NRGBA{0, 0, 0, 160})
+	}
+	drawAt(1, 1, color.White)
+	return img
+}
+
+func watermarkOrigin(pos derivativeConfig.WatermarkPosition, canvas image.Rectangle, size image.Point, margin int) image.Point {
+	left := canvas.Min.X + margin
+	right := canvas.Max.X - margin - size.X
+	top := canvas.Min.Y + margin
+	bottom := canvas.Max.Y - margin - size.Y
+	switch pos {
+	case derivativeConfig.WatermarkTopLeft:
+		return image.Pt(left, top)
+	case derivativeConfig.WatermarkTopRight:
+		return image.Pt(right, top)
+	case derivativeConfig.WatermarkBottomLeft:
+		return image.Pt(left, bottom)
+	case derivativeConfig.WatermarkCenter:
+		return image.Pt(canvas.Min.X+(canvas.Dx()-size.X)/2, canvas.Min.Y+(canvas.Dy()-size.Y)/2)
+	default:
+		return image.Pt(right, bottom)
+	}
+}
+
+// applyWatermark draws the watermark on the resized derivative.
+func applyWatermark(c context.Context, img image.Image, w *derivativeConfig.WatermarkConfig) (image.Image, error) {
+	if w == nil {
+		return img, nil
+	}
+	logScope, _ := logging.Enter(c, "service/derivative/task/watermark", nil, map[string]any{"text": w.Text, "image": w.Image})
+	mark, err := watermarkSource(w)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return img, err
+	}
+	b := img.Bounds()
+	width := int(math.Round(float64(b.Dx()) * w.Scale))
+	if width < 1 {
+		logging.Exit(logScope, "too small", nil)
+		return img, nil
+	}
+	mark = imaging.Resize(mark, width, 0, imaging.Lanczos)
+	margin := int(math.Round(float64(min(b.Dx(), b.Dy())) * watermarkMargin))
+
+	dst := imaging.Clone(img)
+	origin := watermarkOrigin(w.Position, dst.Bounds(), mark.Bounds().Size(), margin)
+	alpha := image.NewUniform(color.Alpha{A: uint8(math.Round(w.Opacity * 255))})
+	draw.DrawMask(dst, image.Rectangle{Min: origin, Max: origin.Add(mark.Bounds().Size())}, mark, mark.Bounds().Min, alpha, image.Point{}, draw.Over)
+	logging.Exit(logScope, "ok", nil)
+	return dst, nil
+}
+
+// copyExif copies the whitelisted tags of the original to the temporary file
+// of a derivative, the exclusions (--TAG) after them are passed as they are.
+// It runs before the file is renamed into place, a failure fails the output:
+// a derivative is never served without its policy (e.g. with GPS for the guests).
+// exiftool detects the file type from the content, the .tmp name is no problem.
+func copyExif(c context.Context, source string, tags []string, path string) error {
+	if source == "" || len(tags) == 0 {
+		return nil
+	}
+	logScope, ctx := logging.Enter(c, "service/derivative/task/exif", source, map[string]any{"path": source, "tags": tags})
+	args := []string{"-q", "-q", "-overwrite_original", "-TagsFromFile", source}
+	for _, t := range tags {
+		switch {
+		case strings.HasPrefix(t, "--"):
+			args = append(args, t)
+			continue
+		case t == derivativeConfig.ExifGPS:
+			t = "GPS:all"
+		}
+		args = append(args, "-"+t)
+	}
+	args = append(args, path)
+	if _, err := runCommand(ctx, previewTimeout, config.Global().Sync.Exiftool.ResolvedPath, args...); err != nil {
+		err = fmt.Errorf("exif policy: %w", err)
+		logging.ExitErrParams(logScope, err, map[string]any{"tags": strings.Join(tags, ",")})
+		return err
+	}
+	logging.Exit(logScope, "ok", nil)
+	return nil
+}
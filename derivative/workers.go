@@ -2,6 +2,7 @@ package derivative
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"math"
@@ -14,7 +15,9 @@ import (
 	"github.com/ignisVeneficus/lumenta/data"
 )
 
-func applyTask(c context.Context, t Task, img image.Image, focus data.Focus, jpgQuality int) error {
+// applyTask resizes, watermarks and writes the derivative.
+// source: the original for the EXIF copy, empty for the videos.
+func applyTask(c context.Context, t Task, img image.Image, focus data.Focus, jpgQuality int, source string) error {
 	logScope, ctx := logging.Enter(c, "service/derivative/task/resize", nil, map[string]any{"task": t})
 
 	targetW := t.Mode.MaxWidth
@@ -37,8 +40,30 @@ func applyTask(c context.Context, t Task, img image.Image, focus data.Focus, jpg
 		logging.ExitErr(logScope, err)
 		return err
 	}
-	if err := writeImage(ctx, img, t.TargetPath, jpgQuality); err != nil {
+	img, err = applyWatermark(ctx, img, t.Mode.Watermark)
+	if err != nil {
 		logging.ExitErr(logScope, err)
+		return err
+	}
+	// the exif policy is applied to the temporary files, before they are served
+	exif := func(tmp string) error {
+		return copyExif(ctx, source, t.Mode.Exif, tmp)
+	}
+	var errs []error
+	for _, o := range t.Outputs {
+		var err error
+		if o.Format == derivativeConfig.FormatJPEG {
+			err = writeImage(ctx, img, o.Path, jpgQuality, exif)
+		} else {
+			err = writeEncoded(ctx, img, o, jpgQuality, exif)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", o.Format, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		logging.ExitErr(logScope, err)
+		return err
 	}
 	logging.Exit(logScope, "ok", nil)
 	return nil
@@ -47,8 +72,9 @@ func applyTask(c context.Context, t Task, img image.Image, focus data.Focus, jpg
 func GenerateDerivativeStep(j *Job) error {
 	logScope, ctx := logging.Enter(j.Ctx, "service/derivative/task/worker", j.SourcePath, map[string]any{"job": j})
 
-	img, err := imaging.Open(j.SourcePath, imaging.AutoOrientation(false))
+	img, decoder, err := openSource(ctx, j)
 	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrDecodeFailed, err)
 		logging.ExitErrParams(logScope, err, map[string]any{"path": j.SourcePath, "step": "open"})
 		return err
 	}
@@ -57,17 +83,35 @@ func GenerateDerivativeStep(j *Job) error {
 	// FIXME: Need this rotate? normal focus, don't, auto???
 	j.ImageParams.Focus.Rotate(j.ImageParams.Rotation)
 
+	source := j.SourcePath
+	if j.Video {
+		source = ""
+	}
+	var errs []error
 	for _, t := range j.Tasks {
-		err := applyTask(ctx, t, img, j.ImageParams.Focus, t.Mode.JPGQuality)
+		err := applyTask(ctx, t, img, j.ImageParams.Focus, t.Mode.JPGQuality, source)
 		if err != nil {
 			logging.ErrorContinue(logScope, err, map[string]any{"task": t.Mode.Name})
+			errs = append(errs, fmt.Errorf("%s: %w", t.Mode.Name, err))
 		}
 	}
+	if err := errors.Join(errs...); err != nil {
+		logging.ExitErrParams(logScope, err, map[string]any{"decoder": decoder})
+		return err
+	}
 
-	logging.Exit(logScope, "ok", nil)
+	logging.Exit(logScope, "ok", map[string]any{"decoder": decoder})
 	return nil
 }
 
+func openSource(c context.Context, j *Job) (image.Image, DecodePath, error) {
+	if j.Video {
+		img, err := extractPoster(c, j.SourcePath)
+		return img, DecodeVideo, err
+	}
+	return decodeManaged(c, j)
+}
+
 func applyRotation(img image.Image, deg int16) image.Image {
 	switch ((deg % 360) + 360) % 360 {
 	case 90:
@@ -168,22 +212,27 @@ func resizeCrop(img image.Image, targetW, targetH int, focus data.Focus) image.I
 	return cropToTarget(img, targetW, targetH, focus)
 }
 
-func writeImage(c context.Context, img image.Image, path string, jpgQuality int) error {
-	logScope, _ := logging.Enter(c, "service/derivative/task/write", path, map[string]any{"path": path})
+// derivativePerm: the mode of the written derivatives
+const derivativePerm = 0644
 
-	tmp := path + ".tmp"
+// writeImage encodes the jpeg to a temporary file, finish runs on it before
+// it is renamed to the path; a failed finish removes it.
+func writeImage(c context.Context, img image.Image, path string, jpgQuality int, finish func(tmp string) error) error {
+	logScope, _ := logging.Enter(c, "service/derivative/task/write", path, map[string]any{"path": path})
 
-	dir := filepath.Dir(tmp)
+	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		logging.ExitErrParams(logScope, err, map[string]any{"step": "create dirs"})
 		return err
 	}
-	out, err := os.Create(tmp)
+	// every writer has its own temporary file, a crash leftover is removed by the gc
+	out, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
 	if err != nil {
 		logging.ExitErrParams(logScope, err, map[string]any{"step": "create file"})
 		return err
 	}
-	if err := imaging.Encode(out, img, imaging.JPEG, imaging.JPEGQuality(jpgQuality)); err != nil {
+	tmp := out.Name()
+	if err := encodeJPEG(out, img, jpgQuality); err != nil {
 		out.Close()
 		os.Remove(tmp)
 		logging.ExitErrParams(logScope, err, map[string]any{"step": "write"})
@@ -200,6 +249,17 @@ func writeImage(c context.Context, img image.Image, path string, jpgQuality int)
 		logging.ExitErrParams(logScope, err, map[string]any{"step": "close"})
 		return err
 	}
+	if err := finish(tmp); err != nil {
+		os.Remove(tmp)
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "finish"})
+		return err
+	}
+	// the temporary file is private
+	if err := os.Chmod(tmp, derivativePerm); err != nil {
+		os.Remove(tmp)
+		logging.ExitErrParams(logScope, err, map[string]any{"step": "chmod"})
+		return err
+	}
 	if err := os.Rename(tmp, path); err != nil {
 		os.Remove(tmp)
 		logging.ExitErrParams(logScope, err, map[string]any{"step": "rename"})
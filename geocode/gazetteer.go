@@ -0,0 +1,271 @@
+package geocode
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ignisVeneficus/logging"
+	"github.com/rs/zerolog"
+)
+
+const earthRadiusKm = 6371.0
+
+// Place is one populated place of the gazetteer, with the region and
+// country names already resolved.
+type Place struct {
+	Name        string
+	CountryCode string
+	Country     string
+	Region      string
+	Timezone    string
+	Latitude    float64
+	Longitude   float64
+	Population  uint64
+}
+
+func (p *Place) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Level) {
+	if level <= zerolog.DebugLevel {
+		e.Str("name", p.Name).
+			Str("country", p.Country).
+			Str("region", p.Region)
+	}
+	if level == zerolog.TraceLevel {
+		e.Str("country_code", p.CountryCode).
+			Str("timezone", p.Timezone).
+			Float64("latitude", p.Latitude).
+			Float64("longitude", p.Longitude).
+			Uint64("population", p.Population)
+	}
+}
+
+type cellKey struct {
+	lat int
+	lon int
+}
+
+// Gazetteer is an in-memory, read-only index of a GeoNames style dump.
+// Places are bucketed into 1x1 degree cells, a lookup only scans the cells
+// inside the search radius.
+type Gazetteer struct {
+	places []Place
+	cells  map[cellKey][]int
+}
+
+// Load reads the GeoNames files:
+//   - citiesFile: tab separated "cities" dump (e.g. cities1000.txt), required
+//   - admin1File: admin1CodesASCII.txt, optional, used for region names
+//   - countryFile: countryInfo.txt, optional, used for country names
+//
+// Missing optional files fall back to the raw codes.
+func Load(c context.Context, citiesFile, admin1File, countryFile string) (*Gazetteer, error) {
+	logScope, _ := logging.Enter(c, "geocode/load", citiesFile, map[string]any{
+		"cities":    citiesFile,
+		"admin1":    admin1File,
+		"countries": countryFile,
+	})
+	countries := map[string]string{}
+	if countryFile != "" {
+		var err error
+		countries, err = readCodeNames(countryFile, 0, 4)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return nil, err
+		}
+	}
+	regions := map[string]string{}
+	if admin1File != "" {
+		var err error
+		regions, err = readCodeNames(admin1File, 0, 1)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(citiesFile)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	defer f.Close()
+
+	g := &Gazetteer{
+		places: make([]Place, 0, 1024),
+		cells:  make(map[cellKey][]int),
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	skipped := 0
+	for scanner.Scan() {
+		line++
+		p, ok := parseCityLine(scanner.Text(), countries, regions)
+		if !ok {
+			skipped++
+			continue
+		}
+		idx := len(g.places)
+		g.places = append(g.places, p)
+		key := cellOf(p.Latitude, p.Longitude)
+		g.cells[key] = append(g.cells[key], idx)
+	}
+	if err := scanner.Err(); err != nil {
+		err = fmt.Errorf("%s line %d: %w", citiesFile, line, err)
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	if len(g.places) == 0 {
+		err := fmt.Errorf("%s: no places found", citiesFile)
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", map[string]any{
+		"places":  len(g.places),
+		"skipped": skipped,
+	})
+	return g, nil
+}
+
+// Len returns the number of indexed places.
+func (g *Gazetteer) Len() int {
+	return len(g.places)
+}
+
+// Lookup returns the nearest place within maxDistanceKm and its distance in km.
+func (g *Gazetteer) Lookup(lat, lon float64, maxDistanceKm float64) (Place, float64, bool) {
+	if g == nil || maxDistanceKm <= 0 {
+		return Place{}, 0, false
+	}
+	center := cellOf(lat, lon)
+	latSpan := int(math.Ceil(maxDistanceKm / 111.0))
+	lonSpan := 180
+	if cos := math.Cos(lat * math.Pi / 180); cos > 0.01 {
+		lonSpan = min(int(math.Ceil(maxDistanceKm/(111.0*cos))), 180)
+	}
+
+	best := -1
+	bestDist := maxDistanceKm
+	for dLat := -latSpan; dLat <= latSpan; dLat++ {
+		cLat := center.lat + dLat
+		if cLat < -90 || cLat > 90 {
+			continue
+		}
+		for dLon := -lonSpan; dLon <= lonSpan; dLon++ {
+			cLon := wrapLon(center.lon + dLon)
+			for _, idx := range g.cells[cellKey{lat: cLat, lon: cLon}] {
+				p := g.places[idx]
+				d := Distance(lat, lon, p.Latitude, p.Longitude)
+				if d <= bestDist {
+					best = idx
+					bestDist = d
+				}
+			}
+		}
+	}
+	if best < 0 {
+		return Place{}, 0, false
+	}
+	return g.places[best], bestDist, true
+}
+
+// Distance returns the great-circle distance between two WGS84 points in km.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func cellOf(lat, lon float64) cellKey {
+	return cellKey{
+		lat: int(math.Floor(lat)),
+		lon: wrapLon(int(math.Floor(lon))),
+	}
+}
+
+func wrapLon(lon int) int {
+	for lon < -180 {
+		lon += 360
+	}
+	for lon >= 180 {
+		lon -= 360
+	}
+	return lon
+}
+
+// parseCityLine parses one line of the GeoNames "geoname" table:
+// geonameid, name, asciiname, alternatenames, latitude, longitude,
+// feature class, feature code, country code, cc2, admin1 code, admin2 code,
+// admin3 code, admin4 code, population, elevation, dem, timezone, modification date
+func parseCityLine(line string, countries, regions map[string]string) (Place, bool) {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Place{}, false
+	}
+	cols := strings.Split(line, "\t")
+	if len(cols) < 15 {
+		return Place{}, false
+	}
+	lat, err := strconv.ParseFloat(cols[4], 64)
+	if err != nil {
+		return Place{}, false
+	}
+	lon, err := strconv.ParseFloat(cols[5], 64)
+	if err != nil {
+		return Place{}, false
+	}
+	p := Place{
+		Name:        cols[1],
+		CountryCode: cols[8],
+		Country:     cols[8],
+		Region:      cols[10],
+		Latitude:    lat,
+		Longitude:   lon,
+	}
+	if name, ok := countries[p.CountryCode]; ok {
+		p.Country = name
+	}
+	if name, ok := regions[p.CountryCode+"."+cols[10]]; ok {
+		p.Region = name
+	}
+	if pop, err := strconv.ParseUint(cols[14], 10, 64); err == nil {
+		p.Population = pop
+	}
+	if len(cols) > 17 {
+		p.Timezone = cols[17]
+	}
+	return p, true
+}
+
+// readCodeNames reads a tab separated code -> name table, skipping comments.
+func readCodeNames(path string, codeCol, nameCol int) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		if len(cols) <= codeCol || len(cols) <= nameCol {
+			continue
+		}
+		ret[cols[codeCol]] = cols[nameCol]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,196 @@
+package gpx
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ignisVeneficus/logging"
+	"github.com/rs/zerolog"
+)
+
+type gpxFile struct {
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Segments []struct {
+			Points []struct {
+				Lat  float64 `xml:"lat,attr"`
+				Lon  float64 `xml:"lon,attr"`
+				Time string  `xml:"time"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type point struct {
+	time    time.Time
+	lat     float64
+	lon     float64
+	segment int
+}
+
+// Fix is a position derived from the tracks.
+type Fix struct {
+	Latitude     float64
+	Longitude    float64
+	Track        string        // gpx file, relative to the configured directory
+	Gap          time.Duration // distance in time from the nearest track point
+	Interpolated bool
+}
+
+func (f *Fix) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog.Level) {
+	if level <= zerolog.DebugLevel {
+		e.Str("track", f.Track).
+			Float64("latitude", f.Latitude).
+			Float64("longitude", f.Longitude).
+			Dur("gap", f.Gap).
+			Bool("interpolated", f.Interpolated)
+	}
+}
+
+// Tracks holds every track point of a GPX directory ordered by time.
+// Each track segment gets its own id, interpolation never crosses segments.
+type Tracks struct {
+	points   []point
+	segments []string // segment id -> track file
+}
+
+// LoadDir reads every .gpx file under dir (recursive).
+// Unreadable files are logged and skipped.
+func LoadDir(c context.Context, dir string) (*Tracks, error) {
+	logScope, _ := logging.Enter(c, "gpx/load", dir, map[string]any{"dir": dir})
+	t := &Tracks{}
+	files := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".gpx") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		if err := t.addFile(path, filepath.ToSlash(rel)); err != nil {
+			logging.ErrorContinue(logScope, err, map[string]any{"file": path})
+			return nil
+		}
+		files++
+		return nil
+	})
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	sort.SliceStable(t.points, func(i, j int) bool {
+		return t.points[i].time.Before(t.points[j].time)
+	})
+	logging.Exit(logScope, "ok", map[string]any{
+		"files":    files,
+		"segments": len(t.segments),
+		"points":   len(t.points),
+	})
+	return t, nil
+}
+
+func (t *Tracks) addFile(path, name string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc gpxFile
+	if err := xml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, trk := range doc.Tracks {
+		for _, seg := range trk.Segments {
+			id := len(t.segments)
+			t.segments = append(t.segments, name)
+			for _, p := range seg.Points {
+				ts, err := time.Parse(time.RFC3339, strings.TrimSpace(p.Time))
+				if err != nil {
+					continue
+				}
+				t.points = append(t.points, point{
+					time:    ts.UTC(),
+					lat:     p.Lat,
+					lon:     p.Lon,
+					segment: id,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// Len returns the number of loaded track points.
+func (t *Tracks) Len() int {
+	return len(t.points)
+}
+
+// Locate returns the position at the given time.
+// Between two points of the same segment, not more than maxGap apart, the
+// position is linearly interpolated. Otherwise the nearest point is used if it is
+// within maxGap.
+func (t *Tracks) Locate(at time.Time, maxGap time.Duration) (Fix, bool) {
+	if t == nil || len(t.points) == 0 {
+		return Fix{}, false
+	}
+	at = at.UTC()
+	idx := sort.Search(len(t.points), func(i int) bool {
+		return !t.points[i].time.Before(at)
+	})
+	var before, after *point
+	if idx < len(t.points) {
+		after = &t.points[idx]
+	}
+	if idx > 0 {
+		before = &t.points[idx-1]
+	}
+	if after != nil && after.time.Equal(at) {
+		return t.fix(*after, after.lat, after.lon, 0, false), true
+	}
+
+	if before != nil && after != nil && before.segment == after.segment &&
+		after.time.Sub(before.time) <= maxGap {
+		span := after.time.Sub(before.time)
+		ratio := float64(at.Sub(before.time)) / float64(span)
+		gap := min(at.Sub(before.time), after.time.Sub(at))
+		return t.fix(*before,
+			before.lat+(after.lat-before.lat)*ratio,
+			before.lon+(after.lon-before.lon)*ratio,
+			gap, true), true
+	}
+
+	var nearest *point
+	var gap time.Duration
+	if before != nil {
+		nearest = before
+		gap = at.Sub(before.time)
+	}
+	if after != nil && (nearest == nil || after.time.Sub(at) < gap) {
+		nearest = after
+		gap = after.time.Sub(at)
+	}
+	if nearest == nil || gap > maxGap {
+		return Fix{}, false
+	}
+	return t.fix(*nearest, nearest.lat, nearest.lon, gap, false), true
+}
+
+func (t *Tracks) fix(p point, lat, lon float64, gap time.Duration, interpolated bool) Fix {
+	return Fix{
+		Latitude:     lat,
+		Longitude:    lon,
+		Track:        t.segments[p.segment],
+		Gap:          gap,
+		Interpolated: interpolated,
+	}
+}
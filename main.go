@@ -37,7 +37,7 @@ func main() {
 		panic(err)
 	}
 
-	derivative.Init(ctx, 10)
+	derivative.Init(ctx, cfg.Derivatives.Workers, cfg.Derivatives.QueueSize)
 	defer derivative.Shutdown(ctx)
 
 	database := db.GetDatabaseMulti()
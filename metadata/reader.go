@@ -76,6 +76,7 @@ func ExtractMetadata(exiftool *exif.PersistentExiftool, c context.Context, paths
 			}
 		}
 	}
+	applyOffsetTime(metadata)
 	logging.Exit(logScope, "ok", nil)
 	return metadata, nil
 }
@@ -118,7 +119,7 @@ func resolveField(c context.Context, alias string, field metadataConfig.Metadata
 			}
 		}
 
-		val, err := coerceType(value, field.Type)
+		val, err := CoerceType(value, field.Type)
 		if err != nil {
 			logging.ErrorContinue(logScope, err, map[string]any{
 				"alias": alias,
@@ -128,6 +129,10 @@ func resolveField(c context.Context, alias string, field metadataConfig.Metadata
 			})
 			continue
 		}
+		if t, ok := val.(time.Time); ok && data.IsFloating(t) && strings.HasPrefix(ref, "quicktime:") {
+			// QuickTime dates are UTC by the specification
+			val = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), data.FloatingUTCZone)
+		}
 		logging.Exit(logScope, "ok", nil)
 		return data.MetadataValue{
 			Alias:  alias,
@@ -142,7 +147,8 @@ func resolveField(c context.Context, alias string, field metadataConfig.Metadata
 	return data.MetadataValue{}, false
 }
 
-func coerceType(v any, t data.MetadataType) (any, error) {
+// CoerceType converts a raw value to the metadata type, an empty type keeps the value.
+func CoerceType(v any, t data.MetadataType) (any, error) {
 	if t == "" {
 		return v, nil
 	}
@@ -186,7 +192,12 @@ func coerceType(v any, t data.MetadataType) (any, error) {
 			return x, nil
 		case string:
 			for _, timeFormat := range timeFormats {
-				if t, err := time.Parse(timeFormat, x); err == nil {
+				loc := time.UTC
+				if strings.HasSuffix(timeFormat, "05") {
+					// no offset in the value: floating, resolved by the timezone step
+					loc = data.FloatingZone
+				}
+				if t, err := time.ParseInLocation(timeFormat, x, loc); err == nil {
 					return t, nil
 				}
 			}
@@ -197,6 +208,52 @@ func coerceType(v any, t data.MetadataType) (any, error) {
 
 	return nil, fmt.Errorf("cannot coerce %T to %s", v, t)
 }
+
+// applyOffsetTime sets the timezone of taken_at from the metadata:
+// an offset in the value itself (e.g. composite:SubSecDateTimeOriginal) or OffsetTimeOriginal.
+func applyOffsetTime(m data.Metadata) {
+	tv, ok := m[data.MetaTakenAt]
+	if !ok {
+		return
+	}
+	t, ok := tv.Value.(time.Time)
+	if !ok {
+		return
+	}
+	if !data.IsFloating(t) {
+		m[data.MetaTakenAtTZ] = data.MetadataValue{
+			Alias:  data.MetaTakenAtTZ,
+			Ref:    tv.Ref,
+			Type:   data.MetaString,
+			Value:  t.Format("-07:00"),
+			Source: tv.Source,
+		}
+		return
+	}
+	ov, ok := m[data.MetaOffsetTime]
+	if !ok {
+		return
+	}
+	s, ok := ov.Value.(string)
+	if !ok {
+		return
+	}
+	ot, err := time.Parse("-07:00", strings.TrimSpace(s))
+	if err != nil {
+		return
+	}
+	_, offset := ot.Zone()
+	tv.Value = data.InZone(t, time.FixedZone("", offset))
+	m[data.MetaTakenAt] = tv
+	m[data.MetaTakenAtTZ] = data.MetadataValue{
+		Alias:  data.MetaTakenAtTZ,
+		Ref:    ov.Ref,
+		Type:   data.MetaString,
+		Value:  ot.Format("-07:00"),
+		Source: ov.Source,
+	}
+}
+
 func isMeaningfulValue(v any) bool {
 	switch x := v.(type) {
 	case nil:
@@ -11,11 +11,15 @@ import (
 	"time"
 
 	"github.com/ignisVeneficus/logging"
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
 	fileConfig "github.com/ignisVeneficus/lumenta/config/filesystem"
 	syncConfig "github.com/ignisVeneficus/lumenta/config/sync"
 	"github.com/ignisVeneficus/lumenta/data"
 	"github.com/ignisVeneficus/lumenta/db/dao"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/derivative"
+	"github.com/ignisVeneficus/lumenta/geocode"
+	"github.com/ignisVeneficus/lumenta/gpx"
 	"github.com/ignisVeneficus/lumenta/mapper"
 	"github.com/ignisVeneficus/lumenta/ruleengine"
 	"github.com/rs/zerolog"
@@ -44,6 +48,7 @@ type WorkItem struct {
 	MetadataFile string      // metadata filename
 	Ext          string      // normalized extension (without dot)
 	Info         os.FileInfo // filesystem stat info
+	MediaType    dbo.MediaType
 
 	// =========================================================
 	// DATABASE PRECHECK (path-based lookup)
@@ -53,6 +58,8 @@ type WorkItem struct {
 	// Exists in db => DBImage.ID not null
 	DBImage *dbo.Image // persisted DB object (or nil if skipped)
 	Albums  ruleengine.AlbumsStruct
+	// bound, but after the album limit: not in Albums, the rules do not see it
+	OverLimitAlbums map[uint64]struct{}
 
 	// =========================================================
 	// CACHED DATA (from one of table)
@@ -86,6 +93,11 @@ type WorkItem struct {
 	Metadata data.Metadata
 	Panorama bool
 
+	// =========================================================
+	// GPX TRACK CORRELATION
+	// =========================================================
+	GPSTrack string // matched track file, empty if the position is not from gpx
+
 	// =========================================================
 	// RULE ENGINE RESULTS
 	// =========================================================
@@ -106,6 +118,7 @@ func (w *WorkItem) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolog
 			Str("real_path", w.RealPath).
 			Str("filename", w.Filename).
 			Str("ext", w.Ext).
+			Str("media_type", string(w.MediaType)).
 			Str("source", string(w.Source))
 
 	}
@@ -157,14 +170,28 @@ type PipelineContext struct {
 	ExifToolConfig syncConfig.ExiftoolConfig
 	Workers        map[syncConfig.StepName]syncConfig.StepConfig
 	Panorama       *ruleengine.RuleGroup
+	Geocode        *syncConfig.GeocodeConfig
+	GPX            *syncConfig.GPXConfig
+	Timezone       *syncConfig.TimezoneConfig
+	Video          *syncConfig.VideoConfig
+	Overrides      []syncConfig.OverrideConfig
 	ACLRules       syncConfig.ACLRules
 	ACLOverride    bool
+	Filesystem     fileConfig.FilesystemConfig
+	// sizes generated by the derivative pregeneration
+	DerivativeSizes []derivativeConfig.DerivativeConfig
 
 	// =========================================================
 	// Sync related data
 	// =========================================================
-	SyncId dbo.SyncRunID
-	Force  bool
+	SyncId    dbo.SyncRunID
+	Force     bool
+	StartedAt time.Time // evaluation time of the relative rules
+	// derivative pregeneration of the run, nil: off
+	Derivatives *derivative.Batch
+	// loaded once per run, used by their steps and by the overrides, nil: off
+	Tracks    *gpx.Tracks
+	Gazetteer *geocode.Gazetteer
 
 	// =========================================================
 	// Album struct
@@ -233,20 +260,37 @@ func createImageFact(job WorkItem) ruleengine.ImageFacts {
 		rating = int(*job.Metadata.GetRating())
 	}
 	return ruleengine.ImageFacts{
-		Root:     job.RootName,
-		Path:     job.Path,
-		Filename: job.Filename,
-		Ext:      job.Ext,
-		TakenAt:  job.Metadata.GetTakenAt(),
-		Rating:   &rating,
-		Tags:     job.Metadata.GetTags(),
-		Width:    job.Metadata.GetWidth(),
-		Height:   job.Metadata.GetHeight(),
-		Albums:   job.Albums,
+		Root:      job.RootName,
+		Path:      job.Path,
+		Filename:  job.Filename,
+		Ext:       job.Ext,
+		TakenAt:   job.Metadata.GetTakenAt(),
+		Rating:    &rating,
+		Tags:      collectTags(job.Metadata),
+		Width:     job.Metadata.GetWidth(),
+		Height:    job.Metadata.GetHeight(),
+		Albums:    job.Albums,
+		Latitude:  job.Metadata.GetLatitude(),
+		Longitude: job.Metadata.GetLongitude(),
+		Metadata:  job.Metadata,
 	}
 
 }
 
+// collectTags merges the source tags, the generated location tags and the override tags
+func collectTags(metadata data.Metadata) []string {
+	tags := metadata.GetTags()
+	placeTags := metadata.GetPlaceTags()
+	overrideTags := metadata.GetOverrideTags()
+	if len(placeTags) == 0 && len(overrideTags) == 0 {
+		return tags
+	}
+	ret := make([]string, 0, len(tags)+len(placeTags)+len(overrideTags))
+	ret = append(ret, tags...)
+	ret = append(ret, placeTags...)
+	return append(ret, overrideTags...)
+}
+
 type AlbumRule struct {
 	Name      string
 	PathIDs   []uint64
@@ -256,6 +300,13 @@ type AlbumRule struct {
 	Depth     int
 	RankOrder []uint64
 	Rank      uint64
+	// the result may change without a change of the image
+	TimeDependent  bool
+	AlbumDependent bool
+	// the album has an image limit: a new image stays hidden until the reorder
+	Limited bool
+	// the image order, and so the images within the limit, change by the day
+	DailyOrder bool
 	//	Path     string
 }
 
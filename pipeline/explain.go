@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ignisVeneficus/logging"
+	syncConfig "github.com/ignisVeneficus/lumenta/config/sync"
+	"github.com/ignisVeneficus/lumenta/db/dao"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
+)
+
+// ExplainEntry is the evaluation of one album rule or one ACL rule.
+type ExplainEntry struct {
+	AlbumID   *uint64 `json:"album_id,omitempty"`
+	AlbumName string  `json:"album_name,omitempty"`
+	// the image is in the album now
+	Bound *bool `json:"bound,omitempty"`
+	// the image is bound, but after the album limit, so it is not in the album
+	OverLimit bool `json:"over_limit,omitempty"`
+
+	Role  dbo.ACLRole     `json:"role,omitempty"`
+	User  *string         `json:"user,omitempty"`
+	Level *dbo.DBACLLevel `json:"acl_level,omitempty"`
+	// the first matching ACL rule sets the level
+	Applied bool `json:"applied,omitempty"`
+
+	Match  bool                       `json:"match"`
+	Result ruleengine.GroupRuleResult `json:"result"`
+}
+
+// ExplainResult is the evaluation of the current rules on a stored image.
+type ExplainResult struct {
+	ImageID     dbo.ImageID               `json:"image_id"`
+	Evaluation  ruleengine.RuleEvaluation `json:"evaluation"`
+	EvaluatedAt time.Time                 `json:"evaluated_at"`
+	Entries     []ExplainEntry            `json:"entries"`
+}
+
+// ExplainImageAlbums runs the album rules on the image in the order of the
+// sync, albumID limits the result to one album (nil: every album with rule).
+func ExplainImageAlbums(database *sql.DB, c context.Context, imageID dbo.ImageID, albumID *uint64) (*ExplainResult, error) {
+	logScope, ctx := logging.Enter(c, "sync/explain/albums", imageID, map[string]any{
+		"image_id": imageID,
+		"album_id": albumID,
+	})
+	image, err := dao.GetImageByID(database, ctx, imageID)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	albumCtx, err := collectAlbums(database, ctx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	if albumID != nil {
+		if _, ok := albumCtx.NameMap[*albumID]; !ok {
+			err := dao.GetDataNotFoundError("album")
+			logging.ExitErr(logScope, err)
+			return nil, err
+		}
+	}
+	bindings, err := dao.QueryAlbumBindingsByImageID(database, ctx, imageID)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	bound, overLimit := splitAlbumBindings(bindings)
+	boundSet := make(map[uint64]struct{}, len(bound))
+	for _, id := range bound {
+		boundSet[uint64(id)] = struct{}{}
+	}
+
+	ret := &ExplainResult{
+		ImageID:     imageID,
+		Evaluation:  ruleengine.EvaluationAlbum,
+		EvaluatedAt: time.Now(),
+		Entries:     []ExplainEntry{},
+	}
+	facts := createDBImageFact(image, bound, &PipelineContext{AlbumCtx: albumCtx})
+	ruleCtx := ruleengine.RuleContext{
+		NameMap: albumCtx.NameMap,
+		Now:     ret.EvaluatedAt,
+	}
+	// same as the album insertion worker: later rules see the membership
+	// changes of the earlier ones
+	for _, ar := range albumCtx.Rules {
+		if ar.Rule == nil {
+			continue
+		}
+		rctx := ruleCtx
+		rctx.RefAlbum = &ar.ID
+		match, result := ar.Rule(facts, &rctx)
+		_, found := facts.Albums[ar.ID]
+		_, hidden := overLimit[ar.ID]
+		if found && !match {
+			delete(facts.Albums, ar.ID)
+		}
+		if !found && !hidden && match && !ar.Limited {
+			if as, ok := albumCtx.AlbumStructs[ar.ID]; ok {
+				if facts.Albums == nil {
+					facts.Albums = make(ruleengine.AlbumsStruct)
+				}
+				facts.Albums[ar.ID] = as
+			}
+		}
+		if albumID != nil && *albumID != ar.ID {
+			continue
+		}
+		id := ar.ID
+		_, isBound := boundSet[id]
+		ret.Entries = append(ret.Entries, ExplainEntry{
+			AlbumID:   &id,
+			AlbumName: albumCtx.NameMap[id],
+			Bound:     &isBound,
+			OverLimit: hidden,
+			Match:     match,
+			Result:    result,
+		})
+	}
+	logging.Exit(logScope, "ok", map[string]any{"entries": len(ret.Entries)})
+	return ret, nil
+}
+
+// ExplainImageACL runs every ACL rule of the sync config on the image, the
+// first match is marked as applied.
+func ExplainImageACL(database *sql.DB, c context.Context, acls syncConfig.ACLRules, imageID dbo.ImageID) (*ExplainResult, error) {
+	logScope, ctx := logging.Enter(c, "sync/explain/acl", imageID, map[string]any{
+		"image_id": imageID,
+	})
+	image, err := dao.GetImageByID(database, ctx, imageID)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	ret := &ExplainResult{
+		ImageID:     imageID,
+		Evaluation:  ruleengine.EvaluationACL,
+		EvaluatedAt: time.Now(),
+		Entries:     []ExplainEntry{},
+	}
+	// acl rules do not use the albums
+	facts := createDBImageFact(image, nil, &PipelineContext{AlbumCtx: &AlbumContext{}})
+	rctx := ruleengine.RuleContext{Now: ret.EvaluatedAt}
+	applied := false
+	for _, aclRule := range compileACLRules(acls, logScope) {
+		for _, rule := range aclRule.Rules {
+			match, result := rule(facts, &rctx)
+			entry := ExplainEntry{
+				Role:   aclRule.Role,
+				User:   aclRule.User,
+				Level:  aclRule.GetACLLevel(),
+				Match:  match,
+				Result: result,
+			}
+			if match && !applied {
+				entry.Applied = true
+				applied = true
+			}
+			ret.Entries = append(ret.Entries, entry)
+		}
+	}
+	logging.Exit(logScope, "ok", map[string]any{"entries": len(ret.Entries), "applied": applied})
+	return ret, nil
+}
@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/db/dao"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
+)
+
+// materializeGeneratedAlbums splits the images of every generator album into
+// generated child albums by the grouping key. Children are created with the
+// key as name, filled from the images of the parent within its limit, and
+// emptied (hidden) when no image has their key. The sort, limit and ACL of
+// the parent are copied on every run; edited fields of an existing child
+// (name, description, cover, rank) are kept, an emptied child included.
+func materializeGeneratedAlbums(database *sql.DB, c context.Context) error {
+	logScope, ctx := logging.Enter(c, "sync/generator", nil, nil)
+	albums, err := dao.QueryAlbum(database, ctx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return err
+	}
+	children := map[dbo.AlbumID]map[string]dbo.Album{}
+	maxRank := map[dbo.AlbumID]uint64{}
+	for _, a := range albums {
+		if a.ParentID == nil {
+			continue
+		}
+		if a.Rank > maxRank[*a.ParentID] {
+			maxRank[*a.ParentID] = a.Rank
+		}
+		if a.GeneratorKey == nil {
+			continue
+		}
+		if children[*a.ParentID] == nil {
+			children[*a.ParentID] = map[string]dbo.Album{}
+		}
+		children[*a.ParentID][*a.GeneratorKey] = a
+	}
+	emptyRule, err := json.Marshal(ruleengine.CreateEmptyRuleGroup())
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return err
+	}
+
+	var tagCache *TagCache
+	created, removed, emptied := 0, 0, 0
+	for _, a := range albums {
+		// generated children are never generators
+		if a.IsGenerated() {
+			continue
+		}
+		existing := children[*a.ID]
+		if a.GroupBy == dbo.AlbumGroupByNone {
+			// generator switched off
+			for _, child := range existing {
+				if err := dao.DeleteAlbum(database, ctx, *child.ID); err != nil {
+					logging.ExitErr(logScope, err)
+					return err
+				}
+				removed++
+			}
+			continue
+		}
+		if a.GroupBy == dbo.AlbumGroupByTag && tagCache == nil {
+			cache := CreateTagCache()
+			if err := LoadTagCache(&cache, database, ctx); err != nil {
+				logging.ExitErr(logScope, err)
+				return err
+			}
+			tagCache = &cache
+		}
+		groups, err := albumGroups(database, ctx, a, tagCache)
+		if err != nil {
+			logging.ErrorContinue(logScope, err, map[string]any{
+				"album_id":   a.ID,
+				"album_name": a.Name,
+			})
+			continue
+		}
+		keys := make([]string, 0, len(groups))
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child, ok := existing[key]
+			if !ok {
+				maxRank[*a.ID]++
+				child = dbo.Album{
+					ParentID:     a.ID,
+					Name:         key,
+					Rank:         maxRank[*a.ID],
+					Sort:         a.Sort,
+					Limit:        a.Limit,
+					GeneratorKey: &key,
+					RuleJSON:     emptyRule,
+					ACLLevel:     a.ACLLevel,
+					ACLUserID:    a.ACLUserID,
+				}
+				if _, err := dao.CreateAlbum(database, ctx, &child); err != nil {
+					logging.ExitErr(logScope, err)
+					return err
+				}
+				created++
+			}
+			if err := setAlbumImages(database, ctx, *child.ID, groups[key]); err != nil {
+				logging.ExitErr(logScope, err)
+				return err
+			}
+		}
+		for key, child := range existing {
+			if _, ok := groups[key]; ok {
+				continue
+			}
+			if err := setAlbumImages(database, ctx, *child.ID, nil); err != nil {
+				logging.ExitErr(logScope, err)
+				return err
+			}
+			emptied++
+		}
+		if err := dao.UpdateGeneratedAlbums(database, ctx, *a.ID); err != nil {
+			logging.ExitErr(logScope, err)
+			return err
+		}
+	}
+	logging.Exit(logScope, "ok", map[string]any{"created": created, "removed": removed, "emptied": emptied})
+	return nil
+}
+
+// albumGroups returns the images of the generator album per key.
+func albumGroups(database *sql.DB, ctx context.Context, a dbo.Album, tagCache *TagCache) (map[string][]dbo.ImageID, error) {
+	switch a.GroupBy {
+	case dbo.AlbumGroupByTag:
+		tagID, ok := tagCache.m[a.GroupParam]
+		if !ok {
+			// no image has the tag yet
+			return map[string][]dbo.ImageID{}, nil
+		}
+		return dao.QueryAlbumGroupKeysByTag(database, ctx, *a.ID, tagID)
+	case dbo.AlbumGroupByYear:
+		return dao.QueryAlbumGroupKeysByTakenAt(database, ctx, *a.ID, "%Y")
+	case dbo.AlbumGroupByMonth:
+		return dao.QueryAlbumGroupKeysByTakenAt(database, ctx, *a.ID, "%Y-%m")
+	case dbo.AlbumGroupByPath:
+		idx, err := strconv.Atoi(a.GroupParam)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path segment index: %s", a.GroupParam)
+		}
+		paths, err := dao.QueryAlbumGroupKeysByPath(database, ctx, *a.ID)
+		if err != nil {
+			return nil, err
+		}
+		ret := make(map[string][]dbo.ImageID)
+		for path, ids := range paths {
+			if key, ok := pathSegment(path, idx); ok {
+				ret[key] = append(ret[key], ids...)
+			}
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("unknown album grouping: %s", a.GroupBy)
+}
+
+// setAlbumImages binds exactly the given images to the album.
+func setAlbumImages(database *sql.DB, ctx context.Context, albumID dbo.AlbumID, imageIDs []dbo.ImageID) error {
+	current, err := dao.QueryImageIDsByAlbum(database, ctx, albumID)
+	if err != nil {
+		return err
+	}
+	want := make(map[dbo.ImageID]struct{}, len(imageIDs))
+	for _, id := range imageIDs {
+		want[id] = struct{}{}
+	}
+	for _, id := range current {
+		if _, ok := want[id]; ok {
+			delete(want, id)
+			continue
+		}
+		if err := dao.BreakAlbumImage(database, ctx, albumID, id); err != nil {
+			return err
+		}
+	}
+	for id := range want {
+		if err := dao.BindAlbumImage(database, ctx, albumID, id, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,27 +2,34 @@ package pipeline
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/ignisVeneficus/lumenta/data"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/geocode"
+	"github.com/ignisVeneficus/lumenta/gpx"
 	"github.com/rs/zerolog/log"
 )
 
 func UpdateImageMetadata(i *dbo.Image, metadata data.Metadata) error {
 	i.Aperture = metadata.GetAperture()
 	i.Camera = metadata.GetMakerCamera()
-	i.TakenAt = metadata.GetTakenAt()
+	i.TakenAt, i.TakenAtLocal = splitTakenAt(metadata.GetTakenAt())
+	i.TakenAtTZ = metadata.GetTakenAtTZ()
 	i.FocalLength = metadata.GetFocalLength()
 	i.Exposure = metadata.GetExposure()
 	i.ISO = metadata.GetIso()
 	i.Latitude = metadata.GetLatitude()
 	i.Longitude = metadata.GetLongitude()
+	i.GPSSource = getGPSSource(metadata, i.Latitude, i.Longitude)
 	i.Lens = metadata.GetLens()
 	i.Rating = metadata.GetRating()
 	i.Width = metadata.GetWidth()
 	i.Height = metadata.GetHeight()
 	i.Rotation = metadata.GetRotation()
+	i.Duration = metadata.GetDuration()
+	i.VideoCodec = metadata.GetVideoCodec()
 	i.Caption = metadata.GetCaption()
 	i.Title = metadata.GetTitle()
 	JSONMetadata, err := json.Marshal(metadata)
@@ -47,6 +54,107 @@ func UpdateImageMetadata(i *dbo.Image, metadata data.Metadata) error {
 	return nil
 }
 
+// splitTakenAt returns the UTC and the local wall clock time of the capture.
+// The local time is returned in UTC location, so the database driver stores it unchanged.
+func splitTakenAt(t *time.Time) (*time.Time, *time.Time) {
+	if t == nil {
+		return nil, nil
+	}
+	utc := t.UTC()
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return &utc, &local
+}
+
+// setTakenAtZone resolves a floating taken_at in the given location.
+func setTakenAtZone(metadata data.Metadata, loc *time.Location, ref string) {
+	tv := metadata[data.MetaTakenAt]
+	t, ok := tv.Value.(time.Time)
+	if !ok {
+		return
+	}
+	tv.Value = data.InZone(t, loc)
+	metadata[data.MetaTakenAt] = tv
+	metadata[data.MetaTakenAtTZ] = data.MetadataValue{
+		Alias:  data.MetaTakenAtTZ,
+		Ref:    ref,
+		Type:   data.MetaString,
+		Value:  loc.String(),
+		Source: data.MetadataSourceTZ,
+	}
+}
+
+func getGPSSource(metadata data.Metadata, lat, lon *float64) *dbo.GPSSource {
+	if lat == nil || lon == nil {
+		return nil
+	}
+	src := dbo.GPSSourceExif
+	if metadata[data.MetaLatitude].Source == data.MetadataSourceGPX {
+		src = dbo.GPSSourceGPX
+	}
+	return &src
+}
+
+// setGPXMetadata adds the position derived from a gpx track to the metadata.
+func setGPXMetadata(metadata data.Metadata, fix gpx.Fix) {
+	set := func(key string, t data.MetadataType, value any) {
+		metadata[key] = data.MetadataValue{
+			Alias:  key,
+			Ref:    "gpx:" + key,
+			Type:   t,
+			Value:  value,
+			Source: data.MetadataSourceGPX,
+		}
+	}
+	set(data.MetaLatitude, data.MetaFloat, fix.Latitude)
+	set(data.MetaLongitude, data.MetaFloat, fix.Longitude)
+	set(data.MetaGPSTrack, data.MetaString, fix.Track)
+}
+
+// setPlaceMetadata adds the geocoded place to the metadata.
+// Values read from the file (e.g. IPTC city) are kept, the gazetteer only fills the gaps.
+func setPlaceMetadata(metadata data.Metadata, place geocode.Place, tagRoot string) {
+	setIfMissing := func(key string, value string) {
+		if value == "" {
+			return
+		}
+		if _, ok := metadata[key]; ok {
+			return
+		}
+		metadata[key] = data.MetadataValue{
+			Alias:  key,
+			Ref:    "geocode:" + key,
+			Type:   data.MetaString,
+			Value:  value,
+			Source: data.MetadataSourceGeocode,
+		}
+	}
+	setIfMissing(data.MetaCountry, place.Country)
+	setIfMissing(data.MetaRegion, place.Region)
+	setIfMissing(data.MetaCity, place.Name)
+
+	if tagRoot == "" {
+		return
+	}
+	parts := []string{tagRoot}
+	for _, p := range []string{place.Country, place.Region, place.Name} {
+		p = strings.TrimSpace(strings.ReplaceAll(p, "/", "-"))
+		if p == "" || p == parts[len(parts)-1] {
+			continue
+		}
+		parts = append(parts, p)
+	}
+	if len(parts) == 1 {
+		return
+	}
+	metadata[data.MetaPlaceTags] = data.MetadataValue{
+		Alias:  data.MetaPlaceTags,
+		Ref:    "geocode:" + data.MetaPlaceTags,
+		Type:   data.MetaList,
+		Value:  []string{strings.Join(parts, "/")},
+		Source: data.MetadataSourceGeocode,
+	}
+}
+
 func getDBOImageFromJob(job WorkItem, syncID dbo.SyncRunID, isForced bool) {
 	job.DBImage.Root = job.RootName
 	job.DBImage.Path = job.Path
@@ -54,6 +162,7 @@ func getDBOImageFromJob(job WorkItem, syncID dbo.SyncRunID, isForced bool) {
 	job.DBImage.FileSize = uint64(job.Info.Size())
 	job.DBImage.MTime = job.Info.ModTime().UTC().Truncate(time.Second)
 	job.DBImage.Ext = job.Ext
+	job.DBImage.MediaType = job.MediaType
 	job.DBImage.FileHash = job.FileHash
 	job.DBImage.MetaHash = job.FileMetadataHash
 	job.DBImage.LastSeenSync = &syncID
@@ -0,0 +1,214 @@
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ignisVeneficus/logging"
+	syncConfig "github.com/ignisVeneficus/lumenta/config/sync"
+	"github.com/ignisVeneficus/lumenta/data"
+	"github.com/ignisVeneficus/lumenta/metadata"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
+)
+
+const (
+	overrideParamAddTag        = "add_tag"
+	overrideParamShiftTakenAt  = "shift_taken_at"
+	overrideParamSet           = "set"
+	overrideParamTitleFromPath = "title_from_path"
+)
+
+type OverrideRule struct {
+	Name    string
+	Rule    ruleengine.CompiledGroupFilter
+	Actions []syncConfig.OverrideAction
+}
+
+func compileOverrides(overrides []syncConfig.OverrideConfig) ([]OverrideRule, error) {
+	ret := make([]OverrideRule, 0, len(overrides))
+	for i, o := range overrides {
+		name := o.Name
+		if name == "" {
+			name = fmt.Sprintf("overrides[%d]", i)
+		}
+		r, err := ruleengine.CompileGroupFilter(o.Rules, name)
+		if err != nil {
+			return nil, fmt.Errorf("override %s: %w", name, err)
+		}
+		ret = append(ret, OverrideRule{
+			Name:    name,
+			Rule:    r,
+			Actions: o.Actions,
+		})
+	}
+	return ret, nil
+}
+
+// applyOverride changes the metadata by the actions, the returned params
+// describe the applied actions with the new values. An action without
+// input (no taken_at, no such folder) is skipped.
+func applyOverride(job *WorkItem, o OverrideRule, fields map[string]syncConfig.MetadataFieldConfig) ([]ruleengine.RuleParam, error) {
+	ref := "override:" + o.Name
+	params := []ruleengine.RuleParam{}
+	for _, a := range o.Actions {
+		switch {
+		case a.AddTag != "":
+			tags := job.Metadata.GetOverrideTags()
+			if !slices.Contains(tags, a.AddTag) {
+				tags = append(slices.Clone(tags), a.AddTag)
+			}
+			job.Metadata[data.MetaOverrideTags] = data.MetadataValue{
+				Alias:  data.MetaOverrideTags,
+				Ref:    ref,
+				Type:   data.MetaList,
+				Value:  tags,
+				Source: data.MetadataSourceOverride,
+			}
+			params = append(params, ruleengine.CreateRuleParamString(overrideParamAddTag, a.AddTag))
+
+		case a.ShiftTakenAt != 0:
+			tv, ok := job.Metadata[data.MetaTakenAt]
+			if !ok {
+				continue
+			}
+			t, ok := tv.Value.(time.Time)
+			if !ok {
+				continue
+			}
+			// the location is kept, the timezone step ran already
+			tv.Value = t.Add(a.ShiftTakenAt)
+			tv.Ref = ref
+			tv.Source = data.MetadataSourceOverride
+			job.Metadata[data.MetaTakenAt] = tv
+			params = append(params, ruleengine.CreateRuleParamStrings(overrideParamShiftTakenAt, []string{
+				a.ShiftTakenAt.String(),
+				tv.Value.(time.Time).Format("2006.01.02 15:04:05"),
+			}))
+
+		case a.Set != nil:
+			field, ok := fields[a.Set.Field]
+			if !ok || field.Type == "" {
+				return params, fmt.Errorf("override %s: set %s: unknown or untyped metadata field", o.Name, a.Set.Field)
+			}
+			value, err := metadata.CoerceType(a.Set.Value, field.Type)
+			if err != nil {
+				return params, fmt.Errorf("override %s: set %s: %w", o.Name, a.Set.Field, err)
+			}
+			job.Metadata[a.Set.Field] = data.MetadataValue{
+				Alias:  a.Set.Field,
+				Ref:    ref,
+				Type:   field.Type,
+				Value:  value,
+				Unit:   field.Unit,
+				Source: data.MetadataSourceOverride,
+			}
+			params = append(params, ruleengine.CreateRuleParamStrings(overrideParamSet, []string{
+				a.Set.Field,
+				fmt.Sprint(a.Set.Value),
+			}))
+
+		case a.TitleFromPath != nil:
+			title, ok := pathSegment(job.Path, *a.TitleFromPath)
+			if !ok {
+				continue
+			}
+			job.Metadata[data.MetaTitle] = data.MetadataValue{
+				Alias:  data.MetaTitle,
+				Ref:    ref,
+				Type:   data.MetaString,
+				Value:  title,
+				Source: data.MetadataSourceOverride,
+			}
+			params = append(params, ruleengine.CreateRuleParamStrings(overrideParamTitleFromPath, []string{
+				strconv.Itoa(*a.TitleFromPath),
+				title,
+			}))
+		}
+	}
+	return params, nil
+}
+
+// derivedInputs are the values the timezone, gpx and geocode steps worked from.
+type derivedInputs struct {
+	takenAt  *time.Time
+	lat, lon *float64
+}
+
+func derivedInputsOf(m data.Metadata) derivedInputs {
+	return derivedInputs{
+		takenAt: m.GetTakenAt(),
+		lat:     m.GetLatitude(),
+		lon:     m.GetLongitude(),
+	}
+}
+
+// rederive computes the derived fields again in the order of their steps:
+// the zone of a floating time, the gpx position of a shifted time and the
+// place of a changed position. Only the derived values are replaced, the
+// ones read from the file or set by an override are kept.
+func rederive(logScope logging.LogScope, job *WorkItem, before derivedInputs, ctx *PipelineContext) {
+	if ctx.Timezone != nil {
+		resolveTakenAtZone(job, ctx.Timezone, ctx.Gazetteer)
+	}
+	relocate := ctx.Tracks != nil && !sameTime(before.takenAt, job.Metadata.GetTakenAt())
+	if relocate {
+		dropDerived(job.Metadata, data.MetadataSourceGPX, data.MetaLatitude, data.MetaLongitude)
+	}
+	if job.Metadata[data.MetaLatitude].Source != data.MetadataSourceGPX {
+		// the track of a replaced position
+		dropDerived(job.Metadata, data.MetadataSourceGPX, data.MetaGPSTrack)
+		job.GPSTrack = ""
+	}
+	if relocate {
+		logging.Debug(logScope, "gpx", map[string]any{"result": locateByGPX(logScope, job, ctx.Tracks, ctx.GPX)})
+	}
+	if ctx.Gazetteer != nil && (!sameFloat(before.lat, job.Metadata.GetLatitude()) || !sameFloat(before.lon, job.Metadata.GetLongitude())) {
+		dropDerived(job.Metadata, data.MetadataSourceGeocode, data.MetaCountry, data.MetaRegion, data.MetaCity, data.MetaPlaceTags)
+		logging.Debug(logScope, "geocode", map[string]any{"result": locatePlace(logScope, job, ctx.Gazetteer, ctx.Geocode)})
+	}
+}
+
+// dropDerived removes the keys written by the given source.
+func dropDerived(m data.Metadata, source data.MetadataSource, keys ...string) {
+	for _, k := range keys {
+		if v, ok := m[k]; ok && v.Source == source {
+			delete(m, k)
+		}
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// pathSegment returns the idx-th folder of the relative path, negative index
+// counts from the end.
+func pathSegment(path string, idx int) (string, bool) {
+	segments := []string{}
+	for _, s := range strings.Split(filepath.ToSlash(path), "/") {
+		if s != "" && s != "." {
+			segments = append(segments, s)
+		}
+	}
+	if idx < 0 {
+		idx += len(segments)
+	}
+	if idx < 0 || idx >= len(segments) {
+		return "", false
+	}
+	return segments[idx], true
+}
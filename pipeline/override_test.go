@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ignisVeneficus/logging"
+	syncConfig "github.com/ignisVeneficus/lumenta/config/sync"
+	"github.com/ignisVeneficus/lumenta/data"
+	"github.com/ignisVeneficus/lumenta/geocode"
+	"github.com/ignisVeneficus/lumenta/gpx"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
+)
+
+// the track passes Aville at 10:00 and Bville at 11:00 (UTC)
+const testTrack = `<?xml version="1.0"?>
+<gpx><trk><name>trip</name><trkseg>
+<trkpt lat="47.0" lon="19.0"><time>2024-05-01T09:59:00Z</time></trkpt>
+<trkpt lat="47.0" lon="19.0"><time>2024-05-01T10:01:00Z</time></trkpt>
+</trkseg><trkseg>
+<trkpt lat="48.0" lon="20.0"><time>2024-05-01T10:59:00Z</time></trkpt>
+<trkpt lat="48.0" lon="20.0"><time>2024-05-01T11:01:00Z</time></trkpt>
+</trkseg></trk></gpx>
+`
+
+const testCities = "1\tAville\tAville\t\t47.0\t19.0\tP\tPPL\tHU\t\t01\t\t\t\t1000\n" +
+	"2\tBville\tBville\t\t48.0\t20.0\tP\tPPL\tHU\t\t02\t\t\t\t1000\n"
+
+func testLocationContext(t *testing.T) *PipelineContext {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "trip.gpx"), []byte(testTrack), 0o600); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	cities := filepath.Join(dir, "cities.txt")
+	if err := os.WriteFile(cities, []byte(testCities), 0o600); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	tracks, err := gpx.LoadDir(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("gpx load failed: %v", err)
+	}
+	gazetteer, err := geocode.Load(context.Background(), cities, "", "")
+	if err != nil {
+		t.Fatalf("geocode load failed: %v", err)
+	}
+	return &PipelineContext{
+		Ctx: context.Background(),
+		Metadata: &syncConfig.MetadataConfig{Fields: map[string]syncConfig.MetadataFieldConfig{
+			data.MetaLatitude:  {Type: data.MetaString},
+			data.MetaLongitude: {Type: data.MetaString},
+			data.MetaCity:      {Type: data.MetaString},
+		}},
+		GPX:       &syncConfig.GPXConfig{Path: dir, MaxGap: 5 * time.Minute},
+		Geocode:   &syncConfig.GeocodeConfig{MaxDistance: 25, TagRoot: "Places"},
+		Tracks:    tracks,
+		Gazetteer: gazetteer,
+	}
+}
+
+// runLocated runs the gpx and geocode steps, then the overrides on one job
+func runLocated(t *testing.T, pc *PipelineContext, metadata data.Metadata, actions ...syncConfig.OverrideAction) WorkItem {
+	t.Helper()
+	job := WorkItem{IsDirty: true, Metadata: metadata}
+	logScope, _ := logging.Enter(pc.Ctx, "test/located", nil, nil)
+	locateByGPX(logScope, &job, pc.Tracks, pc.GPX)
+	locatePlace(logScope, &job, pc.Gazetteer, pc.Geocode)
+
+	in := make(chan WorkItem, 1)
+	out := make(chan WorkItem, 1)
+	in <- job
+	close(in)
+	ctx := *pc
+	ctx.In = in
+	ctx.Out = out
+	all := func(ruleengine.ImageFacts, *ruleengine.RuleContext) (bool, ruleengine.GroupRuleResult) {
+		return true, ruleengine.GroupRuleResult{}
+	}
+	if err := overrideWorker(&ctx, []OverrideRule{{Name: "test", Rule: all, Actions: actions}}); err != nil {
+		t.Fatalf("override failed: %v", err)
+	}
+	return <-out
+}
+
+func takenAtMetadata(t time.Time) data.Metadata {
+	return data.Metadata{
+		data.MetaTakenAt: {Alias: data.MetaTakenAt, Type: data.MetaDateTime, Value: t},
+	}
+}
+
+func expectPlace(t *testing.T, job WorkItem, lat float64, city string) {
+	t.Helper()
+	if got := job.Metadata.GetLatitude(); got == nil || *got != lat {
+		t.Fatalf("expected latitude %v, got %v", lat, got)
+	}
+	if got := job.Metadata[data.MetaCity].Value; got != city {
+		t.Fatalf("expected city %q, got %v", city, got)
+	}
+}
+
+func TestOverrideShiftRelocates(t *testing.T) {
+	pc := testLocationContext(t)
+	job := runLocated(t, pc, takenAtMetadata(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)),
+		syncConfig.OverrideAction{ShiftTakenAt: time.Hour})
+
+	expectPlace(t, job, 48.0, "Bville")
+	if job.GPSTrack != "trip.gpx" {
+		t.Fatalf("expected track trip.gpx, got %q", job.GPSTrack)
+	}
+	tags := job.Metadata.GetPlaceTags()
+	if len(tags) != 1 || tags[0] != "Places/HU/02/Bville" {
+		t.Fatalf("expected the Bville place tag, got %v", tags)
+	}
+}
+
+func TestOverrideShiftLocatesUnmatched(t *testing.T) {
+	pc := testLocationContext(t)
+	// no track point at 09:00, the shifted time matches
+	job := runLocated(t, pc, takenAtMetadata(time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)),
+		syncConfig.OverrideAction{ShiftTakenAt: time.Hour})
+
+	expectPlace(t, job, 47.0, "Aville")
+}
+
+func TestOverrideShiftKeepsFilePosition(t *testing.T) {
+	pc := testLocationContext(t)
+	metadata := takenAtMetadata(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	metadata[data.MetaLatitude] = data.MetadataValue{Alias: data.MetaLatitude, Type: data.MetaString, Value: "48.0"}
+	metadata[data.MetaLongitude] = data.MetadataValue{Alias: data.MetaLongitude, Type: data.MetaString, Value: "20.0"}
+	job := runLocated(t, pc, metadata, syncConfig.OverrideAction{ShiftTakenAt: -time.Hour})
+
+	expectPlace(t, job, 48.0, "Bville")
+	if job.GPSTrack != "" {
+		t.Fatalf("expected no track, got %q", job.GPSTrack)
+	}
+}
+
+func TestOverrideSetPositionGeocodes(t *testing.T) {
+	pc := testLocationContext(t)
+	job := runLocated(t, pc, takenAtMetadata(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)),
+		syncConfig.OverrideAction{Set: &syncConfig.OverrideSet{Field: data.MetaLatitude, Value: 48.0}},
+		syncConfig.OverrideAction{Set: &syncConfig.OverrideSet{Field: data.MetaLongitude, Value: 20.0}},
+	)
+
+	expectPlace(t, job, 48.0, "Bville")
+	if job.GPSTrack != "" {
+		t.Fatalf("expected the track of the replaced position dropped, got %q", job.GPSTrack)
+	}
+	if _, ok := job.Metadata[data.MetaGPSTrack]; ok {
+		t.Fatalf("expected no %s", data.MetaGPSTrack)
+	}
+}
+
+func TestOverrideSetPlaceKept(t *testing.T) {
+	pc := testLocationContext(t)
+	job := runLocated(t, pc, takenAtMetadata(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)),
+		syncConfig.OverrideAction{Set: &syncConfig.OverrideSet{Field: data.MetaCity, Value: "Home"}},
+		syncConfig.OverrideAction{Set: &syncConfig.OverrideSet{Field: data.MetaLatitude, Value: 48.0}},
+		syncConfig.OverrideAction{Set: &syncConfig.OverrideSet{Field: data.MetaLongitude, Value: 20.0}},
+	)
+
+	expectPlace(t, job, 48.0, "Home")
+}
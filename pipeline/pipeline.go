@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/ignisVeneficus/logging"
 	"github.com/ignisVeneficus/lumenta/config"
@@ -15,10 +16,17 @@ import (
 	"github.com/ignisVeneficus/lumenta/db"
 	"github.com/ignisVeneficus/lumenta/db/dao"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/derivative"
+	"github.com/ignisVeneficus/lumenta/geocode"
+	"github.com/ignisVeneficus/lumenta/gpx"
 	"github.com/ignisVeneficus/lumenta/ruleengine"
 	"github.com/rs/zerolog/log"
 )
 
+// ErrSyncRunning: another sync or album refresh holds the active sync run,
+// possibly in another process.
+var ErrSyncRunning = errors.New("sync already running")
+
 func RunForcedImageSync(ctx context.Context, cfg config.Config, imageIDs []uint64) error {
 	pipelineCtx := createPipelineContex(cfg, ctx)
 	ctx, cancel := context.WithCancelCause(ctx)
@@ -108,6 +116,9 @@ func RunGlobalSync(c context.Context, cfg config.Config, cleanUp bool, force boo
 	}()
 
 	syncId, err := dao.CreateSyncRun(pipelineCtx.Database, ctx, mode, metaHash)
+	if errors.Is(err, dao.ErrDataDuplicateKey) {
+		err = ErrSyncRunning
+	}
 	if err != nil {
 		logging.ExitErr(logScope, err)
 		return err
@@ -117,10 +128,19 @@ func RunGlobalSync(c context.Context, cfg config.Config, cleanUp bool, force boo
 	rt = Global()
 
 	if !rt.Start(uint64(syncId)) {
-		err = fmt.Errorf("sync already running")
+		err = ErrSyncRunning
 		logging.ExitErr(logScope, err)
 		return err
 	}
+	err = loadLocationData(ctx, &pipelineCtx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return err
+	}
+	if cfg.Derivatives.Pregenerate {
+		pipelineCtx.Derivatives = derivative.Get().NewBatch()
+		defer pipelineCtx.Derivatives.Close()
+	}
 
 	cancelCtx, cancel := context.WithCancelCause(ctx)
 
@@ -158,9 +178,16 @@ func RunGlobalSync(c context.Context, cfg config.Config, cleanUp bool, force boo
 		stepDBLoopupByPath,
 		stepDirtyCheck,
 		stepMetadataReader,
+		stepTimezone,
+		stepGPX,
+		stepGeocode,
+		// after the derived fields: the rules see them and nothing overwrites the overrides,
+		// the fields derived from an overridden time or position are computed again
+		stepOverride,
 		stepFilter,
 		stepACL,
 		stepDBImageWriter,
+		stepDerivatives,
 		stepAlbumInsertion,
 		stepResultSaver,
 	)
@@ -194,7 +221,7 @@ func RunGlobalSync(c context.Context, cfg config.Config, cleanUp bool, force boo
 		logging.ExitErr(logScope, err)
 		return err
 	}
-	err = dao.ReorderAllImages(pipelineCtx.Database, ctx)
+	err = finishAlbums(pipelineCtx.Database, ctx)
 	if err != nil {
 		logging.ExitErr(logScope, err)
 		return err
@@ -219,11 +246,224 @@ func RunGlobalSync(c context.Context, cfg config.Config, cleanUp bool, force boo
 			return err
 		}
 	}
+	if pipelineCtx.Derivatives != nil {
+		err = waitDerivatives(pipelineCtx, ctx)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return err
+		}
+	}
+	if cleanUp && cfg.Derivatives.GCAfterSync {
+		// a failed gc does not fail the sync
+		_, gcErr := derivative.CollectGarbage(ctx, pipelineCtx.Database, cfg.Derivatives.Sizes, cfg.Filesystem, false)
+		if gcErr != nil {
+			logging.ErrorContinue(logScope, gcErr, nil)
+		}
+	}
 
 	logging.Exit(logScope, "ok", nil)
 	return err
 }
 
+// derivativeProgressInterval: how often the pregeneration progress is saved to the sync run
+const derivativeProgressInterval = 5 * time.Second
+
+// waitDerivatives waits for the pregenerated derivatives of the run,
+// the progress is saved to the sync run meanwhile.
+func waitDerivatives(px PipelineContext, c context.Context) error {
+	logScope, ctx := logging.Enter(c, "sync/global/derivatives", nil, nil)
+	save := func(s derivative.BatchStats) {
+		err := dao.UpdateSyncRunDerivatives(px.Database, ctx, px.SyncId, s.Queued, s.Done, s.Failed)
+		if err != nil {
+			logging.ErrorContinue(logScope, err, nil)
+		}
+	}
+	stats, err := px.Derivatives.Wait(ctx, derivativeProgressInterval, save)
+	save(stats)
+	for _, f := range px.Derivatives.Failures() {
+		logging.ErrorContinue(logScope, f.Err, map[string]any{"image_id": f.Image, "path": f.SourcePath})
+	}
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"stats": &stats})
+	return nil
+}
+
+// finishAlbums builds the album contents after the rules were applied: the
+// image order and the album limits first, so the generated albums are split
+// from the images actually in their parent, then the order of the generated
+// albums.
+func finishAlbums(database *sql.DB, ctx context.Context) error {
+	if err := dao.ReorderAllImages(database, ctx); err != nil {
+		return err
+	}
+	if err := materializeGeneratedAlbums(database, ctx); err != nil {
+		return err
+	}
+	return dao.ReorderGeneratedImages(database, ctx)
+}
+
+// albumRefreshPageSize: the images evaluated at once by the album refresh
+const albumRefreshPageSize uint64 = 500
+
+// RefreshAlbums re-evaluates the albums whose rules depend on the evaluation
+// time (within_last, anniversary), and the albums depending on album membership,
+// on the stored images, then reorders the albums, so the random_daily orders
+// and their limits change by the day too. The filesystem is not read.
+// It runs as a refresh sync run: the active run excludes every other sync and
+// refresh, of any process, while the album contents are rewritten.
+func RefreshAlbums(c context.Context, database *sql.DB) (err error) {
+	logScope, ctx := logging.Enter(c, "sync/albums/refresh", nil, nil)
+	albumCtx, err := collectAlbums(database, ctx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return err
+	}
+	if timeRules, dailyOrder := timeDependence(albumCtx); !timeRules && !dailyOrder {
+		logging.Exit(logScope, "nothing to refresh", nil)
+		return nil
+	}
+
+	runID, err := dao.CreateSyncRun(database, ctx, dbo.SyncModeRefresh, "")
+	if errors.Is(err, dao.ErrDataDuplicateKey) {
+		// the sync evaluates every album itself
+		logging.Exit(logScope, "sync running", nil)
+		return nil
+	}
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return err
+	}
+	var evaluated uint64
+	defer func() {
+		// closed after a shutdown too, an active run blocks every later sync
+		closeCtx := context.WithoutCancel(ctx)
+		var cerr error
+		if err != nil {
+			cerr = dao.CloseSyncRunError(database, closeCtx, runID, err.Error())
+		} else {
+			cerr = dao.CloseSyncRunSuccess(database, closeCtx, runID, evaluated, 0)
+		}
+		if cerr != nil {
+			logging.ErrorContinue(logScope, cerr, map[string]any{"sync_id": runID})
+		}
+	}()
+	// the albums may have been changed by a sync finished meanwhile
+	albumCtx, err = collectAlbums(database, ctx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return err
+	}
+	timeRules, dailyOrder := timeDependence(albumCtx)
+	if !timeRules && !dailyOrder {
+		logging.Exit(logScope, "nothing to refresh", nil)
+		return nil
+	}
+	if timeRules {
+		evaluated, err = reevaluateAlbums(ctx, database, albumCtx)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return err
+		}
+	}
+	// the daily orders are recomputed here
+	if err := finishAlbums(database, ctx); err != nil {
+		logging.ExitErr(logScope, err)
+		return err
+	}
+	logging.Exit(logScope, "ok", map[string]any{"images": evaluated, "daily_order": dailyOrder})
+	return nil
+}
+
+// reevaluateAlbums applies the time and album dependent rules to every stored
+// image, returns the number of the evaluated images.
+func reevaluateAlbums(c context.Context, database *sql.DB, albumCtx *AlbumContext) (uint64, error) {
+	logScope, ctx := logging.Enter(c, "sync/albums/refresh/rules", nil, nil)
+	// the order of the sync: the deepest albums first
+	rules := []*AlbumRule{}
+	for _, ar := range albumCtx.Rules {
+		if ar.TimeDependent || ar.AlbumDependent {
+			rules = append(rules, ar)
+		}
+	}
+
+	bindings, err := dao.QueryAlbumImageBindings(database, ctx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return 0, err
+	}
+	pipelineCtx := &PipelineContext{AlbumCtx: albumCtx}
+	ruleCtx := ruleengine.RuleContext{
+		NameMap: albumCtx.NameMap,
+		Now:     time.Now(),
+	}
+	var evaluated uint64
+	var after dbo.ImageID
+	for {
+		if err := ctx.Err(); err != nil {
+			logging.ExitErr(logScope, err)
+			return evaluated, err
+		}
+		images, err := dao.QueryImageAfterID(database, ctx, after, albumRefreshPageSize)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return evaluated, err
+		}
+		for _, img := range images {
+			albums, overLimit := splitAlbumBindings(bindings[*img.ID])
+			facts := createDBImageFact(img, albums, pipelineCtx)
+			applyAlbumRules(ctx, logScope, database, albumCtx, rules, ruleCtx, *img.ID, &facts, overLimit, nil)
+		}
+		evaluated += uint64(len(images))
+		if uint64(len(images)) < albumRefreshPageSize {
+			break
+		}
+		after = *images[len(images)-1].ID
+	}
+	logging.Exit(logScope, "ok", map[string]any{"albums": len(rules), "images": evaluated})
+	return evaluated, nil
+}
+
+// timeDependence reports whether an album rule depends on the evaluation time
+// and whether an album order changes by the day; either needs the periodic refresh.
+func timeDependence(albumCtx *AlbumContext) (rules bool, order bool) {
+	for _, ar := range albumCtx.Rules {
+		if ar.Rule != nil && ar.TimeDependent {
+			rules = true
+		}
+		if ar.DailyOrder {
+			order = true
+		}
+	}
+	return rules, order
+}
+
+// RunPeriodicAlbumRefresh runs RefreshAlbums every sync.refresh_interval until
+// the context is done, so the relative date rules and the daily orders stay
+// current between syncs.
+func RunPeriodicAlbumRefresh(c context.Context, cfg config.Config) {
+	every := cfg.Sync.RefreshInterval
+	if every <= 0 {
+		return
+	}
+	logScope, ctx := logging.Enter(c, "sync/periodic", nil, map[string]any{"interval": every})
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logging.Exit(logScope, "stopped", nil)
+			return
+		case <-ticker.C:
+			if err := RefreshAlbums(ctx, db.GetDatabase()); err != nil {
+				logging.ErrorContinue(logScope, err, nil)
+			}
+		}
+	}
+}
+
 func collectAlbums(database *sql.DB, c context.Context) (*AlbumContext, error) {
 	logScope, ctx := logging.Enter(c, "sync/pipeline/albums", nil, nil)
 	albums, err := dao.QueryAlbum(database, ctx)
@@ -250,6 +490,9 @@ func collectAlbums(database *sql.DB, c context.Context) (*AlbumContext, error) {
 			Name:     a.Name,
 			Rank:     a.Rank,
 			ParentID: parentID,
+			Limited:  a.Limit != nil,
+			// the sort key of random_daily is the current date
+			DailyOrder: a.Sort == dbo.AlbumSortRandomDaily,
 		}
 		rules[i] = albumrule
 		albumIDs[*id] = albumrule
@@ -263,6 +506,8 @@ func collectAlbums(database *sql.DB, c context.Context) (*AlbumContext, error) {
 			})
 			continue
 		}
+		albumrule.TimeDependent = rawRule.DependsOnTime()
+		albumrule.AlbumDependent = rawRule.DependsOnAlbums()
 		rule, err := ruleengine.CompileGroupFilter(rawRule, fmt.Sprintf("%s (%d)", a.Name, *a.ID))
 		if err != nil {
 			if errors.Is(err, ruleengine.ErrEmptyFilter) {
@@ -341,24 +586,55 @@ func createPipelineContex(cfg config.Config, ctx context.Context) PipelineContex
 		albumCtx = nil
 	}
 	pipelineContext := PipelineContext{
-		RootPath:       cfg.Filesystem.Originals,
-		AllowedExt:     cfg.Sync.NormalizedExtensions,
-		Filters:        cfg.Sync.Paths,
-		ACLRules:       cfg.Sync.ACLRules,
-		ACLOverride:    cfg.Sync.ACLOverride,
-		ExifToolConfig: cfg.Sync.Exiftool,
-		Workers:        cfg.Sync.Pipeline,
-
-		Database: database,
-		Metadata: &cfg.Sync.MergedMetadata,
-		Panorama: cfg.Sync.Panorama,
-		Force:    false,
-		AlbumCtx: albumCtx,
+		RootPath:        cfg.Filesystem.Originals,
+		AllowedExt:      cfg.Sync.NormalizedExtensions,
+		Filters:         cfg.Sync.Paths,
+		ACLRules:        cfg.Sync.ACLRules,
+		ACLOverride:     cfg.Sync.ACLOverride,
+		Filesystem:      cfg.Filesystem,
+		DerivativeSizes: cfg.Derivatives.Sizes,
+		ExifToolConfig:  cfg.Sync.Exiftool,
+		Workers:         cfg.Sync.Pipeline,
+
+		Database:  database,
+		Metadata:  &cfg.Sync.MergedMetadata,
+		Panorama:  cfg.Sync.Panorama,
+		Geocode:   cfg.Sync.Geocode,
+		GPX:       cfg.Sync.GPX,
+		Timezone:  cfg.Sync.Timezone,
+		Video:     cfg.Sync.Video,
+		Overrides: cfg.Sync.Overrides,
+		Force:     false,
+		AlbumCtx:  albumCtx,
+		StartedAt: time.Now(),
 	}
 
 	return pipelineContext
 }
 
+// loadLocationData reads the gpx tracks and the gazetteer of the configured steps.
+func loadLocationData(c context.Context, pc *PipelineContext) error {
+	logScope, ctx := logging.Enter(c, "sync/location_data", nil, nil)
+	if pc.GPX != nil {
+		tracks, err := gpx.LoadDir(ctx, pc.GPX.Path)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return err
+		}
+		pc.Tracks = tracks
+	}
+	if pc.Geocode != nil {
+		gazetteer, err := geocode.Load(ctx, pc.Geocode.Cities, pc.Geocode.Admin1, pc.Geocode.Countries)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return err
+		}
+		pc.Gazetteer = gazetteer
+	}
+	logging.Exit(logScope, "ok", nil)
+	return nil
+}
+
 func runPipeline(c PipelineContext, input chan WorkItem, workers ...step) (chan WorkItem, error) {
 	logScope, _ := logging.Enter(c.Ctx, "sync/pipeline/run", nil, nil)
 	var err error
@@ -414,6 +690,9 @@ func saveResult(px *PipelineContext, job WorkItem, ct context.Context, reason wr
 	if job.DirtyReason != "" {
 		dbItem.DirtyReason = (*string)(&job.DirtyReason)
 	}
+	if job.GPSTrack != "" {
+		dbItem.GPSTrack = &job.GPSTrack
+	}
 	switch reason {
 	case reasonSkipped:
 		if job.Source == SourceImages {
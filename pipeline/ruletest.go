@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/db/dao"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
+)
+
+const ruleTestPageSize = 500
+
+// ErrRuleTestInput is returned for a test request without rules and album.
+var ErrRuleTestInput = errors.New("rules or album id needed")
+
+type RuleTestSample struct {
+	ID       dbo.ImageID `json:"id"`
+	Root     string      `json:"root"`
+	Path     string      `json:"path"`
+	Filename string      `json:"filename"`
+}
+
+// RuleTestReport is the result of a rule group evaluated on the images table.
+type RuleTestReport struct {
+	AlbumID  *uint64                  `json:"album_id,omitempty"`
+	Rules    ruleengine.RuleGroup     `json:"rules"`
+	Total    uint64                   `json:"total"`
+	Matched  uint64                   `json:"matched"`
+	Samples  []RuleTestSample         `json:"samples"`
+	Stats    []*ruleengine.RuleStat   `json:"stats"`
+	Warnings []ruleengine.LintWarning `json:"warnings"`
+}
+
+// createDBImageFact builds the rule input of a stored image, like the sync
+// does for an unchanged file.
+func createDBImageFact(img dbo.Image, albums []dbo.AlbumID, ctx *PipelineContext) ruleengine.ImageFacts {
+	job := WorkItem{
+		RootName: img.Root,
+		Path:     img.Path,
+		Filename: img.Filename,
+		Ext:      img.Ext,
+		DBImage:  &img,
+	}
+	setJobFromImage(&job)
+	job.Albums = convertAlbums(albums, ctx)
+	return createImageFact(job)
+}
+
+// RunAlbumRuleTest tests the stored rule of an album.
+func RunAlbumRuleTest(database *sql.DB, c context.Context, albumID uint64, samples int) (*RuleTestReport, error) {
+	logScope, ctx := logging.Enter(c, "sync/ruletest/album", albumID, map[string]any{"album_id": albumID})
+	album, err := dao.GetAlbumByID(database, ctx, dbo.AlbumID(albumID))
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	if len(album.RuleJSON) == 0 {
+		err := fmt.Errorf("album %d has no rule: %w", albumID, ruleengine.ErrEmptyFilter)
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	var group ruleengine.RuleGroup
+	if err := json.Unmarshal(album.RuleJSON, &group); err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	report, err := RunRuleTest(database, ctx, group, &albumID, samples)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	logging.Exit(logScope, "ok", nil)
+	return report, nil
+}
+
+// RunRuleTest lints the rule group and evaluates it on every image of the
+// database, with the current album bindings. refAlbum is the album the rule
+// belongs to (not_in_child_albums), may be nil.
+// A rule group that does not compile gives a report with a compile_error warning.
+func RunRuleTest(database *sql.DB, c context.Context, group ruleengine.RuleGroup, refAlbum *uint64, samples int) (*RuleTestReport, error) {
+	logScope, ctx := logging.Enter(c, "sync/ruletest", nil, map[string]any{"samples": samples})
+
+	albumCtx, err := collectAlbums(database, ctx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	tagCache := CreateTagCache()
+	if err := LoadTagCache(&tagCache, database, ctx); err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	knownTags := make(map[string]struct{}, len(tagCache.m))
+	for path := range tagCache.m {
+		knownTags[path] = struct{}{}
+	}
+
+	report := &RuleTestReport{
+		AlbumID:  refAlbum,
+		Rules:    group,
+		Samples:  []RuleTestSample{},
+		Warnings: ruleengine.LintRules(group, knownTags, albumCtx.NameMap),
+	}
+
+	rule, err := ruleengine.CompileGroupFilter(group, "test")
+	if err != nil {
+		report.Warnings = append(report.Warnings, ruleengine.LintWarning{
+			Code:    ruleengine.LintCompileError,
+			Message: err.Error(),
+		})
+		logging.Exit(logScope, "not compiled", map[string]any{"error": err.Error()})
+		return report, nil
+	}
+
+	bindings, err := dao.QueryAlbumImageBindings(database, ctx)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+	pipelineCtx := &PipelineContext{AlbumCtx: albumCtx}
+	ruleCtx := ruleengine.RuleContext{
+		RefAlbum: refAlbum,
+		NameMap:  albumCtx.NameMap,
+		Now:      time.Now(),
+	}
+	stats := ruleengine.NewRuleStats(group)
+
+	var after dbo.ImageID
+	for {
+		if err := ctx.Err(); err != nil {
+			logging.ExitErr(logScope, err)
+			return nil, err
+		}
+		images, err := dao.QueryImageAfterID(database, ctx, after, ruleTestPageSize)
+		if err != nil {
+			logging.ExitErr(logScope, err)
+			return nil, err
+		}
+		for _, img := range images {
+			rctx := ruleCtx
+			match, trace := rule(createDBImageFact(img, albumMembers(bindings[*img.ID]), pipelineCtx), &rctx)
+			stats.Add(match, trace)
+			if match && len(report.Samples) < samples {
+				report.Samples = append(report.Samples, RuleTestSample{
+					ID:       *img.ID,
+					Root:     img.Root,
+					Path:     img.Path,
+					Filename: img.Filename + "." + img.Ext,
+				})
+			}
+		}
+		if len(images) < ruleTestPageSize {
+			break
+		}
+		after = *images[len(images)-1].ID
+	}
+
+	report.Total = stats.Total
+	report.Matched = stats.Matched
+	report.Stats = stats.Rules
+	report.Warnings = append(report.Warnings, stats.Lint()...)
+
+	logging.Exit(logScope, "ok", map[string]any{
+		"total":    report.Total,
+		"matched":  report.Matched,
+		"warnings": len(report.Warnings),
+	})
+	return report, nil
+}
@@ -163,6 +163,195 @@ func stepMetadataReader(ctx PipelineContext, in chan WorkItem) (chan WorkItem, e
 	return out, nil
 }
 
+func stepOverride(ctx PipelineContext, in chan WorkItem) (chan WorkItem, error) {
+	logScope, c := logging.Enter(ctx.Ctx, "sync/pipeline/override/build", nil, nil)
+	if len(ctx.Overrides) == 0 {
+		logging.Exit(logScope, "not need", nil)
+		return in, nil
+	}
+	overrides, err := compileOverrides(ctx.Overrides)
+	if err != nil {
+		logging.ExitErr(logScope, err)
+		return nil, err
+	}
+
+	out := make(chan WorkItem, 128)
+
+	pc := ctx
+	pc.In = in
+	pc.Out = out
+
+	workers := 1
+	if stepConfig, ok := ctx.Workers[syncConfig.StepOverride]; ok {
+		workers = int(stepConfig.Workers)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+
+		go func() {
+			logScope, _ := logging.Enter(c, "sync/pipeline/override/run", i, map[string]any{
+				"index": i,
+			})
+			defer wg.Done()
+
+			if err := overrideWorker(&pc, overrides); err != nil {
+				logging.ExitErr(logScope, err)
+				ctx.Cancel(err)
+				return
+			}
+			logging.Exit(logScope, "ok", nil)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	logging.Exit(logScope, "end", nil)
+	return out, nil
+}
+
+func stepTimezone(ctx PipelineContext, in chan WorkItem) (chan WorkItem, error) {
+	logScope, c := logging.Enter(ctx.Ctx, "sync/pipeline/timezone/build", nil, nil)
+	if ctx.Timezone == nil {
+		logging.Exit(logScope, "not need", nil)
+		return in, nil
+	}
+
+	out := make(chan WorkItem, 128)
+
+	pc := ctx
+	pc.In = in
+	pc.Out = out
+
+	workers := 1
+	if stepConfig, ok := ctx.Workers[syncConfig.StepTimezone]; ok {
+		workers = int(stepConfig.Workers)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+
+		go func() {
+			logScope, _ := logging.Enter(c, "sync/pipeline/timezone/run", i, map[string]any{
+				"index": i,
+			})
+			defer wg.Done()
+
+			if err := timezoneWorker(&pc); err != nil {
+				logging.ExitErr(logScope, err)
+				ctx.Cancel(err)
+				return
+			}
+			logging.Exit(logScope, "ok", nil)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	logging.Exit(logScope, "end", nil)
+	return out, nil
+}
+
+func stepGPX(ctx PipelineContext, in chan WorkItem) (chan WorkItem, error) {
+	logScope, c := logging.Enter(ctx.Ctx, "sync/pipeline/gpx/build", nil, nil)
+	if ctx.GPX == nil || ctx.Tracks == nil {
+		logging.Exit(logScope, "not need", nil)
+		return in, nil
+	}
+
+	out := make(chan WorkItem, 128)
+
+	pc := ctx
+	pc.In = in
+	pc.Out = out
+
+	workers := 1
+	if stepConfig, ok := ctx.Workers[syncConfig.StepGPX]; ok {
+		workers = int(stepConfig.Workers)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+
+		go func() {
+			logScope, _ := logging.Enter(c, "sync/pipeline/gpx/run", i, map[string]any{
+				"index": i,
+			})
+			defer wg.Done()
+
+			if err := gpxWorker(&pc); err != nil {
+				logging.ExitErr(logScope, err)
+				ctx.Cancel(err)
+				return
+			}
+			logging.Exit(logScope, "ok", nil)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	logging.Exit(logScope, "end", nil)
+	return out, nil
+}
+
+func stepGeocode(ctx PipelineContext, in chan WorkItem) (chan WorkItem, error) {
+	logScope, c := logging.Enter(ctx.Ctx, "sync/pipeline/geocode/build", nil, nil)
+	if ctx.Geocode == nil || ctx.Gazetteer == nil {
+		logging.Exit(logScope, "not need", nil)
+		return in, nil
+	}
+
+	out := make(chan WorkItem, 128)
+
+	pc := ctx
+	pc.In = in
+	pc.Out = out
+
+	workers := 1
+	if stepConfig, ok := ctx.Workers[syncConfig.StepGeocode]; ok {
+		workers = int(stepConfig.Workers)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+
+		go func() {
+			logScope, _ := logging.Enter(c, "sync/pipeline/geocode/run", i, map[string]any{
+				"index": i,
+			})
+			defer wg.Done()
+
+			if err := geocodeWorker(&pc); err != nil {
+				logging.ExitErr(logScope, err)
+				ctx.Cancel(err)
+				return
+			}
+			logging.Exit(logScope, "ok", nil)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	logging.Exit(logScope, "end", nil)
+	return out, nil
+}
+
 func stepFilter(ctx PipelineContext, in chan WorkItem) (chan WorkItem, error) {
 	logScope, c := logging.Enter(ctx.Ctx, "sync/pipeline/import_filter/build", nil, nil)
 	out := make(chan WorkItem, 128)
@@ -302,6 +491,52 @@ func stepACL(ctx PipelineContext, in chan WorkItem) (chan WorkItem, error) {
 	logging.Exit(logScope, "end", nil)
 	return out, nil
 }
+func stepDerivatives(ctx PipelineContext, in chan WorkItem) (chan WorkItem, error) {
+	logScope, c := logging.Enter(ctx.Ctx, "sync/pipeline/derivatives/build", nil, nil)
+	if ctx.Derivatives == nil {
+		logging.Exit(logScope, "not need", nil)
+		return in, nil
+	}
+
+	out := make(chan WorkItem, 128)
+
+	pc := ctx
+	pc.In = in
+	pc.Out = out
+
+	workers := 1
+	if stepConfig, ok := ctx.Workers[syncConfig.StepDerivatives]; ok {
+		workers = int(stepConfig.Workers)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+
+		go func() {
+			logScope, _ := logging.Enter(c, "sync/pipeline/derivatives/run", i, map[string]any{
+				"index": i,
+			})
+			defer wg.Done()
+
+			if err := derivativesWorker(&pc); err != nil {
+				logging.ExitErr(logScope, err)
+				ctx.Cancel(err)
+				return
+			}
+			logging.Exit(logScope, "ok", nil)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	logging.Exit(logScope, "end", nil)
+	return out, nil
+}
+
 func stepAlbumInsertion(ctx PipelineContext, in chan WorkItem) (chan WorkItem, error) {
 	logScope, c := logging.Enter(ctx.Ctx, "sync/pipeline/album_rules/build", nil, nil)
 	database := db.GetDatabase()
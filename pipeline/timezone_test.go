@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	syncConfig "github.com/ignisVeneficus/lumenta/config/sync"
+	"github.com/ignisVeneficus/lumenta/data"
+	"github.com/ignisVeneficus/lumenta/geocode"
+)
+
+// the place of the gazetteer is in Hungary, its timezone is deliberately another one
+const testZoneCities = "1\tAville\tAville\t\t47.0\t19.0\tP\tPPL\tHU\t\t01\t\t\t\t1000\t\t\tAsia/Tokyo\t2024-01-01\n"
+
+func TestResolveTakenAtZone(t *testing.T) {
+	cities := filepath.Join(t.TempDir(), "cities.txt")
+	if err := os.WriteFile(cities, []byte(testZoneCities), 0o600); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	gazetteer, err := geocode.Load(context.Background(), cities, "", "")
+	if err != nil {
+		t.Fatalf("geocode load failed: %v", err)
+	}
+	load := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatalf("setup failed: %v", err)
+		}
+		return loc
+	}
+	lisbon := load("Europe/Lisbon")
+	paths := []syncConfig.TimezonePathConfig{{Root: "photos", Path: "travel", Location: load("America/New_York")}}
+
+	tests := []struct {
+		name      string
+		tz        syncConfig.TimezoneConfig
+		gazetteer *geocode.Gazetteer
+		path      string
+		lat, lon  float64
+		want      string
+	}{
+		{"path before gps", syncConfig.TimezoneConfig{GPSLookup: true, Paths: paths, DefaultLocation: lisbon}, gazetteer, "travel/ny", 47.0, 19.0, "America/New_York"},
+		{"gazetteer", syncConfig.TimezoneConfig{GPSLookup: true, Paths: paths, DefaultLocation: lisbon}, gazetteer, "home", 47.0, 19.0, "Asia/Tokyo"},
+		{"table without gazetteer", syncConfig.TimezoneConfig{GPSLookup: true, DefaultLocation: lisbon}, nil, "home", 47.0, 19.0, "Europe/Budapest"},
+		{"table far from the gazetteer", syncConfig.TimezoneConfig{GPSLookup: true, DefaultLocation: lisbon}, gazetteer, "home", 35.68, 139.69, "Asia/Tokyo"},
+		{"open sea", syncConfig.TimezoneConfig{GPSLookup: true, DefaultLocation: lisbon}, gazetteer, "home", -40.0, -130.0, "Europe/Lisbon"},
+		{"gps disabled", syncConfig.TimezoneConfig{DefaultLocation: lisbon}, gazetteer, "home", 47.0, 19.0, "Europe/Lisbon"},
+		{"no zone", syncConfig.TimezoneConfig{GPSLookup: true}, gazetteer, "home", -40.0, -130.0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := takenAtMetadata(time.Date(2024, 5, 1, 10, 0, 0, 0, data.FloatingZone))
+			metadata[data.MetaLatitude] = data.MetadataValue{Alias: data.MetaLatitude, Type: data.MetaString, Value: tt.lat}
+			metadata[data.MetaLongitude] = data.MetadataValue{Alias: data.MetaLongitude, Type: data.MetaString, Value: tt.lon}
+			job := WorkItem{RootName: "photos", Path: tt.path, Metadata: metadata}
+			resolveTakenAtZone(&job, &tt.tz, tt.gazetteer)
+
+			got, _ := job.Metadata[data.MetaTakenAtTZ].Value.(string)
+			if got != tt.want {
+				t.Fatalf("expected zone %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
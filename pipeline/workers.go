@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -11,12 +12,17 @@ import (
 	"time"
 
 	"github.com/ignisVeneficus/logging"
+	syncConfig "github.com/ignisVeneficus/lumenta/config/sync"
 	"github.com/ignisVeneficus/lumenta/data"
 	"github.com/ignisVeneficus/lumenta/db/dao"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/derivative"
 	"github.com/ignisVeneficus/lumenta/exif"
+	"github.com/ignisVeneficus/lumenta/geocode"
+	"github.com/ignisVeneficus/lumenta/gpx"
 	"github.com/ignisVeneficus/lumenta/metadata"
 	"github.com/ignisVeneficus/lumenta/ruleengine"
+	"github.com/ignisVeneficus/lumenta/timezone"
 	"github.com/ignisVeneficus/lumenta/utils"
 )
 
@@ -77,6 +83,10 @@ func walkDirHandler(ctx *PipelineContext, rootName, root string, excludedDirName
 			return nil
 		}
 	}
+	mediaType := dbo.MediaTypeImage
+	if ctx.Video.IsVideo(normalisedExt) {
+		mediaType = dbo.MediaTypeVideo
+	}
 	metaFile := realPath + ".xmp"
 
 	if ctx.Out == nil {
@@ -104,6 +114,7 @@ func walkDirHandler(ctx *PipelineContext, rootName, root string, excludedDirName
 		Ext:          normalisedExt,
 		Filename:     filename,
 		Info:         info,
+		MediaType:    mediaType,
 	}:
 	case <-ctx.Ctx.Done():
 		return ctx.Ctx.Err()
@@ -153,6 +164,27 @@ func convertAlbums(albums []dbo.AlbumID, ctx *PipelineContext) ruleengine.Albums
 	return ret
 }
 
+// splitAlbumBindings splits the relations of an image into the albums it is
+// in and the ones it is bound to after the album limit.
+func splitAlbumBindings(bindings []dbo.AlbumBinding) ([]dbo.AlbumID, map[uint64]struct{}) {
+	albums := make([]dbo.AlbumID, 0, len(bindings))
+	overLimit := make(map[uint64]struct{})
+	for _, b := range bindings {
+		if b.OverLimit {
+			overLimit[uint64(b.AlbumID)] = struct{}{}
+			continue
+		}
+		albums = append(albums, b.AlbumID)
+	}
+	return albums, overLimit
+}
+
+// albumMembers returns the albums the image is in.
+func albumMembers(bindings []dbo.AlbumBinding) []dbo.AlbumID {
+	albums, _ := splitAlbumBindings(bindings)
+	return albums
+}
+
 func dBLoopupByPathWorker(ctx *PipelineContext) error {
 	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/db_lookup/run/inside", nil, nil)
 	if ctx.Database == nil {
@@ -179,12 +211,14 @@ func dBLoopupByPathWorker(ctx *PipelineContext) error {
 		case err == nil:
 			job.DBImage = &image
 			setJobFromImage(&job)
-			albums, err := dao.QueryAlbumsIDByImageID(ctx.Database, c, *job.DBImage.ID)
+			bindings, err := dao.QueryAlbumBindingsByImageID(ctx.Database, c, *job.DBImage.ID)
 			if err != nil {
 				logging.ExitErr(logScope, err)
 				return err
 			}
+			albums, overLimit := splitAlbumBindings(bindings)
 			job.Albums = convertAlbums(albums, ctx)
+			job.OverLimitAlbums = overLimit
 		case errors.Is(err, dao.ErrDataNotFound):
 			filtered, err := dao.GetFilteredByPath(ctx.Database, c, job.RootName, job.Path, job.Filename, job.Ext)
 			switch {
@@ -447,6 +481,261 @@ func metadataReaderWorker(ctx *PipelineContext) error {
 	return nil
 }
 
+func overrideWorker(ctx *PipelineContext, overrides []OverrideRule) error {
+	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/override/run/inside", nil, nil)
+	if ctx.In == nil || ctx.Out == nil {
+		err := fmt.Errorf("In/Out channel is nil")
+		logging.ExitErr(logScope, err)
+		return err
+	}
+
+	for job := range ctx.In {
+		select {
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		default:
+		}
+		logScope, c := logging.Enter(job.Ctx, "pipeline/job/run/override", job.RealPath, map[string]any{
+			"path": job.RealPath,
+		})
+		log := "nop"
+		if job.IsDirty && job.Metadata != nil {
+			log = "ok"
+			applied := 0
+			before := derivedInputsOf(job.Metadata)
+			var err error
+			// every override sees the changes of the earlier ones
+			for _, o := range overrides {
+				match, result := o.Rule(createImageFact(job), nil)
+				if match {
+					var params []ruleengine.RuleParam
+					params, err = applyOverride(&job, o, ctx.Metadata.Fields)
+					result.Params = append(result.Params, params...)
+					if err != nil {
+						break
+					}
+					applied++
+				}
+				job.RuleResults.AddResult(ruleengine.EvaluationOverride, result)
+			}
+			if err != nil {
+				logging.ExitErr(logScope, err)
+				SaveResultError(ctx, job, c)
+				continue
+			}
+			// the derived steps ran already, their fields follow the overridden values here
+			if applied > 0 {
+				rederive(logScope, &job, before, ctx)
+			}
+			logging.Debug(logScope, "applied", map[string]any{"count": applied})
+		}
+		ws := time.Now()
+		select {
+		case ctx.Out <- job:
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		}
+		logging.Exit(logScope, log, map[string]any{
+			"wait_insert": time.Since(ws),
+		})
+	}
+	logging.Exit(logScope, "ok", nil)
+	return nil
+}
+
+func timezoneWorker(ctx *PipelineContext) error {
+	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/timezone/run/inside", nil, nil)
+	if ctx.In == nil || ctx.Out == nil {
+		err := fmt.Errorf("In/Out channel is nil")
+		logging.ExitErr(logScope, err)
+		return err
+	}
+
+	for job := range ctx.In {
+		select {
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		default:
+		}
+		logScope, _ := logging.Enter(job.Ctx, "pipeline/job/run/timezone", job.RealPath, map[string]any{
+			"path": job.RealPath,
+		})
+		log := "nop"
+		if job.IsDirty && job.Metadata != nil {
+			log = resolveTakenAtZone(&job, ctx.Timezone, ctx.Gazetteer)
+		}
+		ws := time.Now()
+		select {
+		case ctx.Out <- job:
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		}
+		logging.Exit(logScope, log, map[string]any{
+			"wait_insert": time.Since(ws),
+		})
+	}
+	logging.Exit(logScope, "ok", nil)
+	return nil
+}
+
+// resolveTakenAtZone sets the timezone of a floating taken_at: the zone of its
+// path, the zone of the GPS position (gazetteer, embedded table), the default.
+// Returns the way it was resolved.
+func resolveTakenAtZone(job *WorkItem, tz *syncConfig.TimezoneConfig, gazetteer *geocode.Gazetteer) string {
+	takenAt := job.Metadata.GetTakenAt()
+	if takenAt == nil {
+		return "no time"
+	}
+	if !data.IsFloating(*takenAt) {
+		return "has offset"
+	}
+	if loc := tz.PathLocation(job.RootName, job.Path); loc != nil {
+		setTakenAtZone(job.Metadata, loc, "timezone:config")
+		return "path"
+	}
+	lat := job.Metadata.GetLatitude()
+	lon := job.Metadata.GetLongitude()
+	if tz.GPSLookup && lat != nil && lon != nil {
+		if loc := timezone.Lookup(*lat, *lon, gazetteer); loc != nil {
+			setTakenAtZone(job.Metadata, loc, "timezone:gps")
+			return "gps"
+		}
+	}
+	if tz.DefaultLocation == nil {
+		return "no zone"
+	}
+	setTakenAtZone(job.Metadata, tz.DefaultLocation, "timezone:config")
+	return "default"
+}
+
+func gpxWorker(ctx *PipelineContext) error {
+	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/gpx/run/inside", nil, nil)
+	if ctx.In == nil || ctx.Out == nil {
+		err := fmt.Errorf("In/Out channel is nil")
+		logging.ExitErr(logScope, err)
+		return err
+	}
+
+	for job := range ctx.In {
+		select {
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		default:
+		}
+		logScope, _ := logging.Enter(job.Ctx, "pipeline/job/run/gpx", job.RealPath, map[string]any{
+			"path": job.RealPath,
+		})
+		log := "nop"
+		if job.IsDirty && job.Metadata != nil {
+			log = locateByGPX(logScope, &job, ctx.Tracks, ctx.GPX)
+		}
+		ws := time.Now()
+		select {
+		case ctx.Out <- job:
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		}
+		logging.Exit(logScope, log, map[string]any{
+			"wait_insert": time.Since(ws),
+		})
+	}
+	logging.Exit(logScope, "ok", nil)
+	return nil
+}
+
+// locateByGPX sets the position of an image without gps from the tracks.
+// Returns the way it was resolved.
+func locateByGPX(logScope logging.LogScope, job *WorkItem, tracks *gpx.Tracks, cfg *syncConfig.GPXConfig) string {
+	takenAt := job.Metadata.GetTakenAt()
+	switch {
+	case job.Metadata.GetLatitude() != nil && job.Metadata.GetLongitude() != nil:
+		return "has gps"
+	case takenAt == nil:
+		return "no time"
+	}
+	fix, ok := tracks.Locate(takenAt.Add(cfg.Offset), cfg.MaxGap)
+	if !ok {
+		return "no match"
+	}
+	setGPXMetadata(job.Metadata, fix)
+	job.GPSTrack = fix.Track
+	logging.Debug(logScope, "fix", map[string]any{
+		"fix": &fix,
+	})
+	return "ok"
+}
+
+func geocodeWorker(ctx *PipelineContext) error {
+	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/geocode/run/inside", nil, nil)
+	if ctx.In == nil || ctx.Out == nil {
+		err := fmt.Errorf("In/Out channel is nil")
+		logging.ExitErr(logScope, err)
+		return err
+	}
+
+	for job := range ctx.In {
+		select {
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		default:
+		}
+		logScope, _ := logging.Enter(job.Ctx, "pipeline/job/run/geocode", job.RealPath, map[string]any{
+			"path": job.RealPath,
+		})
+		log := "nop"
+		if job.IsDirty && job.Metadata != nil {
+			log = locatePlace(logScope, &job, ctx.Gazetteer, ctx.Geocode)
+		}
+		ws := time.Now()
+		select {
+		case ctx.Out <- job:
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		}
+		logging.Exit(logScope, log, map[string]any{
+			"wait_insert": time.Since(ws),
+		})
+	}
+	logging.Exit(logScope, "ok", nil)
+	return nil
+}
+
+// locatePlace sets the place of an image with gps from the gazetteer.
+// Returns the way it was resolved.
+func locatePlace(logScope logging.LogScope, job *WorkItem, gazetteer *geocode.Gazetteer, cfg *syncConfig.GeocodeConfig) string {
+	lat := job.Metadata.GetLatitude()
+	lon := job.Metadata.GetLongitude()
+	if lat == nil || lon == nil {
+		return "no gps"
+	}
+	place, distance, ok := gazetteer.Lookup(*lat, *lon, cfg.MaxDistance)
+	if !ok {
+		return "no match"
+	}
+	setPlaceMetadata(job.Metadata, place, cfg.TagRoot)
+	logging.Debug(logScope, "place", map[string]any{
+		"place":    &place,
+		"distance": distance,
+	})
+	return "ok"
+}
+
 func filterWorker(ctx *PipelineContext) error {
 	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/import_filter/run/inside", nil, nil)
 
@@ -542,15 +831,9 @@ func filterWorker(ctx *PipelineContext) error {
 	return nil
 }
 
-func aclWorker(ctx *PipelineContext) error {
-	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/acl_rules/run/inside", nil, nil)
-	if ctx.In == nil || ctx.Out == nil {
-		err := fmt.Errorf("In/Out channel is nil")
-		logging.ExitErr(logScope, err)
-		return err
-	}
-	ACLRules := ACLRules{}
-	for i, acl := range ctx.ACLRules {
+func compileACLRules(acls syncConfig.ACLRules, logScope logging.LogScope) ACLRules {
+	ret := ACLRules{}
+	for i, acl := range acls {
 		aclRule := ACLRule{
 			Role: acl.Role,
 			User: acl.User,
@@ -567,8 +850,19 @@ func aclWorker(ctx *PipelineContext) error {
 			rules = append(rules, r)
 		}
 		aclRule.Rules = rules
-		ACLRules = append(ACLRules, aclRule)
+		ret = append(ret, aclRule)
+	}
+	return ret
+}
+
+func aclWorker(ctx *PipelineContext) error {
+	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/acl_rules/run/inside", nil, nil)
+	if ctx.In == nil || ctx.Out == nil {
+		err := fmt.Errorf("In/Out channel is nil")
+		logging.ExitErr(logScope, err)
+		return err
 	}
+	ACLRules := compileACLRules(ctx.ACLRules, logScope)
 
 	for job := range ctx.In {
 		select {
@@ -656,27 +950,12 @@ func dbImageWriterWorker(ctx *PipelineContext, tagCache *TagCache) error {
 				SaveResultError(ctx, job, c)
 				continue
 			}
-			tagSet := make(map[dbo.TagID]struct{})
-			tags := job.Metadata.GetTags()
-			for _, t := range tags {
-				var tagIDs []dbo.TagID
-				tagIDs, err = tagCache.Resolve(ctx.Database, c, t, "Digikam")
-				if err != nil {
-					logging.ExitErrParams(logScope, err, map[string]any{"is_dirty": job.IsDirty})
-					SaveResultError(ctx, job, c)
-					break
-				}
-				for _, id := range tagIDs {
-					tagSet[id] = struct{}{}
-				}
-			}
+			tagIDs, err := resolveImageTags(c, ctx.Database, tagCache, job.Metadata)
 			if err != nil {
+				logging.ExitErrParams(logScope, err, map[string]any{"is_dirty": job.IsDirty})
+				SaveResultError(ctx, job, c)
 				continue
 			}
-			tagIDs := make([]dbo.TagID, 0, len(tagSet))
-			for id := range tagSet {
-				tagIDs = append(tagIDs, id)
-			}
 			err = dao.BindImageTags(ctx.Database, c, updateID, tagIDs)
 			if err != nil {
 				logging.ExitErrParams(logScope, err, map[string]any{"is_dirty": job.IsDirty})
@@ -706,6 +985,92 @@ func dbImageWriterWorker(ctx *PipelineContext, tagCache *TagCache) error {
 	return nil
 }
 
+// resolveImageTags returns the ids of the file, place and override tags,
+// each tag path resolved with its own source.
+func resolveImageTags(c context.Context, database *sql.DB, tagCache *TagCache, metadata data.Metadata) ([]dbo.TagID, error) {
+	sources := []struct {
+		tags   []string
+		source string
+	}{
+		{metadata.GetTags(), "Digikam"},
+		{metadata.GetPlaceTags(), string(dbo.TagSourceGeocode)},
+		{metadata.GetOverrideTags(), string(dbo.TagSourceOverride)},
+	}
+	tagSet := make(map[dbo.TagID]struct{})
+	for _, s := range sources {
+		for _, t := range s.tags {
+			ids, err := tagCache.Resolve(database, c, t, s.source)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				tagSet[id] = struct{}{}
+			}
+		}
+	}
+	tagIDs := make([]dbo.TagID, 0, len(tagSet))
+	for id := range tagSet {
+		tagIDs = append(tagIDs, id)
+	}
+	return tagIDs, nil
+}
+
+// derivativesWorker submits the derivatives of the written (new or changed) images:
+// every derivative of a changed content, the missing ones otherwise. A metadata
+// change reaching the pixels (rotation, focus) changes the fingerprint, so its
+// files are missing too. The jobs run in the derivative service, the results
+// are counted by the batch.
+func derivativesWorker(ctx *PipelineContext) error {
+	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/derivatives/run/inside", nil, nil)
+	if ctx.In == nil || ctx.Out == nil {
+		err := fmt.Errorf("In/Out channel is nil")
+		logging.ExitErr(logScope, err)
+		return err
+	}
+
+	for job := range ctx.In {
+		select {
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		default:
+		}
+		logScope, c := logging.Enter(job.Ctx, "pipeline/job/run/derivatives", job.RealPath, map[string]any{
+			"path": job.RealPath,
+		})
+		log := "nop"
+		if job.IsDirty && job.DBImage != nil && job.DBImage.ID != nil {
+			missing := job.DirtyReason != data.DirtyHashChg && job.DirtyReason != data.DirtySizeChg
+			dJob, ok, err := derivative.ImageJob(c, *job.DBImage, ctx.DerivativeSizes, ctx.Filesystem, missing)
+			switch {
+			case err != nil:
+				log = "error"
+				logging.ErrorContinue(logScope, err, nil)
+			case ok:
+				log = "submitted"
+				if err := ctx.Derivatives.Submit(dJob); err != nil {
+					log = "error"
+					logging.ErrorContinue(logScope, err, nil)
+				}
+			}
+		}
+		ws := time.Now()
+		select {
+		case ctx.Out <- job:
+		case <-ctx.Ctx.Done():
+			err := ctx.Ctx.Err()
+			logging.ExitErr(logScope, err)
+			return err
+		}
+		logging.Exit(logScope, log, map[string]any{
+			"wait_insert": time.Since(ws),
+		})
+	}
+	logging.Exit(logScope, "ok", nil)
+	return nil
+}
+
 func albumInsertionWorker(ctx *PipelineContext) error {
 	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/album_rules/run/inside", nil, nil)
 	if ctx.Database == nil {
@@ -721,6 +1086,8 @@ func albumInsertionWorker(ctx *PipelineContext) error {
 	albumsRules := ctx.AlbumCtx.Rules
 	ruleCtx := ruleengine.RuleContext{
 		NameMap: ctx.AlbumCtx.NameMap,
+		// one evaluation time for the relative date rules of the whole run
+		Now: ctx.StartedAt,
 	}
 
 	for job := range ctx.In {
@@ -737,47 +1104,7 @@ func albumInsertionWorker(ctx *PipelineContext) error {
 		if job.DBImage != nil {
 			//			facts := createImageFactDb(job)
 			facts := createImageFact(job)
-			for _, ar := range albumsRules {
-				if ar.Rule == nil {
-					logging.Trace(logScope, "empty rule", map[string]any{
-						"album_id":   ar.ID,
-						"album_name": ar.Name,
-					})
-					continue
-				}
-				rctx := ruleCtx
-				rctx.RefAlbum = &ar.ID
-				match, ruleResult := ar.Rule(facts, &rctx)
-				_, found := job.Albums[ar.ID]
-				job.RuleResults.AddResult(ruleengine.EvaluationAlbum, ruleResult)
-				if found && !match {
-					err := dao.BreakAlbumImage(ctx.Database, c, dbo.AlbumID(ar.ID), *job.DBImage.ID)
-					if err != nil {
-						logging.ErrorContinue(logScope, err, map[string]any{
-							"album_id": ar.ID,
-							"image_id": job.DBImage.ID,
-						})
-					}
-					delete(facts.Albums, ar.ID)
-				}
-				if !found && match {
-					err := dao.BindAlbumImage(ctx.Database, c, dbo.AlbumID(ar.ID), *job.DBImage.ID, nil)
-					if err != nil {
-						logging.ErrorContinue(logScope, err, map[string]any{
-							"album_id": ar.ID,
-							"image_id": job.DBImage.ID,
-						})
-					}
-					as, ok := ctx.AlbumCtx.AlbumStructs[ar.ID]
-					if ok {
-						if facts.Albums == nil {
-							facts.Albums = make(ruleengine.AlbumsStruct)
-						}
-						facts.Albums[ar.ID] = as
-					}
-				}
-
-			}
+			applyAlbumRules(c, logScope, ctx.Database, ctx.AlbumCtx, albumsRules, ruleCtx, *job.DBImage.ID, &facts, job.OverLimitAlbums, &job.RuleResults)
 		}
 
 		ws := time.Now()
@@ -797,6 +1124,62 @@ func albumInsertionWorker(ctx *PipelineContext) error {
 	return nil
 }
 
+// applyAlbumRules evaluates the album rules on the image, binds it to the
+// matching albums and breaks the rest; facts.Albums follows the changes, so
+// the later rules see them. overLimit holds the relations after the album
+// limit: they are kept while the rule matches, but they are not facts.
+func applyAlbumRules(c context.Context, logScope logging.LogScope, database *sql.DB, albumCtx *AlbumContext, rules []*AlbumRule, ruleCtx ruleengine.RuleContext, imageID dbo.ImageID, facts *ruleengine.ImageFacts, overLimit map[uint64]struct{}, results *ruleengine.RuleResults) {
+	for _, ar := range rules {
+		if ar.Rule == nil {
+			logging.Trace(logScope, "empty rule", map[string]any{
+				"album_id":   ar.ID,
+				"album_name": ar.Name,
+			})
+			continue
+		}
+		rctx := ruleCtx
+		rctx.RefAlbum = &ar.ID
+		match, ruleResult := ar.Rule(*facts, &rctx)
+		_, found := facts.Albums[ar.ID]
+		if _, ok := overLimit[ar.ID]; ok {
+			found = true
+		}
+		if results != nil {
+			results.AddResult(ruleengine.EvaluationAlbum, ruleResult)
+		}
+		if found && !match {
+			err := dao.BreakAlbumImage(database, c, dbo.AlbumID(ar.ID), imageID)
+			if err != nil {
+				logging.ErrorContinue(logScope, err, map[string]any{
+					"album_id": ar.ID,
+					"image_id": imageID,
+				})
+			}
+			delete(facts.Albums, ar.ID)
+		}
+		if !found && match {
+			err := dao.BindAlbumImage(database, c, dbo.AlbumID(ar.ID), imageID, nil)
+			if err != nil {
+				logging.ErrorContinue(logScope, err, map[string]any{
+					"album_id": ar.ID,
+					"image_id": imageID,
+				})
+			}
+			// hidden until the reorder decides whether it is within the limit
+			if ar.Limited {
+				continue
+			}
+			as, ok := albumCtx.AlbumStructs[ar.ID]
+			if ok {
+				if facts.Albums == nil {
+					facts.Albums = make(ruleengine.AlbumsStruct)
+				}
+				facts.Albums[ar.ID] = as
+			}
+		}
+	}
+}
+
 func resultSaverWorker(ctx *PipelineContext) error {
 	logScope, _ := logging.Enter(ctx.Ctx, "sync/pipeline/result_saver/run/inside", nil, nil)
 	if ctx.Database == nil {
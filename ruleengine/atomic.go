@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/data"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -29,14 +30,30 @@ type ImageFacts struct {
 	Width   uint32
 	Height  uint32
 
+	// GPS position, nil if not known
+	Latitude  *float64
+	Longitude *float64
+
 	Tags []string
 
 	// nill-> not given
 	Albums AlbumsStruct
+
+	// resolved metadata by alias, for the meta rule
+	Metadata data.Metadata
 }
 type RuleContext struct {
 	RefAlbum *uint64
 	NameMap  map[uint64]string
+	// evaluation time of the relative date rules, zero: time.Now()
+	Now time.Time
+}
+
+func (rc *RuleContext) EvaluationTime() time.Time {
+	if rc == nil || rc.Now.IsZero() {
+		return time.Now()
+	}
+	return rc.Now
 }
 
 func (rc *RuleContext) AlbumName(value uint64) string {
@@ -99,6 +116,16 @@ func compileAtomicFilter(flt Rule) (CompiledFilter, error) {
 		return compileHeightFilter(f)
 	case *AspectFilter:
 		return compileAspectFilter(f)
+	case *GroupFilter:
+		return compileNestedGroupFilter(f)
+	case *MetaFilter:
+		return compileMetaFilter(f)
+	case *GeoFilter:
+		return compileGeoFilter(f)
+	case *ExpressionFilter:
+		return compileExpressionFilter(f)
+	case CompilableRule:
+		return f.Compile()
 	default:
 		return nil, fmt.Errorf("unknown filter type: %T", flt)
 	}
@@ -218,7 +245,19 @@ func parseDateRange(s string) (time.Time, time.Time, error) {
 
 	return start, end, nil
 }
+
+// wallClock returns the local time of t in UTC location, so it compares with
+// the UTC based date ranges by calendar date.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
 func compileDateFilter(f *DateFilter) (CompiledFilter, error) {
+	switch f.Op {
+	case DateOn, DateBefore, DateAfter, DateBetween:
+	default:
+		return compileRecurringDateFilter(f)
+	}
 	start, end, err := parseDateRange(f.Date)
 	if err != nil {
 		return nil, err
@@ -232,6 +271,18 @@ func compileDateFilter(f *DateFilter) (CompiledFilter, error) {
 			CreateRuleParamString("date", f.Date),
 		},
 	}
+	if f.Op == DateBetween {
+		if f.To == "" {
+			return nil, fmt.Errorf("date rule: between needs to")
+		}
+		_, toEnd, err := parseDateRange(f.To)
+		if err != nil {
+			return nil, err
+		}
+		end = toEnd
+		base.Name = fmt.Sprintf("date:%s:%s-%s", f.Op, f.Date, f.To)
+		base.Params = append(base.Params, CreateRuleParamString("to", f.To))
+	}
 
 	return func(img ImageFacts, ruleContext *RuleContext) (TriState, RuleResult) {
 		rr := base
@@ -243,10 +294,11 @@ func compileDateFilter(f *DateFilter) (CompiledFilter, error) {
 		rr.Actual = append(rr.Actual,
 			CreateRuleParamDate("date", (*img.TakenAt)))
 
-		t := *img.TakenAt
+		// calendar date of the capture place
+		t := wallClock(*img.TakenAt)
 
 		switch f.Op {
-		case DateOn:
+		case DateOn, DateBetween:
 			return returnBool(rr, !t.Before(start) && t.Before(end))
 		case DateBefore:
 			return returnBool(rr, t.Before(start))
@@ -629,7 +681,46 @@ func compileNotInChildAlbumsFilter(f *NotInChildAlbumsFilter) (CompiledFilter, e
 	}, nil
 }
 
+// compileNestedGroupFilter wraps a nested group into a single rule, the
+// nested trace is kept in RuleResult.Group. The unknown result of the group is
+// kept, not(unknown) is unknown too.
+func compileNestedGroupFilter(f *GroupFilter) (CompiledFilter, error) {
+	name := fmt.Sprintf("group:%s:%d", f.Op, len(f.Rules))
+	inner, err := compileGroupState(f.Group(), name)
+	if err != nil {
+		return nil, err
+	}
+	return func(img ImageFacts, ruleContext *RuleContext) (TriState, RuleResult) {
+		ts, gr := inner(img, ruleContext)
+		rr := RuleResult{
+			Name:  name,
+			Type:  f.FilterType(),
+			Op:    string(f.Op),
+			Group: &gr,
+		}
+		return returnValue(rr, ts)
+	}, nil
+}
+
+// CompileGroupFilter compiles the group, an unknown result does not match.
 func CompileGroupFilter(group RuleGroup, name string) (CompiledGroupFilter, error) {
+	inner, err := compileGroupState(group, name)
+	if err != nil {
+		return nil, err
+	}
+	return func(img ImageFacts, ruleContext *RuleContext) (bool, GroupRuleResult) {
+		ts, ret := inner(img, ruleContext)
+		return ts.Bool(), ret
+	}, nil
+}
+
+type compiledGroupState func(img ImageFacts, ruleContext *RuleContext) (TriState, GroupRuleResult)
+
+// compileGroupState evaluates the group in three-valued logic:
+// all: false if any is false, unknown if any is unknown, else true;
+// any: true if any is true, unknown if any is unknown, else false;
+// not (none of them): false if any is true, unknown if any is unknown, else true.
+func compileGroupState(group RuleGroup, name string) (compiledGroupState, error) {
 	if len(group.Rules) == 0 {
 		return nil, ErrEmptyFilter
 	}
@@ -644,45 +735,37 @@ func CompileGroupFilter(group RuleGroup, name string) (CompiledGroupFilter, erro
 		preds = append(preds, p)
 	}
 
+	// decisive: the value ending the evaluation, complete: the value if none is decisive or unknown
+	var decisive, result, complete TriState
 	switch group.Op {
 	case OpAll:
-		return func(img ImageFacts, ruleContext *RuleContext) (bool, GroupRuleResult) {
-			ret := GroupRuleResult{
-				Name: name,
-				Op:   group.Op,
-			}
-			for _, p := range preds {
-				ts, rr := p(img, ruleContext)
-				ret.RuleResults = append(ret.RuleResults, rr)
-				if !ts.Bool() {
-					ret.Result = false
-					return false, ret
-				}
-			}
-			ret.Result = true
-			return true, ret
-		}, nil
-
+		decisive, result, complete = EvalResultFalse, EvalResultFalse, EvalResultTrue
 	case OpAny:
-		return func(img ImageFacts, ruleContext *RuleContext) (bool, GroupRuleResult) {
-			ret := GroupRuleResult{
-				Name: name,
-				Op:   group.Op,
-			}
-			for _, p := range preds {
-				ts, rr := p(img, ruleContext)
-				ret.RuleResults = append(ret.RuleResults, rr)
-
-				if ts.Bool() {
-					ret.Result = true
-					return true, ret
-				}
-			}
-			ret.Result = false
-			return false, ret
-		}, nil
-
+		decisive, result, complete = EvalResultTrue, EvalResultTrue, EvalResultFalse
+	case OpNot:
+		decisive, result, complete = EvalResultTrue, EvalResultFalse, EvalResultTrue
 	default:
 		return nil, fmt.Errorf("unknown filter group op: %s", group.Op)
 	}
+
+	return func(img ImageFacts, ruleContext *RuleContext) (TriState, GroupRuleResult) {
+		ret := GroupRuleResult{
+			Name: name,
+			Op:   group.Op,
+		}
+		state := complete
+		for _, p := range preds {
+			ts, rr := p(img, ruleContext)
+			ret.RuleResults = append(ret.RuleResults, rr)
+			if ts == decisive {
+				state = result
+				break
+			}
+			if ts == EvalResultUnknow {
+				state = EvalResultUnknow
+			}
+		}
+		ret.Result = state.Bool()
+		return state, ret
+	}, nil
 }
@@ -0,0 +1,235 @@
+package ruleengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseRelative parses 12h, 30d, 2w, 6m, 1y and returns the start of the
+// window ending at now.
+func parseRelative(s string) (func(now time.Time) time.Time, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if len(s) < 2 {
+		return nil, fmt.Errorf("invalid relative date: %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid relative date: %q", s)
+	}
+	switch s[len(s)-1] {
+	case 'h':
+		return func(now time.Time) time.Time { return now.Add(-time.Duration(n) * time.Hour) }, nil
+	case 'd':
+		return func(now time.Time) time.Time { return now.AddDate(0, 0, -n) }, nil
+	case 'w':
+		return func(now time.Time) time.Time { return now.AddDate(0, 0, -7*n) }, nil
+	case 'm':
+		return func(now time.Time) time.Time { return addMonthsClamped(now, -n) }, nil
+	case 'y':
+		return func(now time.Time) time.Time { return addMonthsClamped(now, -12*n) }, nil
+	}
+	return nil, fmt.Errorf("invalid relative date unit: %q", s)
+}
+
+// addMonthsClamped adds the months keeping the day within the target month:
+// one month before Mar 31 is the end of February, not Mar 2 as AddDate gives.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	// the first day never overflows
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, months, 0)
+	day := min(t.Day(), daysIn(first.Month(), first.Year()))
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// daysIn returns the number of days of the month in the year.
+func daysIn(m time.Month, year int) int {
+	return time.Date(year, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseMonthDay parses mm[.dd] as month*100+day. A missing day is the first
+// or the last day of the month. The day must exist in the month, Feb 29 included.
+func parseMonthDay(s string, end bool) (int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) > 2 {
+		return 0, fmt.Errorf("invalid month/day: %q", s)
+	}
+	m, err := strconv.Atoi(parts[0])
+	if err != nil || m < 1 || m > 12 {
+		return 0, fmt.Errorf("invalid month: %q", s)
+	}
+	d := 1
+	if end {
+		d = 31
+	}
+	if len(parts) == 2 {
+		d, err = strconv.Atoi(parts[1])
+		// a leap year has every day
+		if err != nil || d < 1 || d > daysIn(time.Month(m), 2000) {
+			return 0, fmt.Errorf("invalid day: %q", s)
+		}
+	}
+	return m*100 + d, nil
+}
+
+// parseRange parses "a-b" or "a" with the given parser, a > b wraps around.
+func parseRange(s string, parse func(string, bool) (int, error)) (int, int, error) {
+	from, to, found := strings.Cut(s, "-")
+	start, err := parse(from, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		to = from
+	}
+	end, err := parse(to, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+func inRange(v, start, end int) bool {
+	if start <= end {
+		return v >= start && v <= end
+	}
+	return v >= start || v <= end
+}
+
+func parseWeekdays(s string) (map[time.Weekday]struct{}, error) {
+	ret := map[time.Weekday]struct{}{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(strings.ToLower(p))
+		if p == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(p); err == nil && n >= 0 && n <= 6 {
+			ret[time.Weekday(n)] = struct{}{}
+			continue
+		}
+		if len(p) >= 3 {
+			if wd, ok := weekdayNames[p[:3]]; ok {
+				ret[wd] = struct{}{}
+				continue
+			}
+		}
+		return nil, fmt.Errorf("invalid weekday: %q", p)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("missing weekday")
+	}
+	return ret, nil
+}
+
+func parseHour(s string, _ bool) (int, error) {
+	h, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid hour: %q", s)
+	}
+	return h, nil
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// compileRecurringDateFilter compiles the relative and recurring date ops.
+// Recurring ops use the local wall clock of the capture, relative ops record
+// the evaluation time in the result.
+func compileRecurringDateFilter(f *DateFilter) (CompiledFilter, error) {
+	var match func(taken time.Time, now time.Time) bool
+
+	switch f.Op {
+	case DateWithinLast:
+		since, err := parseRelative(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		match = func(taken, now time.Time) bool {
+			return !taken.Before(since(now)) && !taken.After(now)
+		}
+	case DateAnniversary:
+		match = func(taken, now time.Time) bool {
+			t := wallClock(taken)
+			month, day := t.Month(), t.Day()
+			// Feb 29 is celebrated on Feb 28 in the common years
+			if month == time.February && day == 29 && !isLeapYear(now.Year()) {
+				day = 28
+			}
+			return month == now.Month() && day == now.Day() && t.Year() < now.Year()
+		}
+	case DateMonthDay:
+		start, end, err := parseRange(f.Value, parseMonthDay)
+		if err != nil {
+			return nil, err
+		}
+		match = func(taken, _ time.Time) bool {
+			t := wallClock(taken)
+			return inRange(int(t.Month())*100+t.Day(), start, end)
+		}
+	case DateWeekday:
+		days, err := parseWeekdays(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		match = func(taken, _ time.Time) bool {
+			_, ok := days[wallClock(taken).Weekday()]
+			return ok
+		}
+	case DateHour:
+		start, end, err := parseRange(f.Value, parseHour)
+		if err != nil {
+			return nil, err
+		}
+		// 24 is the midnight, as an end it closes the day
+		start %= 24
+		if start == end || !strings.Contains(f.Value, "-") {
+			// single hour
+			end = start + 1
+		}
+		match = func(taken, _ time.Time) bool {
+			h := wallClock(taken).Hour()
+			if start < end {
+				return h >= start && h < end
+			}
+			return h >= start || h < end
+		}
+	default:
+		return nil, fmt.Errorf("unknown date op: %s", f.Op)
+	}
+
+	name := fmt.Sprintf("date:%s:%s", f.Op, f.Value)
+	base := RuleResult{
+		Name: name,
+		Op:   string(f.Op),
+		Type: "date",
+	}
+	if f.Value != "" {
+		base.Params = append(base.Params, CreateRuleParamString("value", f.Value))
+	}
+	relative := f.IsRelative()
+
+	return func(img ImageFacts, ruleContext *RuleContext) (TriState, RuleResult) {
+		rr := base
+		now := ruleContext.EvaluationTime()
+		if relative {
+			rr.Actual = append(rr.Actual, CreateRuleParamDate("evaluated_at", now))
+		}
+		if img.TakenAt == nil {
+			rr.Actual = append(rr.Actual, CreateRuleParamEmpty("date"))
+			return returnValue(rr, EvalResultUnknow)
+		}
+		rr.Actual = append(rr.Actual, CreateRuleParamDate("date", *img.TakenAt))
+		return returnBool(rr, match(*img.TakenAt, now))
+	}, nil
+}
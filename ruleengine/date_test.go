@@ -0,0 +1,199 @@
+package ruleengine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRelative(t *testing.T) {
+	now := time.Date(2024, time.March, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"12h", time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{"30d", time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)},
+		{"2w", time.Date(2024, time.March, 17, 12, 0, 0, 0, time.UTC)},
+		{"1m", time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)},
+		{"13m", time.Date(2023, time.February, 28, 12, 0, 0, 0, time.UTC)},
+		{"2m", time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)},
+		{" 1Y ", time.Date(2023, time.March, 31, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			since, err := parseRelative(tc.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := since(now); !got.Equal(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+	t.Run("1y from leap day", func(t *testing.T) {
+		since, err := parseRelative("1y")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := time.Date(2023, time.February, 28, 12, 0, 0, 0, time.UTC)
+		if got := since(time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)); !got.Equal(want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	})
+	for _, value := range []string{"", "d", "0d", "-1d", "3x", "1.5d"} {
+		t.Run("invalid "+value, func(t *testing.T) {
+			if _, err := parseRelative(value); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	tests := []struct {
+		value      string
+		start, end time.Time
+	}{
+		{"2023", time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"2023.12", time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024.02.29", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			start, end, err := parseDateRange(tc.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !start.Equal(tc.start) || !end.Equal(tc.end) {
+				t.Fatalf("expected %v - %v, got %v - %v", tc.start, tc.end, start, end)
+			}
+		})
+	}
+	t.Run("too many parts", func(t *testing.T) {
+		if _, _, err := parseDateRange("2023.01.02.03"); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		parse      func(string, bool) (int, error)
+		start, end int
+	}{
+		{"month", "12", parseMonthDay, 1201, 1231},
+		{"month day", "12.24", parseMonthDay, 1224, 1224},
+		{"month day range", "12.24-01.06", parseMonthDay, 1224, 106},
+		{"leap day", "02.29", parseMonthDay, 229, 229},
+		{"last day of april", "04.30", parseMonthDay, 430, 430},
+		{"month range", "06-08", parseMonthDay, 601, 831},
+		{"hour", "5", parseHour, 5, 5},
+		{"hour range", "22-5", parseHour, 22, 5},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, err := parseRange(tc.value, tc.parse)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if start != tc.start || end != tc.end {
+				t.Fatalf("expected %d-%d, got %d-%d", tc.start, tc.end, start, end)
+			}
+		})
+	}
+	invalid := []struct {
+		name  string
+		value string
+		parse func(string, bool) (int, error)
+	}{
+		{"month 13", "13", parseMonthDay},
+		{"day 32", "01.32", parseMonthDay},
+		{"february 30", "02.30", parseMonthDay},
+		{"february 31", "02.31", parseMonthDay},
+		{"april 31", "04.31", parseMonthDay},
+		{"range to a missing day", "01.01-06.31", parseMonthDay},
+		{"too many parts", "01.02.03", parseMonthDay},
+		{"hour 25", "25", parseHour},
+		{"negative hour", "-1", parseHour},
+		{"not a number", "x-5", parseHour},
+	}
+	for _, tc := range invalid {
+		t.Run("invalid "+tc.name, func(t *testing.T) {
+			if _, _, err := parseRange(tc.value, tc.parse); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	days, err := parseWeekdays("sat, Sunday,1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, wd := range []time.Weekday{time.Saturday, time.Sunday, time.Monday} {
+		if _, ok := days[wd]; !ok {
+			t.Fatalf("missing %v", wd)
+		}
+	}
+	if len(days) != 3 {
+		t.Fatalf("expected 3 days, got %d", len(days))
+	}
+	for _, value := range []string{"", "7", "xyz", "sa"} {
+		t.Run("invalid "+value, func(t *testing.T) {
+			if _, err := parseWeekdays(value); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestRecurringDateFilter(t *testing.T) {
+	at := func(year int, month time.Month, day, hour int) *time.Time {
+		t := time.Date(year, month, day, hour, 30, 0, 0, time.UTC)
+		return &t
+	}
+	tests := []struct {
+		name  string
+		op    DateOp
+		value string
+		taken *time.Time
+		now   time.Time
+		want  TriState
+	}{
+		{"within last", DateWithinLast, "30d", at(2024, time.March, 10, 8), *at(2024, time.March, 31, 12), EvalResultTrue},
+		{"within last, too old", DateWithinLast, "30d", at(2024, time.February, 10, 8), *at(2024, time.March, 31, 12), EvalResultFalse},
+		{"within last, future", DateWithinLast, "30d", at(2024, time.April, 10, 8), *at(2024, time.March, 31, 12), EvalResultFalse},
+		{"anniversary", DateAnniversary, "", at(2020, time.May, 4, 8), *at(2024, time.May, 4, 12), EvalResultTrue},
+		{"anniversary, same year", DateAnniversary, "", at(2024, time.May, 4, 8), *at(2024, time.May, 4, 20), EvalResultFalse},
+		{"anniversary, feb 29 in a common year", DateAnniversary, "", at(2020, time.February, 29, 8), *at(2023, time.February, 28, 12), EvalResultTrue},
+		{"anniversary, feb 29 in a leap year", DateAnniversary, "", at(2020, time.February, 29, 8), *at(2024, time.February, 28, 12), EvalResultFalse},
+		{"anniversary, feb 29 on the day", DateAnniversary, "", at(2020, time.February, 29, 8), *at(2024, time.February, 29, 12), EvalResultTrue},
+		{"monthday wraps the year", DateMonthDay, "12.24-01.06", at(2023, time.January, 2, 8), time.Time{}, EvalResultTrue},
+		{"monthday outside", DateMonthDay, "12.24-01.06", at(2023, time.January, 7, 8), time.Time{}, EvalResultFalse},
+		{"monthday feb 29", DateMonthDay, "02.29", at(2024, time.February, 29, 8), time.Time{}, EvalResultTrue},
+		{"weekday", DateWeekday, "sat,sun", at(2024, time.May, 4, 8), time.Time{}, EvalResultTrue},
+		{"weekday, monday", DateWeekday, "sat,sun", at(2024, time.May, 6, 8), time.Time{}, EvalResultFalse},
+		{"hour wraps midnight", DateHour, "22-5", at(2024, time.May, 4, 2), time.Time{}, EvalResultTrue},
+		{"hour end exclusive", DateHour, "22-5", at(2024, time.May, 4, 5), time.Time{}, EvalResultFalse},
+		{"single hour", DateHour, "5", at(2024, time.May, 4, 5), time.Time{}, EvalResultTrue},
+		{"hour 24 is midnight", DateHour, "24", at(2024, time.May, 4, 0), time.Time{}, EvalResultTrue},
+		{"hour until 24", DateHour, "20-24", at(2024, time.May, 4, 23), time.Time{}, EvalResultTrue},
+		{"same start and end", DateHour, "7-7", at(2024, time.May, 4, 7), time.Time{}, EvalResultTrue},
+		{"no date", DateWeekday, "sat", nil, time.Time{}, EvalResultUnknow},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := compileDateFilter(&DateFilter{Type: "date", Op: tc.op, Value: tc.value})
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			got, _ := f(ImageFacts{TakenAt: tc.taken}, &RuleContext{Now: tc.now})
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
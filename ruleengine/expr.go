@@ -0,0 +1,699 @@
+package ruleengine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ignisVeneficus/lumenta/data"
+)
+
+// Expression rule: a small boolean language over the image facts and the metadata.
+//
+//	rating >= 4 && camera =~ "X-T"
+//	(year == 2023 || "Travel" in tags) && !exists(meta.flash)
+//
+// Operators, loosest first: ||, &&, ! and the comparisons
+// ==, !=, <, <=, >, >=, =~, !~ (regexp, the pattern is a string literal) and in (list).
+// Names: path, filename, ext, root, rating, width, height, aspect, taken,
+// year, month, day, hour, weekday (sunday = 0), lat, lon, tags;
+// any other name is a metadata alias, meta.<alias> always is.
+// exists(name) reports whether the value is known.
+//
+// Comparisons follow the meta rule: numbers numerically, taken to yyyy[.mm[.dd]] by period,
+// strings case-insensitive, a list matches if any element matches (tags by tag path).
+// An unknown value makes the comparison unknown; &&, || and ! use three-valued logic.
+
+type exprKind int
+
+const (
+	exprNull exprKind = iota
+	exprBool
+	exprNumber
+	exprString
+	exprTime
+	exprList
+)
+
+type exprValue struct {
+	kind exprKind
+	b    bool
+	n    float64
+	s    string
+	t    time.Time
+	list []string
+	// list of tag paths, elements match by tagMatches
+	tags bool
+}
+
+func exprState(s TriState) exprValue {
+	if s == EvalResultUnknow {
+		return exprValue{}
+	}
+	return exprValue{kind: exprBool, b: s == EvalResultTrue}
+}
+
+func (v exprValue) state() TriState {
+	if v.kind != exprBool {
+		return EvalResultUnknow
+	}
+	return boolState(v.b)
+}
+
+// plain is the value as a comparison operand.
+func (v exprValue) plain() string {
+	switch v.kind {
+	case exprBool:
+		return strconv.FormatBool(v.b)
+	case exprNumber:
+		return strconv.FormatFloat(v.n, 'f', -1, 64)
+	case exprTime:
+		return v.t.Format("2006.01.02")
+	case exprList:
+		return strings.Join(v.list, ",")
+	}
+	return v.s
+}
+
+func (v exprValue) String() string {
+	switch v.kind {
+	case exprNull:
+		return "unknown"
+	case exprString:
+		return strconv.Quote(v.s)
+	case exprTime:
+		return v.t.Format("2006.01.02 15:04:05")
+	case exprList:
+		q := make([]string, len(v.list))
+		for i, e := range v.list {
+			q[i] = strconv.Quote(e)
+		}
+		return "[" + strings.Join(q, ", ") + "]"
+	}
+	return v.plain()
+}
+
+func (v exprValue) compare() metaCompare {
+	switch v.kind {
+	case exprBool:
+		return boolCompare(v.b)
+	case exprNumber:
+		return numberCompare(v.n)
+	case exprTime:
+		return dateCompare(v.t)
+	}
+	return stringCompare(v.plain())
+}
+
+// elements are the scalar values of a list, or the value itself.
+func (v exprValue) elements() []exprValue {
+	if v.kind != exprList {
+		return []exprValue{v}
+	}
+	ret := make([]exprValue, len(v.list))
+	for i, e := range v.list {
+		ret[i] = exprValue{kind: exprString, s: e}
+	}
+	return ret
+}
+
+func exprNumberPoi[T int | float64](v *T) exprValue {
+	if v == nil {
+		return exprValue{}
+	}
+	return exprValue{kind: exprNumber, n: float64(*v)}
+}
+
+func exprMetadata(mv data.MetadataValue) exprValue {
+	switch mv.Type {
+	case data.MetaInt, data.MetaFloat, data.MetaRational:
+		if n, ok := mv.AsNumber(); ok {
+			return exprValue{kind: exprNumber, n: n}
+		}
+	case data.MetaDateTime:
+		if t, ok := mv.Value.(time.Time); ok {
+			return exprValue{kind: exprTime, t: wallClock(t)}
+		}
+	case data.MetaBool:
+		if b, ok := metaBool(mv.Value); ok {
+			return exprValue{kind: exprBool, b: b}
+		}
+	case data.MetaList:
+		if list, ok := mv.AsList(); ok {
+			return exprValue{kind: exprList, list: list}
+		}
+	default:
+		return exprValue{kind: exprString, s: fmt.Sprint(mv.Value)}
+	}
+	return exprValue{}
+}
+
+// exprFact resolves a name on the image.
+func exprFact(img ImageFacts, name string) exprValue {
+	var taken *time.Time
+	if img.TakenAt != nil {
+		t := wallClock(*img.TakenAt)
+		taken = &t
+	}
+	takenPart := func(part func(time.Time) int) exprValue {
+		if taken == nil {
+			return exprValue{}
+		}
+		return exprValue{kind: exprNumber, n: float64(part(*taken))}
+	}
+
+	switch name {
+	case "path":
+		return exprValue{kind: exprString, s: img.Path}
+	case "filename":
+		return exprValue{kind: exprString, s: img.Filename}
+	case "ext":
+		return exprValue{kind: exprString, s: img.Ext}
+	case "root":
+		return exprValue{kind: exprString, s: img.Root}
+	case "rating":
+		return exprNumberPoi(img.Rating)
+	case "width":
+		return exprValue{kind: exprNumber, n: float64(img.Width)}
+	case "height":
+		return exprValue{kind: exprNumber, n: float64(img.Height)}
+	case "aspect":
+		if img.Height == 0 {
+			return exprValue{}
+		}
+		return exprValue{kind: exprNumber, n: float64(img.Width) / float64(img.Height)}
+	case "taken":
+		if taken == nil {
+			return exprValue{}
+		}
+		return exprValue{kind: exprTime, t: *taken}
+	case "year":
+		return takenPart(time.Time.Year)
+	case "month":
+		return takenPart(func(t time.Time) int { return int(t.Month()) })
+	case "day":
+		return takenPart(time.Time.Day)
+	case "hour":
+		return takenPart(time.Time.Hour)
+	case "weekday":
+		return takenPart(func(t time.Time) int { return int(t.Weekday()) })
+	case "lat":
+		return exprNumberPoi(img.Latitude)
+	case "lon":
+		return exprNumberPoi(img.Longitude)
+	case "tags":
+		return exprValue{kind: exprList, list: img.Tags, tags: true}
+	}
+	name = strings.TrimPrefix(name, "meta.")
+	mv, ok := img.Metadata[name]
+	if !ok {
+		return exprValue{}
+	}
+	return exprMetadata(mv)
+}
+
+// exprEqual reports whether the scalar a equals the list element e.
+func exprEqual(a exprValue, e string, tags bool) (bool, bool) {
+	if tags {
+		return tagMatches(e, a.plain()), true
+	}
+	c, ok := a.compare()(e)
+	return ok && c == 0, ok
+}
+
+func exprCompare(op string, l, r exprValue) TriState {
+	if l.kind == exprNull || r.kind == exprNull || r.kind == exprList {
+		return EvalResultUnknow
+	}
+	if l.kind == exprList {
+		found := false
+		for _, e := range l.list {
+			eq, ok := exprEqual(r, e, l.tags)
+			if !ok {
+				return EvalResultUnknow
+			}
+			switch op {
+			case "==", "!=":
+				found = found || eq
+			default:
+				c, _ := stringCompare(e)(r.plain())
+				found = found || compareResult(op, c)
+			}
+		}
+		if op == "!=" {
+			return boolState(!found)
+		}
+		return boolState(found)
+	}
+	c, ok := l.compare()(r.plain())
+	if !ok {
+		return EvalResultUnknow
+	}
+	return boolState(compareResult(op, c))
+}
+
+func compareResult(op string, c int) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func exprIn(l, r exprValue) TriState {
+	if l.kind == exprNull || r.kind != exprList {
+		return EvalResultUnknow
+	}
+	for _, a := range l.elements() {
+		for _, e := range r.list {
+			eq, ok := exprEqual(a, e, r.tags)
+			if !ok {
+				return EvalResultUnknow
+			}
+			if eq {
+				return EvalResultTrue
+			}
+		}
+	}
+	return EvalResultFalse
+}
+
+func exprMatch(l exprValue, re *regexp.Regexp) TriState {
+	if l.kind == exprNull {
+		return EvalResultUnknow
+	}
+	for _, e := range l.elements() {
+		if re.MatchString(e.plain()) {
+			return EvalResultTrue
+		}
+	}
+	return EvalResultFalse
+}
+
+type exprNode struct {
+	op    string // lit, list, name, exists, !, &&, ||, comparison, in, =~, !~
+	src   string
+	value exprValue // lit, list
+	name  string    // name, exists
+	re    *regexp.Regexp
+	args  []*exprNode
+}
+
+// eval evaluates every operand, without short circuit, so the trace is complete.
+// The values of the non literal nodes are appended to the trace, innermost first.
+func (n *exprNode) eval(img ImageFacts, trace *[]string) exprValue {
+	args := make([]exprValue, len(n.args))
+	for i, a := range n.args {
+		args[i] = a.eval(img, trace)
+	}
+
+	var ret exprValue
+	switch n.op {
+	case "lit", "list":
+		return n.value
+	case "name":
+		ret = exprFact(img, n.name)
+	case "exists":
+		ret = exprValue{kind: exprBool, b: exprFact(img, n.name).kind != exprNull}
+	case "!":
+		switch args[0].state() {
+		case EvalResultTrue:
+			ret = exprState(EvalResultFalse)
+		case EvalResultFalse:
+			ret = exprState(EvalResultTrue)
+		}
+	case "&&", "||":
+		decisive := n.op == "||"
+		unknown := false
+		for _, a := range args {
+			switch a.state() {
+			case EvalResultUnknow:
+				unknown = true
+			case boolState(decisive):
+				return n.record(exprState(boolState(decisive)), trace)
+			}
+		}
+		if !unknown {
+			ret = exprState(boolState(!decisive))
+		}
+	case "in":
+		ret = exprState(exprIn(args[0], args[1]))
+	case "=~":
+		ret = exprState(exprMatch(args[0], n.re))
+	case "!~":
+		switch exprMatch(args[0], n.re) {
+		case EvalResultTrue:
+			ret = exprState(EvalResultFalse)
+		case EvalResultFalse:
+			ret = exprState(EvalResultTrue)
+		}
+	default:
+		ret = exprState(exprCompare(n.op, args[0], args[1]))
+	}
+	return n.record(ret, trace)
+}
+
+func (n *exprNode) record(v exprValue, trace *[]string) exprValue {
+	*trace = append(*trace, fmt.Sprintf("%s → %s", n.src, v))
+	return v
+}
+
+type exprToken struct {
+	kind  string // num, str, name, op, eof
+	text  string
+	start int
+	end   int
+}
+
+var exprOps = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")", "[", "]", ",", "-"}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var ret []exprToken
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '"' || c == '`':
+			end := i + 1
+			for end < len(src) && src[end] != src[i] {
+				if src[end] == '\\' && c == '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			s, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %w", i, err)
+			}
+			ret = append(ret, exprToken{kind: "str", text: s, start: i, end: end + 1})
+			i = end + 1
+			continue
+		case unicode.IsDigit(c):
+			end := i
+			for end < len(src) && (unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+			ret = append(ret, exprToken{kind: "num", text: src[i:end], start: i, end: end})
+			i = end
+			continue
+		case unicode.IsLetter(c) || c == '_':
+			end := i
+			for end < len(src) {
+				r := rune(src[end])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+					break
+				}
+				end++
+			}
+			ret = append(ret, exprToken{kind: "name", text: src[i:end], start: i, end: end})
+			i = end
+			continue
+		}
+		op := ""
+		for _, o := range exprOps {
+			if strings.HasPrefix(src[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+		ret = append(ret, exprToken{kind: "op", text: op, start: i, end: i + len(op)})
+		i += len(op)
+	}
+	return append(ret, exprToken{kind: "eof", start: len(src), end: len(src)}), nil
+}
+
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != "op" && !(t.kind == "name" && t.text == "in") {
+		return false
+	}
+	for _, o := range ops {
+		if t.text == o {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.unexpected()
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) unexpected() error {
+	t := p.peek()
+	if t.kind == "eof" {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at %d", p.src[t.start:t.end], t.start)
+}
+
+// node closes a node started at the token start.
+func (p *exprParser) node(start int, n *exprNode) *exprNode {
+	n.src = p.src[p.tokens[start].start:p.tokens[p.pos-1].end]
+	return n
+}
+
+func (p *exprParser) parseBinary(ops []string, operand func() (*exprNode, error)) (*exprNode, error) {
+	start := p.pos
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(ops...) {
+		op := p.next().text
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = p.node(start, &exprNode{op: op, args: []*exprNode{left, right}})
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseOr() (*exprNode, error) {
+	return p.parseBinary([]string{"||"}, p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (*exprNode, error) {
+	return p.parseBinary([]string{"&&"}, p.parseUnary)
+}
+
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	start := p.pos
+	if p.isOp("!") {
+		p.next()
+		arg, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return p.node(start, &exprNode{op: "!", args: []*exprNode{arg}}), nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (*exprNode, error) {
+	start := p.pos
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=", "=~", "!~", "in") {
+		return left, nil
+	}
+	op := p.next().text
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	n := &exprNode{op: op, args: []*exprNode{left, right}}
+	switch op {
+	case "=~", "!~":
+		if right.op != "lit" || right.value.kind != exprString {
+			return nil, fmt.Errorf("%s needs a string pattern at %d", op, p.tokens[start].start)
+		}
+		n.re, err = regexp.Compile(right.value.s)
+		if err != nil {
+			return nil, err
+		}
+	case "in":
+		if right.op == "lit" {
+			return nil, fmt.Errorf("in needs a list at %d", p.tokens[start].start)
+		}
+	default:
+		if right.op == "list" {
+			return nil, fmt.Errorf("%s can not compare to a list at %d", op, p.tokens[start].start)
+		}
+	}
+	return p.node(start, n), nil
+}
+
+func (p *exprParser) parseOperand() (*exprNode, error) {
+	start := p.pos
+	t := p.next()
+	switch t.kind {
+	case "str":
+		return p.node(start, &exprNode{op: "lit", value: exprValue{kind: exprString, s: t.text}}), nil
+	case "num":
+		return p.number(start, t.text)
+	case "name":
+		switch t.text {
+		case "true", "false":
+			return p.node(start, &exprNode{op: "lit", value: exprValue{kind: exprBool, b: t.text == "true"}}), nil
+		case "in":
+			p.pos--
+			return nil, p.unexpected()
+		case "exists":
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			arg := p.next()
+			if arg.kind != "name" {
+				p.pos--
+				return nil, p.unexpected()
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return p.node(start, &exprNode{op: "exists", name: arg.text}), nil
+		}
+		return p.node(start, &exprNode{op: "name", name: t.text}), nil
+	case "op":
+		switch t.text {
+		case "-":
+			n := p.next()
+			if n.kind != "num" {
+				p.pos--
+				return nil, p.unexpected()
+			}
+			return p.number(start, "-"+n.text)
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			var list []string
+			for !p.isOp("]") {
+				if len(list) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				e, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				if e.op != "lit" {
+					return nil, fmt.Errorf("list elements must be literals: %s", e.src)
+				}
+				list = append(list, e.value.plain())
+			}
+			p.next()
+			return p.node(start, &exprNode{op: "list", value: exprValue{kind: exprList, list: list}}), nil
+		}
+	}
+	p.pos = start
+	return nil, p.unexpected()
+}
+
+func (p *exprParser) number(start int, text string) (*exprNode, error) {
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", text)
+	}
+	return p.node(start, &exprNode{op: "lit", value: exprValue{kind: exprNumber, n: n}}), nil
+}
+
+// parseExpr parses and checks an expression.
+func parseExpr(src string) (*exprNode, error) {
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{src: src, tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != "eof" {
+		return nil, p.unexpected()
+	}
+	if (n.op == "lit" && n.value.kind != exprBool) || n.op == "list" {
+		return nil, fmt.Errorf("expression is not a condition")
+	}
+	return n, nil
+}
+
+func compileExpressionFilter(f *ExpressionFilter) (CompiledFilter, error) {
+	expr := strings.TrimSpace(f.Expr)
+	if expr == "" {
+		return nil, fmt.Errorf("expr rule: missing expression")
+	}
+	root, err := parseExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("expr rule %q: %w", expr, err)
+	}
+
+	base := RuleResult{
+		Name: "expr:" + expr,
+		Op:   "expr",
+		Type: "expr",
+		Params: []RuleParam{
+			CreateRuleParamString("expr", expr),
+		},
+	}
+
+	return func(img ImageFacts, ruleContext *RuleContext) (TriState, RuleResult) {
+		rr := base
+		var trace []string
+		v := root.eval(img, &trace)
+		rr.Actual = []RuleParam{CreateRuleParamStrings("trace", trace)}
+		return returnValue(rr, v.state())
+	}, nil
+}
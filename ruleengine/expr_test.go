@@ -0,0 +1,127 @@
+package ruleengine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLexExpr(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		kinds []string
+		texts []string
+	}{
+		{"comparison", "rating >= 4", []string{"name", "op", "num", "eof"}, []string{"rating", ">=", "4", ""}},
+		{"dotted name", "meta.flash", []string{"name", "eof"}, []string{"meta.flash", ""}},
+		{"escaped string", `"a\"b"`, []string{"str", "eof"}, []string{`a"b`, ""}},
+		{"raw string", "`a\\b`", []string{"str", "eof"}, []string{`a\b`, ""}},
+		{"decimal", "aspect < 1.5", []string{"name", "op", "num", "eof"}, []string{"aspect", "<", "1.5", ""}},
+		{"longest operator", "a!=b&&!c", []string{"name", "op", "name", "op", "op", "name", "eof"}, []string{"a", "!=", "b", "&&", "!", "c", ""}},
+		{"list", `["x", 2]`, []string{"op", "str", "op", "num", "op", "eof"}, []string{"[", "x", ",", "2", "]", ""}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens, err := lexExpr(tc.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tokens) != len(tc.kinds) {
+				t.Fatalf("expected %d tokens, got %d: %v", len(tc.kinds), len(tokens), tokens)
+			}
+			for i, tok := range tokens {
+				if tok.kind != tc.kinds[i] || tok.text != tc.texts[i] {
+					t.Fatalf("token %d: expected %s %q, got %s %q", i, tc.kinds[i], tc.texts[i], tok.kind, tok.text)
+				}
+			}
+		})
+	}
+}
+
+func TestLexExprErrors(t *testing.T) {
+	for _, src := range []string{`"open`, "rating # 4", "a & b", "a | b"} {
+		t.Run(src, func(t *testing.T) {
+			if _, err := lexExpr(src); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"missing operand", "rating >="},
+		{"unbalanced parenthesis", "(rating > 1"},
+		{"trailing token", "rating > 1 2"},
+		{"not a condition", "4"},
+		{"list is not a condition", "[1, 2]"},
+		{"in needs a list", `"x" in "y"`},
+		{"compare to a list", "rating == [1, 2]"},
+		{"list of names", "rating in [year]"},
+		{"exists needs a name", `exists("x")`},
+		{"invalid regexp", `filename =~ "("`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseExpr(tc.src); err == nil {
+				t.Fatalf("expected error for %q", tc.src)
+			}
+		})
+	}
+}
+
+func TestExpressionFilter(t *testing.T) {
+	rating := 4
+	taken := time.Date(2023, time.July, 14, 21, 30, 0, 0, time.UTC)
+	img := ImageFacts{
+		Filename: "DSCF1234",
+		Ext:      "jpg",
+		Rating:   &rating,
+		TakenAt:  &taken,
+		Width:    6000,
+		Height:   4000,
+		Tags:     []string{"Travel/Iceland"},
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		img  ImageFacts
+		want TriState
+	}{
+		{"number", "rating >= 4", img, EvalResultTrue},
+		{"negative number", "rating > -1", img, EvalResultTrue},
+		{"precedence", "rating == 1 || rating == 4 && year == 2023", img, EvalResultTrue},
+		{"parenthesis", "(rating == 1 || rating == 4) && year == 2022", img, EvalResultFalse},
+		{"taken period", `taken == "2023.07"`, img, EvalResultTrue},
+		{"hour", "hour >= 21", img, EvalResultTrue},
+		{"aspect", "aspect == 1.5", img, EvalResultTrue},
+		{"string case insensitive", `ext == "JPG"`, img, EvalResultTrue},
+		{"regexp", `filename =~ "^DSCF"`, img, EvalResultTrue},
+		{"negated regexp", `filename !~ "^DSCF"`, img, EvalResultFalse},
+		{"in list", "month in [6, 7, 8]", img, EvalResultTrue},
+		{"tag path", `"Travel" in tags`, img, EvalResultTrue},
+		{"exists", "exists(rating) && !exists(lat)", img, EvalResultTrue},
+		{"unknown value", "lat > 0", img, EvalResultUnknow},
+		{"not unknown", "!(lat > 0)", img, EvalResultUnknow},
+		{"unknown or true", "lat > 0 || rating == 4", img, EvalResultTrue},
+		{"unknown and false", "lat > 0 && rating == 1", img, EvalResultFalse},
+		{"unknown and true", "lat > 0 && rating == 4", img, EvalResultUnknow},
+		{"missing rating", "rating >= 1", ImageFacts{}, EvalResultUnknow},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := compileExpressionFilter(&ExpressionFilter{Type: "expr", Expr: tc.expr})
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			got, _ := f(tc.img, nil)
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package ruleengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/ignisVeneficus/lumenta/geocode"
+)
+
+// geoPolygon is one polygon of a GeoJSON file: the first ring is the outer
+// boundary, the others are holes. Points are [lon, lat].
+type geoPolygon [][][]float64
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Features    []geoJSON       `json:"features"`
+	Geometries  []geoJSON       `json:"geometries"`
+}
+
+func (g *geoJSON) polygons() ([]geoPolygon, error) {
+	switch g.Type {
+	case "FeatureCollection":
+		var ret []geoPolygon
+		for _, f := range g.Features {
+			p, err := f.polygons()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, p...)
+		}
+		return ret, nil
+	case "GeometryCollection":
+		var ret []geoPolygon
+		for _, f := range g.Geometries {
+			p, err := f.polygons()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, p...)
+		}
+		return ret, nil
+	case "Feature":
+		if g.Geometry == nil {
+			return nil, nil
+		}
+		return g.Geometry.polygons()
+	case "Polygon":
+		var p geoPolygon
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return nil, err
+		}
+		return []geoPolygon{p}, nil
+	case "MultiPolygon":
+		var p []geoPolygon
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	// points, lines: no area
+	return nil, nil
+}
+
+func loadGeoPolygons(path string) ([]geoPolygon, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc geoJSON
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	polys, err := doc.polygons()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(polys) == 0 {
+		return nil, fmt.Errorf("%s: no polygon found", path)
+	}
+	return polys, nil
+}
+
+// ringContains is the even-odd ray casting test on the lon/lat plane.
+func ringContains(ring [][]float64, lat, lon float64) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		if len(ring[i]) < 2 || len(ring[j]) < 2 {
+			continue
+		}
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
+
+func (p geoPolygon) contains(lat, lon float64) bool {
+	if len(p) == 0 || !ringContains(p[0], lat, lon) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+// polygonsCenter is the average of the outer ring vertices, used for the
+// distance in the rule result.
+func polygonsCenter(polys []geoPolygon) (float64, float64) {
+	var lat, lon float64
+	n := 0
+	for _, p := range polys {
+		if len(p) == 0 {
+			continue
+		}
+		for _, pt := range p[0] {
+			if len(pt) < 2 {
+				continue
+			}
+			lon += pt[0]
+			lat += pt[1]
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return lat / float64(n), lon / float64(n)
+}
+
+func inBBox(box []float64, lat, lon float64) bool {
+	south, west, north, east := box[0], box[1], box[2], box[3]
+	if lat < south || lat > north {
+		return false
+	}
+	if west <= east {
+		return lon >= west && lon <= east
+	}
+	// crosses the antimeridian
+	return lon >= west || lon <= east
+}
+
+func bboxCenter(box []float64) (float64, float64) {
+	south, west, north, east := box[0], box[1], box[2], box[3]
+	if west > east {
+		east += 360
+	}
+	lon := (west + east) / 2
+	if lon >= 180 {
+		lon -= 360
+	}
+	return (south + north) / 2, lon
+}
+
+func roundKm(km float64) float64 {
+	return math.Round(km*1000) / 1000
+}
+
+func compileGeoFilter(f *GeoFilter) (CompiledFilter, error) {
+	var params []RuleParam
+	var centerLat, centerLon float64
+	var match func(lat, lon, dist float64) bool
+
+	switch f.Op {
+	case GeoBBox:
+		if len(f.BBox) != 4 || f.BBox[0] > f.BBox[2] {
+			return nil, fmt.Errorf("geo rule: bbox needs south, west, north, east")
+		}
+		centerLat, centerLon = bboxCenter(f.BBox)
+		params = append(params,
+			CreateRuleParamFloat64("south", f.BBox[0]),
+			CreateRuleParamFloat64("west", f.BBox[1]),
+			CreateRuleParamFloat64("north", f.BBox[2]),
+			CreateRuleParamFloat64("east", f.BBox[3]))
+		match = func(lat, lon, _ float64) bool {
+			return inBBox(f.BBox, lat, lon)
+		}
+	case GeoRadius:
+		if len(f.Center) != 2 {
+			return nil, fmt.Errorf("geo rule: center needs lat, lon")
+		}
+		if f.RadiusKm <= 0 {
+			return nil, fmt.Errorf("geo rule: radius_km must be positive")
+		}
+		centerLat, centerLon = f.Center[0], f.Center[1]
+		params = append(params,
+			CreateRuleParamFloat64("latitude", centerLat),
+			CreateRuleParamFloat64("longitude", centerLon),
+			CreateRuleParamFloat64("radius_km", f.RadiusKm))
+		match = func(_, _, dist float64) bool {
+			return dist <= f.RadiusKm
+		}
+	case GeoPolygon:
+		if f.Polygon == "" {
+			return nil, fmt.Errorf("geo rule: missing polygon file")
+		}
+		polys, err := loadGeoPolygons(f.Polygon)
+		if err != nil {
+			return nil, err
+		}
+		centerLat, centerLon = polygonsCenter(polys)
+		params = append(params, CreateRuleParamString("polygon", f.Polygon))
+		match = func(lat, lon, _ float64) bool {
+			for _, p := range polys {
+				if p.contains(lat, lon) {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		return nil, fmt.Errorf("geo rule: unknown op %q", f.Op)
+	}
+
+	name := fmt.Sprintf("geo:%s", f.Op)
+	base := RuleResult{
+		Name:   name,
+		Op:     string(f.Op),
+		Type:   "geo",
+		Params: params,
+	}
+
+	return func(img ImageFacts, ruleContext *RuleContext) (TriState, RuleResult) {
+		rr := base
+		if img.Latitude == nil || img.Longitude == nil {
+			rr.Actual = append(rr.Actual,
+				CreateRuleParamEmpty("latitude"),
+				CreateRuleParamEmpty("longitude"))
+			return returnValue(rr, EvalResultUnknow)
+		}
+		lat, lon := *img.Latitude, *img.Longitude
+		// distance from the center of the area
+		dist := geocode.Distance(lat, lon, centerLat, centerLon)
+		rr.Actual = append(rr.Actual,
+			CreateRuleParamFloat64("latitude", lat),
+			CreateRuleParamFloat64("longitude", lon),
+			CreateRuleParamFloat64("distance_km", roundKm(dist)))
+		return returnBool(rr, match(lat, lon, dist))
+	}, nil
+}
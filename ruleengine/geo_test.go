@@ -0,0 +1,57 @@
+package ruleengine
+
+import "testing"
+
+func TestRingContains(t *testing.T) {
+	// lon/lat pairs as in GeoJSON
+	square := [][]float64{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	concave := [][]float64{{0, 0}, {10, 0}, {10, 10}, {5, 5}, {0, 10}, {0, 0}}
+	unclosed := [][]float64{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+
+	tests := []struct {
+		name     string
+		ring     [][]float64
+		lat, lon float64
+		want     bool
+	}{
+		{"inside", square, 5, 5, true},
+		{"outside east", square, 5, 15, false},
+		{"outside north", square, 15, 5, false},
+		{"near the edge", square, 9.999, 0.001, true},
+		{"concave inside", concave, 2, 5, true},
+		{"concave notch", concave, 8, 5, false},
+		{"concave arm", concave, 8, 1, true},
+		{"unclosed ring", unclosed, 5, 5, true},
+		{"empty ring", nil, 5, 5, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ringContains(tc.ring, tc.lat, tc.lon); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPolygonHole(t *testing.T) {
+	p := geoPolygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}},
+	}
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     bool
+	}{
+		{"in the outer ring", 2, 2, true},
+		{"in the hole", 5, 5, false},
+		{"outside", 12, 5, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := p.contains(tc.lat, tc.lon); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
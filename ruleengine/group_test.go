@@ -0,0 +1,53 @@
+package ruleengine
+
+import "testing"
+
+func TestGroupThreeValued(t *testing.T) {
+	lat, lon := 64.1466, -21.9426
+	geo := func() Rule {
+		return &GeoFilter{Type: "geo", Op: GeoRadius, Center: []float64{lat, lon}, RadiusKm: 10}
+	}
+	tag := func() Rule {
+		return &TagFilter{Type: "tag", Op: SetAny, Tags: []string{"Travel"}}
+	}
+	nested := func(op RuleGroupOp, rules ...Rule) Rule {
+		return &GroupFilter{Type: "group", Op: op, Rules: rules}
+	}
+
+	tagged := ImageFacts{Tags: []string{"Travel"}}
+	untagged := ImageFacts{}
+	located := ImageFacts{Latitude: &lat, Longitude: &lon}
+
+	tests := []struct {
+		name  string
+		group RuleGroup
+		img   ImageFacts
+		want  bool
+	}{
+		{"not unknown", RuleGroup{Op: OpNot, Rules: []Rule{geo()}}, untagged, false},
+		{"not false", RuleGroup{Op: OpNot, Rules: []Rule{geo()}}, ImageFacts{Latitude: &lon, Longitude: &lat}, true},
+		{"not true", RuleGroup{Op: OpNot, Rules: []Rule{geo()}}, located, false},
+		{"nested not unknown", RuleGroup{Op: OpAll, Rules: []Rule{nested(OpNot, geo())}}, untagged, false},
+		{"nested not unknown in any", RuleGroup{Op: OpAny, Rules: []Rule{nested(OpNot, geo()), tag()}}, tagged, true},
+		{"not of nested any, one true", RuleGroup{Op: OpNot, Rules: []Rule{nested(OpAny, geo(), tag())}}, tagged, false},
+		{"not of nested any, unknown", RuleGroup{Op: OpNot, Rules: []Rule{nested(OpAny, geo(), tag())}}, untagged, false},
+		{"all false beats unknown", RuleGroup{Op: OpNot, Rules: []Rule{nested(OpAll, geo(), tag())}}, untagged, true},
+		{"any unknown", RuleGroup{Op: OpAny, Rules: []Rule{geo()}}, untagged, false},
+		{"all unknown", RuleGroup{Op: OpAll, Rules: []Rule{tag(), geo()}}, tagged, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := CompileGroupFilter(tc.group, tc.name)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			got, trace := f(tc.img, nil)
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			if trace.Result != got {
+				t.Fatalf("trace result %v differs from %v", trace.Result, got)
+			}
+		})
+	}
+}
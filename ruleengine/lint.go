@@ -0,0 +1,189 @@
+package ruleengine
+
+import (
+	"fmt"
+	"strings"
+)
+
+type LintCode string
+
+const (
+	LintCompileError LintCode = "compile_error"
+	LintUnknownTag   LintCode = "unknown_tag"
+	LintUnknownAlbum LintCode = "unknown_album"
+	LintUnreachable  LintCode = "unreachable"
+	LintAlwaysTrue   LintCode = "always_true"
+	LintAlwaysFalse  LintCode = "always_false"
+)
+
+// LintWarning is one finding on a rule group. Path points to the rule:
+// rules[1].rules[0], empty for the group itself.
+type LintWarning struct {
+	Path    string   `json:"path"`
+	Code    LintCode `json:"code"`
+	Message string   `json:"message"`
+}
+
+func rulePath(prefix string, i int) string {
+	if prefix == "" {
+		return fmt.Sprintf("rules[%d]", i)
+	}
+	return fmt.Sprintf("%s.rules[%d]", prefix, i)
+}
+
+// LintRules checks the references of the rules: tags must exist by full path,
+// albums by ID.
+func LintRules(group RuleGroup, knownTags map[string]struct{}, knownAlbums map[uint64]string) []LintWarning {
+	var ret []LintWarning
+	lintRules(group, "", knownTags, knownAlbums, &ret)
+	return ret
+}
+
+func lintRules(group RuleGroup, prefix string, knownTags map[string]struct{}, knownAlbums map[uint64]string, ret *[]LintWarning) {
+	for i, r := range group.Rules {
+		path := rulePath(prefix, i)
+		switch f := r.(type) {
+		case *TagFilter:
+			for _, t := range f.Tags {
+				if _, ok := knownTags[t]; !ok {
+					*ret = append(*ret, LintWarning{
+						Path:    path,
+						Code:    LintUnknownTag,
+						Message: fmt.Sprintf("unknown tag: %s", t),
+					})
+				}
+			}
+		case *AlbumFilter:
+			for _, a := range f.Albums {
+				if _, ok := knownAlbums[a]; !ok {
+					*ret = append(*ret, LintWarning{
+						Path:    path,
+						Code:    LintUnknownAlbum,
+						Message: fmt.Sprintf("unknown album id: %d", a),
+					})
+				}
+			}
+		case *GroupFilter:
+			lintRules(f.Group(), path, knownTags, knownAlbums, ret)
+		}
+	}
+}
+
+// RuleStat counts the evaluations of one rule over a test run.
+type RuleStat struct {
+	Path      string `json:"path"`
+	Type      string `json:"type"`
+	Evaluated uint64 `json:"evaluated"`
+	True      uint64 `json:"true"`
+	False     uint64 `json:"false"`
+	Unknown   uint64 `json:"unknown"`
+}
+
+// RuleStats collects the per rule counts from the group traces. A rule is
+// only in the trace if the group reached it (all / any / not stop early).
+type RuleStats struct {
+	Total   uint64
+	Matched uint64
+	Rules   []*RuleStat
+	byPath  map[string]*RuleStat
+}
+
+func NewRuleStats(group RuleGroup) *RuleStats {
+	s := &RuleStats{byPath: map[string]*RuleStat{}}
+	s.register(group, "")
+	return s
+}
+
+func (s *RuleStats) register(group RuleGroup, prefix string) {
+	for i, r := range group.Rules {
+		path := rulePath(prefix, i)
+		stat := &RuleStat{Path: path, Type: r.FilterType()}
+		s.Rules = append(s.Rules, stat)
+		s.byPath[path] = stat
+		if g, ok := r.(*GroupFilter); ok {
+			s.register(g.Group(), path)
+		}
+	}
+}
+
+// Add counts the trace of one image.
+func (s *RuleStats) Add(result bool, trace GroupRuleResult) {
+	s.Total++
+	if result {
+		s.Matched++
+	}
+	s.add(trace, "")
+}
+
+func (s *RuleStats) add(trace GroupRuleResult, prefix string) {
+	for i, rr := range trace.RuleResults {
+		path := rulePath(prefix, i)
+		stat, ok := s.byPath[path]
+		if !ok {
+			continue
+		}
+		stat.Evaluated++
+		switch rr.Result {
+		case EvalResultTrue:
+			stat.True++
+		case EvalResultFalse:
+			stat.False++
+		default:
+			stat.Unknown++
+		}
+		if rr.Group != nil {
+			s.add(*rr.Group, path)
+		}
+	}
+}
+
+// Lint reports the rules never reached and the rules / groups with the same
+// result on every image. Nothing is reported without images.
+func (s *RuleStats) Lint() []LintWarning {
+	var ret []LintWarning
+	if s.Total == 0 {
+		return ret
+	}
+	switch s.Matched {
+	case 0:
+		ret = append(ret, LintWarning{Code: LintAlwaysFalse, Message: "the group matches no image"})
+	case s.Total:
+		ret = append(ret, LintWarning{Code: LintAlwaysTrue, Message: "the group matches every image"})
+	}
+	for _, stat := range s.Rules {
+		// the parent is reported already
+		if s.parentUnreachable(stat.Path) {
+			continue
+		}
+		switch {
+		case stat.Evaluated == 0:
+			ret = append(ret, LintWarning{
+				Path:    stat.Path,
+				Code:    LintUnreachable,
+				Message: fmt.Sprintf("%s rule is never evaluated", stat.Type),
+			})
+		case stat.True == stat.Evaluated:
+			ret = append(ret, LintWarning{
+				Path:    stat.Path,
+				Code:    LintAlwaysTrue,
+				Message: fmt.Sprintf("%s rule is true for every evaluated image (%d)", stat.Type, stat.Evaluated),
+			})
+		case stat.True == 0:
+			ret = append(ret, LintWarning{
+				Path:    stat.Path,
+				Code:    LintAlwaysFalse,
+				Message: fmt.Sprintf("%s rule is never true (%d evaluated, %d unknown)", stat.Type, stat.Evaluated, stat.Unknown),
+			})
+		}
+	}
+	return ret
+}
+
+func (s *RuleStats) parentUnreachable(path string) bool {
+	idx := strings.LastIndex(path, ".")
+	if idx < 0 {
+		return false
+	}
+	parent, ok := s.byPath[path[:idx]]
+	return ok && parent.Evaluated == 0
+}
@@ -0,0 +1,300 @@
+package ruleengine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ignisVeneficus/lumenta/data"
+)
+
+// compileMetaFilter compiles a comparison on one metadata alias.
+// The comparison follows the MetadataType of the value:
+//   - int / float / rational: numeric
+//   - datetime: by calendar, the value is yyyy[.mm[.dd]] like the date rule
+//   - list: matches if any element matches (ne: none of them is equal)
+//   - bool: true / false
+//   - string: case-insensitive, numeric if both sides are numbers
+//
+// Missing fields give unknown, except for exists.
+func compileMetaFilter(f *MetaFilter) (CompiledFilter, error) {
+	if f.Field == "" {
+		return nil, fmt.Errorf("meta rule: missing field")
+	}
+	values := metaRuleValues(f.Value, f.Op == MetaBetween || f.Op == MetaIn)
+
+	var re *regexp.Regexp
+	switch f.Op {
+	case MetaExists:
+	case MetaEq, MetaNe, MetaLt, MetaGt:
+		if len(values) != 1 {
+			return nil, fmt.Errorf("meta rule %s: %s needs one value", f.Field, f.Op)
+		}
+	case MetaBetween:
+		if len(values) != 2 {
+			return nil, fmt.Errorf("meta rule %s: between needs two values", f.Field)
+		}
+	case MetaIn:
+		if len(values) == 0 {
+			return nil, fmt.Errorf("meta rule %s: in needs values", f.Field)
+		}
+	case MetaRegex:
+		if len(values) != 1 {
+			return nil, fmt.Errorf("meta rule %s: regex needs one pattern", f.Field)
+		}
+		var err error
+		re, err = regexp.Compile(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("meta rule %s: %w", f.Field, err)
+		}
+	default:
+		return nil, fmt.Errorf("meta rule %s: unknown op %q", f.Field, f.Op)
+	}
+
+	name := fmt.Sprintf("meta:%s:%s:%s", f.Field, f.Op, strings.Join(values, ","))
+	base := RuleResult{
+		Name: name,
+		Op:   string(f.Op),
+		Type: "meta",
+		Params: []RuleParam{
+			CreateRuleParamString("field", f.Field),
+			CreateRuleParamStrings("value", values),
+		},
+	}
+
+	return func(img ImageFacts, ruleContext *RuleContext) (TriState, RuleResult) {
+		rr := base
+		mv, ok := img.Metadata[f.Field]
+		if !ok {
+			rr.Actual = append(rr.Actual, CreateRuleParamEmpty("value"))
+			if f.Op == MetaExists {
+				return returnBool(rr, false)
+			}
+			return returnValue(rr, EvalResultUnknow)
+		}
+		rr.Actual = append(rr.Actual, CreateRuleParamStrings("value", metaDisplay(mv)))
+		if f.Op == MetaExists {
+			return returnBool(rr, true)
+		}
+		return returnValue(rr, matchMeta(mv, f.Op, values, re))
+	}, nil
+}
+
+// metaRuleValues normalizes the rule value to strings.
+// A single string is split on "," for list operators (rule editor input).
+func metaRuleValues(v any, list bool) []string {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		ret := make([]string, 0, len(x))
+		for _, e := range x {
+			ret = append(ret, metaRuleValues(e, false)...)
+		}
+		return ret
+	case []string:
+		return x
+	case string:
+		if !list {
+			return []string{x}
+		}
+		var ret []string
+		for _, p := range strings.Split(x, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				ret = append(ret, p)
+			}
+		}
+		return ret
+	case float64:
+		return []string{strconv.FormatFloat(x, 'f', -1, 64)}
+	default:
+		return []string{fmt.Sprint(x)}
+	}
+}
+
+func metaDisplay(mv data.MetadataValue) []string {
+	switch v := mv.Value.(type) {
+	case time.Time:
+		return []string{v.Format("2006.01.02 15:04:05")}
+	}
+	if list, ok := mv.AsList(); ok {
+		return list
+	}
+	return []string{fmt.Sprint(mv.Value)}
+}
+
+// metaCompare compares the actual value to one rule value: -1, 0, 1.
+// false if they are not comparable.
+type metaCompare func(want string) (int, bool)
+
+func matchMeta(mv data.MetadataValue, op MetaOp, values []string, re *regexp.Regexp) TriState {
+	switch mv.Type {
+	case data.MetaInt, data.MetaFloat, data.MetaRational:
+		n, ok := mv.AsNumber()
+		if !ok {
+			return EvalResultUnknow
+		}
+		return evalMetaOp(op, values, re, strconv.FormatFloat(n, 'f', -1, 64), numberCompare(n))
+
+	case data.MetaDateTime:
+		t, ok := mv.Value.(time.Time)
+		if !ok {
+			return EvalResultUnknow
+		}
+		return evalMetaOp(op, values, re, t.Format("2006.01.02 15:04:05"), dateCompare(wallClock(t)))
+
+	case data.MetaBool:
+		b, ok := metaBool(mv.Value)
+		if !ok {
+			return EvalResultUnknow
+		}
+		return evalMetaOp(op, values, re, strconv.FormatBool(b), boolCompare(b))
+
+	case data.MetaList:
+		list, ok := mv.AsList()
+		if !ok {
+			return EvalResultUnknow
+		}
+		if op == MetaNe {
+			for _, e := range list {
+				if evalMetaOp(MetaEq, values, re, e, stringCompare(e)) == EvalResultTrue {
+					return EvalResultFalse
+				}
+			}
+			return EvalResultTrue
+		}
+		for _, e := range list {
+			if evalMetaOp(op, values, re, e, stringCompare(e)) == EvalResultTrue {
+				return EvalResultTrue
+			}
+		}
+		return EvalResultFalse
+
+	default:
+		s := fmt.Sprint(mv.Value)
+		return evalMetaOp(op, values, re, s, stringCompare(s))
+	}
+}
+
+func evalMetaOp(op MetaOp, values []string, re *regexp.Regexp, text string, cmp metaCompare) TriState {
+	if op == MetaRegex {
+		return boolState(re.MatchString(text))
+	}
+	if op == MetaIn {
+		for _, v := range values {
+			c, ok := cmp(v)
+			if !ok {
+				return EvalResultUnknow
+			}
+			if c == 0 {
+				return EvalResultTrue
+			}
+		}
+		return EvalResultFalse
+	}
+	c, ok := cmp(values[0])
+	if !ok {
+		return EvalResultUnknow
+	}
+	switch op {
+	case MetaEq:
+		return boolState(c == 0)
+	case MetaNe:
+		return boolState(c != 0)
+	case MetaLt:
+		return boolState(c < 0)
+	case MetaGt:
+		return boolState(c > 0)
+	case MetaBetween:
+		upper, ok := cmp(values[1])
+		if !ok {
+			return EvalResultUnknow
+		}
+		return boolState(c >= 0 && upper <= 0)
+	}
+	return EvalResultFalse
+}
+
+func boolState(b bool) TriState {
+	if b {
+		return EvalResultTrue
+	}
+	return EvalResultFalse
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func numberCompare(n float64) metaCompare {
+	return func(want string) (int, bool) {
+		w, err := data.ParseRational(want)
+		if err != nil {
+			return 0, false
+		}
+		return compareFloat(n, w), true
+	}
+}
+
+// dateCompare compares to the period of yyyy[.mm[.dd]], inside the period is equal.
+func dateCompare(t time.Time) metaCompare {
+	return func(want string) (int, bool) {
+		start, end, err := parseDateRange(want)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case t.Before(start):
+			return -1, true
+		case !t.Before(end):
+			return 1, true
+		}
+		return 0, true
+	}
+}
+
+func metaBool(v any) (bool, bool) {
+	switch x := v.(type) {
+	case bool:
+		return x, true
+	case string:
+		b, err := strconv.ParseBool(x)
+		return b, err == nil
+	}
+	return false, false
+}
+
+func boolCompare(b bool) metaCompare {
+	return func(want string) (int, bool) {
+		w, err := strconv.ParseBool(want)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case b == w:
+			return 0, true
+		case w:
+			return -1, true
+		}
+		return 1, true
+	}
+}
+
+func stringCompare(s string) metaCompare {
+	return func(want string) (int, bool) {
+		if n, err := data.ParseRational(s); err == nil {
+			if w, err := data.ParseRational(want); err == nil {
+				return compareFloat(n, w), true
+			}
+		}
+		return strings.Compare(strings.ToLower(s), strings.ToLower(want)), true
+	}
+}
@@ -24,9 +24,11 @@ const (
 	EvaluationPanorama   RuleEvaluation = "panorama"
 	EvaluationACL        RuleEvaluation = "acl"
 	EvaluationAlbum      RuleEvaluation = "album"
+	EvaluationOverride   RuleEvaluation = "override"
 )
 
 var AllRuleEvaluation = []RuleEvaluation{
+	EvaluationOverride,
 	EvaluationFilesystem,
 	EvaluationACL,
 	EvaluationPanorama,
@@ -48,6 +50,8 @@ type RuleResult struct {
 	Params []RuleParam `json:"rule-params"`
 	Actual []RuleParam `json:"actual-values"`
 	Result TriState    `json:"result"`
+	// nested group trace, only for "group" rules
+	Group *GroupRuleResult `json:"group,omitempty"`
 }
 
 func CreateRuleParamString(name, value string) RuleParam {
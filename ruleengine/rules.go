@@ -13,10 +13,11 @@ type RuleGroupOp string
 const (
 	OpAll RuleGroupOp = "all" // AND
 	OpAny RuleGroupOp = "any" // OR
+	OpNot RuleGroupOp = "not" // NOR, none of the rules match
 )
 
 type RuleGroup struct {
-	Op    RuleGroupOp // all | any
+	Op    RuleGroupOp // all | any | not
 	Rules []Rule
 }
 
@@ -36,45 +37,85 @@ var filterRegistry = map[string]func() Rule{
 	"width":       func() Rule { return &WidthFilter{} },
 	"height":      func() Rule { return &HeightFilter{} },
 	"aspect":      func() Rule { return &AspectFilter{} },
+	"group":       func() Rule { return &GroupFilter{} },
+	"meta":        func() Rule { return &MetaFilter{} },
+	"geo":         func() Rule { return &GeoFilter{} },
+	"expr":        func() Rule { return &ExpressionFilter{} },
 }
 
-func (g *RuleGroup) UnmarshalYAML(value *yaml.Node) error {
-	log.Logger.Warn().Int("Line", value.Line).Msg("RuleGroup UnmarshalYAML called")
-	var raw struct {
-		Op    RuleGroupOp      `yaml:"op"`
-		Rules []map[string]any `yaml:"rules"`
-	}
+// CompilableRule is a rule type that compiles itself.
+// Rule types added by RegisterFilter must implement it.
+type CompilableRule interface {
+	Rule
+	Compile() (CompiledFilter, error)
+}
 
-	if err := value.Decode(&raw); err != nil {
-		return err
+// RegisterFilter adds a rule type to the decoder, ctor must return a pointer to
+// a CompilableRule decodable from JSON. The registry is not locked: register
+// before the first rule is decoded. A used or invalid type panics.
+func RegisterFilter(name string, ctor func() Rule) {
+	if name == "" {
+		panic("ruleengine: empty filter type")
 	}
+	if _, ok := filterRegistry[name]; ok {
+		panic(fmt.Sprintf("ruleengine: filter type %q already registered", name))
+	}
+	if _, ok := ctor().(CompilableRule); !ok {
+		panic(fmt.Sprintf("ruleengine: filter type %q does not implement CompilableRule", name))
+	}
+	filterRegistry[name] = ctor
+}
 
-	g.Op = raw.Op
-
-	for i, rule := range raw.Rules {
+// decodeRules builds the typed rules from their generic map form.
+// Nested groups are decoded recursively through GroupFilter.UnmarshalJSON.
+func decodeRules(raw []map[string]any) ([]Rule, error) {
+	var ret []Rule
+	for i, rule := range raw {
 		t, ok := rule["type"].(string)
 		if !ok || t == "" {
-			return fmt.Errorf("rules[%d]: missing type", i)
+			return nil, fmt.Errorf("rules[%d]: missing type", i)
 		}
 
 		ctor, ok := filterRegistry[t]
 		if !ok {
-			return fmt.Errorf("rules[%d]: unknown filter type %q", i, t)
+			return nil, fmt.Errorf("rules[%d]: unknown filter type %q", i, t)
 		}
 
 		f := ctor()
 
-		// YAML → map → JSON → struct
+		// map → JSON → struct
 		b, err := json.Marshal(rule)
 		if err != nil {
-			return fmt.Errorf("rules[%d]: %w", i, err)
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
 		}
 		if err := json.Unmarshal(b, f); err != nil {
-			return fmt.Errorf("rules[%d]: %w", i, err)
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
 		}
 
-		g.Rules = append(g.Rules, f)
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+func (g *RuleGroup) UnmarshalYAML(value *yaml.Node) error {
+	log.Logger.Warn().Int("Line", value.Line).Msg("RuleGroup UnmarshalYAML called")
+	var raw struct {
+		Op    RuleGroupOp      `yaml:"op"`
+		Rules []map[string]any `yaml:"rules"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	g.Op = raw.Op
+
+	// YAML → map → JSON → struct
+	rules, err := decodeRules(raw.Rules)
+	if err != nil {
+		return err
 	}
+	g.Rules = rules
 
 	return nil
 }
@@ -91,25 +132,11 @@ func (g *RuleGroup) UnmarshalJSON(data []byte) error {
 
 	g.Op = raw.Op
 
-	for i, rule := range raw.Rules {
-		t, ok := rule["type"].(string)
-		if !ok || t == "" {
-			return fmt.Errorf("rules[%d]: missing type", i)
-		}
-
-		ctor, ok := filterRegistry[t]
-		if !ok {
-			return fmt.Errorf("rules[%d]: unknown filter type %q", i, t)
-		}
-
-		f := ctor()
-		b, _ := json.Marshal(rule)
-		if err := json.Unmarshal(b, f); err != nil {
-			return fmt.Errorf("rules[%d]: %w", i, err)
-		}
-
-		g.Rules = append(g.Rules, f)
+	rules, err := decodeRules(raw.Rules)
+	if err != nil {
+		return err
 	}
+	g.Rules = rules
 
 	return nil
 }
@@ -128,6 +155,84 @@ func (g RuleGroup) MarshalJSON() ([]byte, error) {
 	return json.Marshal(out)
 }
 
+// DependsOnTime reports whether the result changes with the evaluation time
+// (relative date rules), without any change of the image.
+func (g RuleGroup) DependsOnTime() bool {
+	return g.any(func(r Rule) bool {
+		f, ok := r.(*DateFilter)
+		return ok && f.IsRelative()
+	})
+}
+
+// DependsOnAlbums reports whether the result depends on the album membership.
+func (g RuleGroup) DependsOnAlbums() bool {
+	return g.any(func(r Rule) bool {
+		switch r.(type) {
+		case *AlbumFilter, *NotInChildAlbumsFilter:
+			return true
+		}
+		return false
+	})
+}
+
+// any reports whether a rule of the group or of its nested groups satisfies fn.
+func (g RuleGroup) any(fn func(Rule) bool) bool {
+	for _, r := range g.Rules {
+		if n, ok := r.(*GroupFilter); ok {
+			if n.Group().any(fn) {
+				return true
+			}
+			continue
+		}
+		if fn(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupFilter is a rule group used as a rule, so groups can be nested:
+// (tag Family AND rating>3) OR (tag Travel AND date after 2020)
+type GroupFilter struct {
+	Type  string      `json:"type" yaml:"type"` // "group"
+	Op    RuleGroupOp `json:"op" yaml:"op"`
+	Rules []Rule      `json:"rules" yaml:"rules"`
+}
+
+func (GroupFilter) FilterType() string { return "group" }
+
+// Group returns the nested rule group.
+func (f *GroupFilter) Group() RuleGroup {
+	return RuleGroup{Op: f.Op, Rules: f.Rules}
+}
+
+func (f *GroupFilter) UnmarshalJSON(data []byte) error {
+	var g RuleGroup
+	if err := json.Unmarshal(data, &g); err != nil {
+		return err
+	}
+	f.Type = f.FilterType()
+	f.Op = g.Op
+	f.Rules = g.Rules
+	return nil
+}
+
+func (f GroupFilter) MarshalJSON() ([]byte, error) {
+	type alias struct {
+		Type  string      `json:"type"`
+		Op    RuleGroupOp `json:"op"`
+		Rules []any       `json:"rules"`
+	}
+	out := alias{
+		Type: f.FilterType(),
+		Op:   f.Op,
+	}
+	for _, r := range f.Rules {
+		out.Rules = append(out.Rules, r)
+	}
+	return json.Marshal(out)
+}
+
 type SetOp string
 
 const (
@@ -140,9 +245,19 @@ const (
 type DateOp string
 
 const (
-	DateOn     DateOp = "on"
-	DateBefore DateOp = "before"
-	DateAfter  DateOp = "after"
+	DateOn      DateOp = "on"
+	DateBefore  DateOp = "before"
+	DateAfter   DateOp = "after"
+	DateBetween DateOp = "between" // date .. to, inclusive
+
+	// relative to the evaluation time
+	DateWithinLast  DateOp = "within_last" // value: 12h, 30d, 2w, 6m, 1y
+	DateAnniversary DateOp = "anniversary" // this day in past years
+
+	// recurring, on the local capture time
+	DateMonthDay DateOp = "monthday" // value: mm[.dd][-mm[.dd]], e.g. 12.24-12.26
+	DateWeekday  DateOp = "weekday"  // value: sat,sun or 0-6 (sunday = 0)
+	DateHour     DateOp = "hour"     // value: 22-5, start inclusive, end exclusive
 )
 
 type RelationOp string
@@ -161,9 +276,16 @@ type TagFilter struct {
 func (TagFilter) FilterType() string { return "tag" }
 
 type DateFilter struct {
-	Type string `json:"type" yaml:"type"` // "date"
-	Op   DateOp `json:"op" yaml:"op"`
-	Date string `json:"date" yaml:"date"` // yyyy[.mm[.dd]]
+	Type  string `json:"type" yaml:"type"` // "date"
+	Op    DateOp `json:"op" yaml:"op"`
+	Date  string `json:"date,omitempty" yaml:"date,omitempty"`   // yyyy[.mm[.dd]]
+	To    string `json:"to,omitempty" yaml:"to,omitempty"`       // yyyy[.mm[.dd]], between only
+	Value string `json:"value,omitempty" yaml:"value,omitempty"` // relative and recurring ops
+}
+
+// IsRelative reports whether the result depends on the evaluation time.
+func (f DateFilter) IsRelative() bool {
+	return f.Op == DateWithinLast || f.Op == DateAnniversary
 }
 
 func (DateFilter) FilterType() string { return "date" }
@@ -207,6 +329,57 @@ type AspectFilter struct {
 
 func (AspectFilter) FilterType() string { return "aspect" }
 
+type MetaOp string
+
+const (
+	MetaEq      MetaOp = "eq"
+	MetaNe      MetaOp = "ne"
+	MetaLt      MetaOp = "lt"
+	MetaGt      MetaOp = "gt"
+	MetaBetween MetaOp = "between" // inclusive, value: [min, max]
+	MetaRegex   MetaOp = "regex"
+	MetaExists  MetaOp = "exists" // no value
+	MetaIn      MetaOp = "in"     // value: list
+)
+
+// MetaFilter compares a resolved metadata alias (camera, lens, iso, custom fields...)
+type MetaFilter struct {
+	Type  string `json:"type" yaml:"type"` // "meta"
+	Field string `json:"field" yaml:"field"`
+	Op    MetaOp `json:"op" yaml:"op"`
+	Value any    `json:"value,omitempty" yaml:"value,omitempty"` // scalar, or list for between / in
+}
+
+func (MetaFilter) FilterType() string { return "meta" }
+
+type GeoOp string
+
+const (
+	GeoBBox    GeoOp = "bbox"
+	GeoRadius  GeoOp = "radius"
+	GeoPolygon GeoOp = "polygon"
+)
+
+// GeoFilter matches the GPS position of the image against an area.
+type GeoFilter struct {
+	Type     string    `json:"type" yaml:"type"` // "geo"
+	Op       GeoOp     `json:"op" yaml:"op"`
+	BBox     []float64 `json:"bbox,omitempty" yaml:"bbox,omitempty"`     // south, west, north, east
+	Center   []float64 `json:"center,omitempty" yaml:"center,omitempty"` // lat, lon
+	RadiusKm float64   `json:"radius_km,omitempty" yaml:"radius_km,omitempty"`
+	Polygon  string    `json:"polygon,omitempty" yaml:"polygon,omitempty"` // GeoJSON file path
+}
+
+func (GeoFilter) FilterType() string { return "geo" }
+
+// ExpressionFilter is a condition written in the expression language, see expr.go.
+type ExpressionFilter struct {
+	Type string `json:"type" yaml:"type"` // "expr"
+	Expr string `json:"expr" yaml:"expr"`
+}
+
+func (ExpressionFilter) FilterType() string { return "expr" }
+
 type PathFilter struct {
 	Type  string   `json:"type" yaml:"type"` // "path"
 	Op    SetOp    `json:"op" yaml:"op"`
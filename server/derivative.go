@@ -1,9 +1,12 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ignisVeneficus/logging"
@@ -12,6 +15,7 @@ import (
 	"github.com/ignisVeneficus/lumenta/config"
 	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
 	"github.com/ignisVeneficus/lumenta/derivative"
+	"github.com/ignisVeneficus/lumenta/server/routes"
 	"github.com/ignisVeneficus/lumenta/utils"
 )
 
@@ -35,7 +39,7 @@ func DerivativeHandler(cfg config.Config) gin.HandlerFunc {
 			return
 		}
 
-		derivativesCfx := cfg.Derivatives
+		derivativesCfx := cfg.Derivatives.Sizes
 		var found *derivativeConfig.DerivativeConfig
 		for _, d := range derivativesCfx {
 			if d.Postfix == kind {
@@ -50,13 +54,26 @@ func DerivativeHandler(cfg config.Config) gin.HandlerFunc {
 			})
 			return
 		}
-		path, err := derivative.GetDerivativesPathWithACL(ctx, auth, imgID, *found, cfg.Filesystem)
+		formats := acceptedFormats(*found, c.GetHeader("Accept"), c.Query(routes.FormatParam))
+		file, err := derivative.GetDerivativesPathWithACL(ctx, auth, imgID, *found, cfg.Filesystem, formats)
 		if err != nil {
 			logging.ExitErr(logg, err)
-			c.AbortWithStatus(http.StatusNotFound)
+			abortDerivativeError(c, err, http.StatusNotFound)
 			return
 		}
-		_, err = os.Stat(path)
+		if len(found.Formats) > 1 {
+			c.Header("Vary", "Accept")
+		}
+		_, err = os.Stat(file.Path)
+		if os.IsNotExist(err) && file.Key != "" {
+			res, finished := derivative.Get().Wait(ctx, file.Key, cfg.Derivatives.WaitTimeout)
+			if finished && res.Err != nil {
+				logging.ExitErr(logg, res.Err)
+				abortDerivativeError(c, res.Err, http.StatusInternalServerError)
+				return
+			}
+			_, err = os.Stat(file.Path)
+		}
 		if os.IsNotExist(err) {
 			logging.Exit(logg, "generating", nil)
 			c.Header("Retry-After", "1")
@@ -65,15 +82,90 @@ func DerivativeHandler(cfg config.Config) gin.HandlerFunc {
 		}
 		if err == nil {
 			logging.Exit(logg, "ok", nil)
-			c.Header("Content-Type", "image/jpeg")
-			c.Header("Cache-Control", "public, max-age=31536000")
-			c.File(path)
+			c.Header("Content-Type", derivativeConfig.FormatMimeType(file.Format))
+			// the versioned URL never changes its content, the plain one is revalidated;
+			// the variants of the roles share the URL, they are revalidated by the fingerprint
+			c.Header("ETag", `"`+file.Version+"-"+file.Fingerprint+"-"+file.Format+`"`)
+			switch {
+			case found.RoleDependent():
+				c.Header("Cache-Control", "private, no-cache")
+			case c.Query(routes.VersionParam) == file.Version:
+				c.Header("Cache-Control", "public, max-age=31536000, immutable")
+			default:
+				c.Header("Cache-Control", "public, no-cache")
+			}
+			c.File(file.Path)
 			return
 		}
 		logging.ExitErr(logg, err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 	}
 }
+
+// derivativeErrorStatus maps a failed lookup or generation to the response,
+// fallback: the status of the other errors.
+func derivativeErrorStatus(err error, fallback int) (int, string) {
+	switch {
+	case errors.Is(err, derivative.ErrDecodeFailed):
+		return http.StatusUnprocessableEntity, "image cannot be decoded"
+	case errors.Is(err, derivative.ErrGenerateFailed):
+		return http.StatusInternalServerError, "derivative generation failed"
+	case errors.Is(err, derivative.ErrQueueFull):
+		return http.StatusServiceUnavailable, ""
+	}
+	return fallback, ""
+}
+
+func abortDerivativeError(c *gin.Context, err error, fallback int) {
+	status, msg := derivativeErrorStatus(err, fallback)
+	if status == http.StatusServiceUnavailable {
+		c.Header("Retry-After", "5")
+	}
+	if msg == "" {
+		c.AbortWithStatus(status)
+		return
+	}
+	c.AbortWithStatusJSON(status, gin.H{
+		"error": msg,
+	})
+}
+
+// acceptedFormats returns the formats of the derivative accepted by the client,
+// in the order of the configuration; jpeg is the last, served to everyone.
+// forced: the format requested in the URL (<picture> sources).
+func acceptedFormats(d derivativeConfig.DerivativeConfig, accept string, forced string) []string {
+	if forced != "" && d.HasFormat(forced) {
+		return []string{forced}
+	}
+	ret := []string{}
+	for _, f := range d.Formats {
+		if f == derivativeConfig.FormatJPEG || acceptsMime(accept, derivativeConfig.FormatMimeType(f)) {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// acceptsMime: an explicit, not refused media type of the Accept header,
+// */* is not enough, browsers send it for every image request.
+func acceptsMime(accept string, mime string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), mime) {
+			continue
+		}
+		for _, p := range params[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
 func DefaultHTMLMime() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ignisVeneficus/lumenta/derivative"
+)
+
+func TestAcceptsMime(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		mime   string
+		want   bool
+	}{
+		{"browser image request", "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8", "image/webp", true},
+		{"wildcard only", "image/*,*/*;q=0.8", "image/webp", false},
+		{"empty", "", "image/avif", false},
+		{"case and spaces", " Image/AVIF ; q=0.9", "image/avif", true},
+		{"refused", "image/webp;q=0,image/*", "image/webp", false},
+		{"refused with decimals", "image/webp;q=0.000", "image/webp", false},
+		{"low but accepted", "image/webp;q=0.1", "image/webp", true},
+		{"other parameter", "image/webp;level=1", "image/webp", true},
+		{"prefix is not enough", "image/webp2", "image/webp", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := acceptsMime(tc.accept, tc.mime); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDerivativeErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"decode", fmt.Errorf("%w: bad file", derivative.ErrDecodeFailed), http.StatusUnprocessableEntity},
+		{"generate", fmt.Errorf("%w: disk full", derivative.ErrGenerateFailed), http.StatusInternalServerError},
+		{"queue full", derivative.ErrQueueFull, http.StatusServiceUnavailable},
+		{"other", errors.New("not found"), http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := derivativeErrorStatus(tc.err, http.StatusNotFound); got != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
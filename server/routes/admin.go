@@ -47,6 +47,9 @@ func GetAdminImgPath() string {
 func CreateAdminImgPath(imageID ImageID) string {
 	return AdminPrefix + fmt.Sprintf(adminImgPath, imageID)
 }
+func BuildAdminImgPath(imageID ImageID) *URLBuilder {
+	return NewURL(CreateAdminImgPath(imageID))
+}
 
 func GetAdminAlbumsPath() string {
 	return adminAlbumListPath
@@ -11,6 +11,12 @@ const (
 	apiAdminAlbumPath  = "/albums/%d"
 	apiAdminImagesPath = "/images"
 	apiAdminImagePath  = "/images/%d"
+	apiAdminRulesTest  = "/rules/test"
+
+	apiAdminDerivativesStatusPath = "/derivatives/status"
+
+	apiAdminImageExplainPath    = "/images/%d/explain"
+	apiAdminImageExplainACLPath = "/images/%d/explain/acl"
 )
 
 func GetApiAdminTagsPath() string {
@@ -55,3 +61,29 @@ func GetApiAdminImagePath() string {
 func CreateApiAdminImagePath(imageID ImageID) string {
 	return ApiPrefix + AdminPrefix + fmt.Sprintf(apiAdminImagePath, imageID)
 }
+func GetApiAdminRulesTestPath() string {
+	return apiAdminRulesTest
+}
+func CreateApiAdminRulesTestPath() string {
+	return ApiPrefix + AdminPrefix + apiAdminRulesTest
+}
+
+func GetApiAdminDerivativesStatusPath() string {
+	return apiAdminDerivativesStatusPath
+}
+func CreateApiAdminDerivativesStatusPath() string {
+	return ApiPrefix + AdminPrefix + apiAdminDerivativesStatusPath
+}
+
+func GetApiAdminImageExplainPath() string {
+	return getPath(apiAdminImageExplainPath, ":id")
+}
+func CreateApiAdminImageExplainPath(imageID ImageID) string {
+	return ApiPrefix + AdminPrefix + fmt.Sprintf(apiAdminImageExplainPath, imageID)
+}
+func GetApiAdminImageExplainACLPath() string {
+	return getPath(apiAdminImageExplainACLPath, ":id")
+}
+func CreateApiAdminImageExplainACLPath(imageID ImageID) string {
+	return ApiPrefix + AdminPrefix + fmt.Sprintf(apiAdminImageExplainACLPath, imageID)
+}
@@ -13,6 +13,7 @@ const (
 	tagPath        = "/tag/%d"
 	tagImagePath   = "/tag/%d/img/%d"
 	imagePath      = "/img/%d"
+	videoPath      = "/video/%d"
 )
 
 func GetAlbumImagePath() string {
@@ -32,6 +33,23 @@ func CreateDerivativePath(id ImageID, derivative string) string {
 	return fmt.Sprintf(derivativePath, id, derivative)
 }
 
+// CreateDerivativeVersionPath adds the cache-busting version, if known.
+func CreateDerivativeVersionPath(id ImageID, derivative, version string) string {
+	if version == "" {
+		return CreateDerivativePath(id, derivative)
+	}
+	return NewURL(CreateDerivativePath(id, derivative)).WithParam(VersionParam, version).String()
+}
+
+// CreateDerivativeFormatPath forces the format instead of the Accept header.
+func CreateDerivativeFormatPath(id ImageID, derivative, version, format string) string {
+	u := NewURL(CreateDerivativePath(id, derivative)).WithParam(FormatParam, format)
+	if version != "" {
+		u.WithParam(VersionParam, version)
+	}
+	return u.String()
+}
+
 func GetAlbumsRootPath() string {
 	return albumsRootPath
 }
@@ -85,3 +103,10 @@ func GetImagePath() string {
 func CreateImagePath(id ImageID) string {
 	return fmt.Sprintf(imagePath, id)
 }
+
+func GetVideoPath() string {
+	return getPath(videoPath, ":id")
+}
+func CreateVideoPath(id ImageID) string {
+	return fmt.Sprintf(videoPath, id)
+}
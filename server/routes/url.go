@@ -11,6 +11,10 @@ var (
 	SyncPageParam   = "sPage"
 	SearchParam     = "q"
 	FilterParam     = "f"
+	ExplainParam    = "explain"
+	AlbumParam      = "album"
+	VersionParam    = "v"
+	FormatParam     = "format"
 )
 
 type URLBuilder struct {
@@ -76,6 +76,7 @@ func Server(cfg config.Config, i18n *i18n.Service, ctx context.Context) {
 
 		/// "/img/:id/:type"
 		publicGrp.GET(routes.GetImageDerivativePath(), DerivativeHandler(cfg))
+		publicGrp.GET(routes.GetVideoPath(), VideoHandler(cfg))
 	}
 	adminGrp := r.Group("/admin")
 	adminGrp.Use(RequireRole(dbo.RoleAdmin), DefaultHTMLMime())
@@ -128,6 +129,12 @@ func Server(cfg config.Config, i18n *i18n.Service, ctx context.Context) {
 		apiAdminGrp.PATCH(routes.GetApiAdminAlbumPath(), endpoint.AlbumPatch(cfg))
 
 		apiAdminGrp.PATCH(routes.GetApiAdminImagePath(), endpoint.ImagePatch(cfg))
+		apiAdminGrp.GET(routes.GetApiAdminImageExplainPath(), endpoint.ImageExplain(cfg))
+		apiAdminGrp.GET(routes.GetApiAdminImageExplainACLPath(), endpoint.ImageExplainACL(cfg))
+		// rules
+		apiAdminGrp.POST(routes.GetApiAdminRulesTestPath(), endpoint.RulesTest(cfg))
+		// derivatives
+		apiAdminGrp.GET(routes.GetApiAdminDerivativesStatusPath(), endpoint.DerivativesStatus(cfg))
 
 	}
 
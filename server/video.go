@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ignisVeneficus/logging"
+	"github.com/ignisVeneficus/lumenta/auth"
+	authData "github.com/ignisVeneficus/lumenta/auth/data"
+	"github.com/ignisVeneficus/lumenta/config"
+	"github.com/ignisVeneficus/lumenta/db"
+	"github.com/ignisVeneficus/lumenta/db/dao"
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/derivative"
+	"github.com/ignisVeneficus/lumenta/utils"
+)
+
+// VideoHandler streams the original file of a video, range requests are
+// served by http.ServeContent. The guests get a copy without metadata, the
+// original may contain the location.
+func VideoHandler(cfg config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := c.MustGet(auth.AuthContextKey).(authData.ACLContext)
+		imageIdStr := c.Param("id")
+
+		logg, ctx := logging.Enter(c.Request.Context(), "server/videoHandler", imageIdStr, map[string]any{
+			"id": imageIdStr,
+		})
+
+		imgID, err := utils.ParseUint(imageIdStr)
+		if err != nil || imgID == 0 {
+			logging.ExitErr(logg, err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "invalid image id",
+			})
+			return
+		}
+		image, err := dao.GetImageByIdACL(db.GetDatabase(), ctx, dbo.ImageID(imgID), auth.ACLContext)
+		if err != nil {
+			logging.ExitErr(logg, err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if !image.IsVideo() {
+			logging.ExitErr(logg, fmt.Errorf("not a video"))
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		imgRoot, ok := cfg.Filesystem.Originals[image.Root]
+		if !ok {
+			logging.ExitErr(logg, fmt.Errorf("root not defined: %s", image.Root))
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		path := utils.ConcatGlobalPath(imgRoot.Root, image.Path, image.Filename, image.Ext)
+		if _, err := os.Stat(path); err != nil {
+			logging.ExitErr(logg, err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if auth.Role == dbo.RoleGuest {
+			path, err = derivative.GuestVideo(ctx, image, path, cfg.Filesystem)
+			if err != nil {
+				logging.ExitErr(logg, err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+		logging.Exit(logg, "ok", nil)
+		c.Header("Content-Type", utils.VideoMimeType(image.Ext))
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.File(path)
+	}
+}
@@ -0,0 +1,105 @@
+package timezone
+
+import (
+	"bufio"
+	_ "embed"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/ignisVeneficus/lumenta/geocode"
+)
+
+//go:embed zones.tsv
+var zonesTSV string
+
+// maxDistanceKm is the search radius around the representative points,
+// outside of it (open sea) there is no zone.
+const maxDistanceKm = 1500
+
+// gazetteerDistanceKm is the search radius of the gazetteer places, their
+// timezone is exact (GeoNames), the table is used outside of it.
+const gazetteerDistanceKm = 200
+
+type zonePoint struct {
+	name string
+	lat  float64
+	lon  float64
+}
+
+var (
+	loadOnce sync.Once
+	points   []zonePoint
+	zones    map[string]*time.Location
+
+	placeZones sync.Map // name -> *time.Location, the zones of the gazetteer
+)
+
+func load() {
+	zones = map[string]*time.Location{}
+	scanner := bufio.NewScanner(strings.NewReader(zonesTSV))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		if len(cols) != 3 {
+			continue
+		}
+		lat, err1 := strconv.ParseFloat(cols[1], 64)
+		lon, err2 := strconv.ParseFloat(cols[2], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if _, ok := zones[cols[0]]; !ok {
+			loc, err := time.LoadLocation(cols[0])
+			if err != nil {
+				continue
+			}
+			zones[cols[0]] = loc
+		}
+		points = append(points, zonePoint{name: cols[0], lat: lat, lon: lon})
+	}
+}
+
+// Lookup returns the timezone of a WGS84 coordinate: the timezone of the
+// nearest gazetteer place if one is loaded, otherwise the embedded table.
+// The table holds representative points, the nearest one wins, it is
+// approximate near the borders. nil if nothing is near (open sea).
+func Lookup(lat, lon float64, gazetteer *geocode.Gazetteer) *time.Location {
+	if place, _, ok := gazetteer.Lookup(lat, lon, gazetteerDistanceKm); ok && place.Timezone != "" {
+		if loc := placeZone(place.Timezone); loc != nil {
+			return loc
+		}
+	}
+	loadOnce.Do(load)
+	best := -1
+	bestDist := float64(maxDistanceKm)
+	for i, p := range points {
+		d := geocode.Distance(lat, lon, p.lat, p.lon)
+		if d < bestDist {
+			best = i
+			bestDist = d
+		}
+	}
+	if best >= 0 {
+		return zones[points[best].name]
+	}
+	return nil
+}
+
+// placeZone loads the zone of a gazetteer place once, nil if it is unknown.
+func placeZone(name string) *time.Location {
+	if loc, ok := placeZones.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = nil
+	}
+	placeZones.Store(name, loc)
+	return loc
+}
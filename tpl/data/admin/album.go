@@ -23,6 +23,10 @@ type AlbumForm struct {
 	ACLLevel    string       `form:"acl_level"`
 	ACLUserID   string       `form:"acl_user"`
 	Rank        string       `form:"rank"`
+	Sort        string       `form:"sort_order"`
+	Limit       string       `form:"image_limit"`
+	GroupBy     string       `form:"group_by"`
+	GroupParam  string       `form:"group_param"`
 
 	Errors validate.ValidationErrors `form:"-"`
 }
@@ -39,7 +43,11 @@ func (a *AlbumForm) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolo
 			Str(string(definitions.AlbumFieldRuleJSON), a.RuleJSON).
 			Str(string(definitions.AlbumFieldACLLevel), a.ACLLevel).
 			Str(string(definitions.AlbumFieldACLUserID), a.ACLUserID).
-			Str(string(definitions.AlbumFieldRank), a.Rank)
+			Str(string(definitions.AlbumFieldRank), a.Rank).
+			Str(string(definitions.AlbumFieldSort), a.Sort).
+			Str(string(definitions.AlbumFieldLimit), a.Limit).
+			Str(string(definitions.AlbumFieldGroupBy), a.GroupBy).
+			Str(string(definitions.AlbumFieldGroupParam), a.GroupParam)
 		errors := zerolog.Dict()
 		for k, v := range a.Errors {
 			errors.Strs(string(k), v)
@@ -52,10 +60,14 @@ func (a *AlbumForm) MarshalZerologObjectWithLevel(e *zerolog.Event, level zerolo
 type AlbumContext struct {
 	AlbumForm
 	CoverImage *routes.ImageID
-	AlbumCount uint64
-	ImageCount uint64
-	State      FormState
-	Flash      *Flash
+	Sorts      []dbo.AlbumSort
+	GroupBys   []dbo.AlbumGroupBy
+	// key of a generated child album, nil for normal albums
+	GeneratorKey *string
+	AlbumCount   uint64
+	ImageCount   uint64
+	State        FormState
+	Flash        *Flash
 }
 
 type AlbumPageContext struct {
@@ -10,6 +10,9 @@ import (
 	focusdata "github.com/ignisVeneficus/lumenta/data"
 	rootData "github.com/ignisVeneficus/lumenta/data"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/derivative"
+	"github.com/ignisVeneficus/lumenta/pipeline"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
 	"github.com/ignisVeneficus/lumenta/server/routes"
 	"github.com/ignisVeneficus/lumenta/tpl/data"
 	grid "github.com/ignisVeneficus/lumenta/tpl/grid/data"
@@ -27,6 +30,12 @@ type ImageSync struct {
 	LastUpdated *time.Time
 }
 
+// DerivativeError is the last failed derivative generation of the image.
+type DerivativeError struct {
+	Error string
+	At    time.Time
+}
+
 type PageImage struct {
 	dbo.Image
 	Realpath      string
@@ -40,6 +49,69 @@ type PageImage struct {
 	Albums        rootData.Forest[*data.ViewTreeNode]
 	MetadataDB    []MetadataValue
 	Metadata      []MetadataValue
+
+	DerivativeError *DerivativeError
+	Explain         *ImageExplain
+}
+
+// ImageExplain is the on demand evaluation of the current rules on the image.
+type ImageExplain struct {
+	Evaluation  ruleengine.RuleEvaluation
+	EvaluatedAt time.Time
+	Entries     []ImageExplainEntry
+}
+
+type ImageExplainEntry struct {
+	pipeline.ExplainEntry
+}
+
+func CreateImageExplain(result *pipeline.ExplainResult) *ImageExplain {
+	ret := &ImageExplain{
+		Evaluation:  result.Evaluation,
+		EvaluatedAt: result.EvaluatedAt,
+		Entries:     make([]ImageExplainEntry, len(result.Entries)),
+	}
+	for i, e := range result.Entries {
+		ret.Entries[i] = ImageExplainEntry{e}
+	}
+	return ret
+}
+
+// State is the why / why not of the entry:
+// album: in, out, join (added on the next sync), leave (removed on the next sync),
+// over_limit (matches, but after the album limit)
+// acl: applied, shadowed (an earlier rule won), no_match
+func (e ImageExplainEntry) State() string {
+	if e.Bound != nil {
+		switch {
+		case *e.Bound && e.Match:
+			return "in"
+		case *e.Bound:
+			return "leave"
+		case e.OverLimit && e.Match:
+			return "over_limit"
+		case e.Match:
+			return "join"
+		}
+		return "out"
+	}
+	switch {
+	case e.Applied:
+		return "applied"
+	case e.Match:
+		return "shadowed"
+	}
+	return "no_match"
+}
+
+func (e ImageExplainEntry) Label() string {
+	if e.AlbumID != nil {
+		return e.AlbumName
+	}
+	if e.User != nil {
+		return string(e.Role) + ": " + *e.User
+	}
+	return string(e.Role)
 }
 
 type MetadataValue struct {
@@ -100,3 +172,12 @@ func (pi PageImage) NormalisedSubject() string {
 func (pi PageImage) RoutesImagedID() routes.ImageID {
 	return routes.ImageID(*pi.ID)
 }
+func (pi PageImage) DerivativeVersion() string {
+	return derivative.Version(pi.Image)
+}
+func (pi PageImage) ExplainAlbumsURL() template.URL {
+	return template.URL(routes.BuildAdminImgPath(pi.RoutesImagedID()).WithParam(routes.ExplainParam, string(ruleengine.EvaluationAlbum)).String())
+}
+func (pi PageImage) ExplainACLURL() template.URL {
+	return template.URL(routes.BuildAdminImgPath(pi.RoutesImagedID()).WithParam(routes.ExplainParam, string(ruleengine.EvaluationACL)).String())
+}
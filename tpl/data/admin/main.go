@@ -1,13 +1,32 @@
 package admin
 
 import (
+	"path/filepath"
+
+	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/server/routes"
+	"github.com/ignisVeneficus/lumenta/tpl"
 	"github.com/ignisVeneficus/lumenta/tpl/data"
 )
 
 type MainPageContext struct {
 	data.NavigationContext
-	Cards []DashboardCard
+	Cards             []DashboardCard
+	ColorWarnings     []ColorWarningData
+	ColorWarningCount uint64
+}
+
+type ColorWarningData struct {
+	dbo.ColorWarning
 }
+
+func (cw ColorWarningData) RoutesImageID() routes.ImageID {
+	return routes.ImageID(cw.ImageID)
+}
+func (cw ColorWarningData) FullPathText() string {
+	return tpl.CreateSpacePath(filepath.Join(cw.Root, cw.Path, cw.Filename+"."+cw.Ext))
+}
+
 type DashboardCard struct {
 	ID           string // "images", "albums"
 	TitleKey     string
@@ -141,6 +141,15 @@ type SingleMap struct {
 	Color *string
 }
 
+// GPSPinColor returns the map pin style of the coordinate source, nil is the default pin
+func GPSPinColor(src *dbo.GPSSource) *string {
+	if src == nil || *src != dbo.GPSSourceGPX {
+		return nil
+	}
+	color := "gpx"
+	return &color
+}
+
 type ImagePageContext struct {
 	NavigationContext
 	Image      PageImage
@@ -3,7 +3,9 @@ package data
 import (
 	"github.com/ignisVeneficus/lumenta/data"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/derivative"
 	"github.com/ignisVeneficus/lumenta/server/routes"
+	"github.com/ignisVeneficus/lumenta/utils"
 )
 
 type PageImage struct {
@@ -19,6 +21,14 @@ func (pi PageImage) RoutesImagedID() routes.ImageID {
 	return routes.ImageID(*pi.Image.ID)
 }
 
+func (pi PageImage) DerivativeVersion() string {
+	return derivative.Version(pi.Image)
+}
+
+func (pi PageImage) VideoMimeType() string {
+	return utils.VideoMimeType(pi.Image.Ext)
+}
+
 type Metadata struct {
 	Title          string
 	Description    string
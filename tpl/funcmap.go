@@ -24,9 +24,13 @@ func DefaultFuncMap(i18n *i18n.Service, iconMap data.IconMap) template.FuncMap {
 		"tileImgList":   functions.TileImgList,
 		"gridRectToVar": functions.RectsToStyleVars,
 		"gridTileRole":  functions.TileRole,
+		"gridTileSizes": functions.TileSizes,
 		"toPercent":     ToPercent,
 
 		"imagePath":      functions.ImagePath,
+		"pictureSources": functions.PictureSources,
+		"srcset":         functions.Srcset,
+		"videoPath":      functions.VideoPath,
 		"imagePagePath":  functions.ImagePagePath,
 		"tagsRootPath":   functions.TagsRootPath,
 		"tagPath":        functions.TagPath,
@@ -3,6 +3,7 @@ package functions
 import (
 	"fmt"
 	"html/template"
+	"math"
 	"strings"
 
 	"github.com/ignisVeneficus/lumenta/server/routes"
@@ -39,6 +40,19 @@ func TileRole(img gridData.GridImage) string {
 
 }
 
+// TileSizes is the sizes attribute value of a tile by its span in the 12 and 6 column
+// layouts, the breakpoints follow the .imageGrid rules of public-main.css.
+func TileSizes(img gridData.GridImage) string {
+	vw := func(grid int) string {
+		l, ok := img.Layouts[grid]
+		if !ok || l.Rect == nil {
+			return "100vw"
+		}
+		return fmt.Sprintf("%dvw", int(math.Ceil(float64(l.Rect.W)*100/float64(grid))))
+	}
+	return "(max-width: 600px) 100vw, (max-width: 1024px) " + vw(6) + ", " + vw(12)
+}
+
 func genImgSrc(imageID routes.ImageID, width int) string {
 	derivative := fmt.Sprintf("w%d", width)
 	return fmt.Sprintf("%s %dw", routes.CreateDerivativePath(imageID, derivative), width)
@@ -1,15 +1,82 @@
 package functions
 
 import (
+	"fmt"
 	"html/template"
+	"strings"
 
+	"github.com/ignisVeneficus/lumenta/config"
+	derivativeConfig "github.com/ignisVeneficus/lumenta/config/derivative"
 	"github.com/ignisVeneficus/lumenta/server/routes"
 )
 
-func ImagePath(imageID routes.ImageID, derivative string) template.URL {
+// ImagePath is the derivative URL, with the optional cache-busting version of the image.
+func ImagePath(imageID routes.ImageID, derivative string, version ...string) template.URL {
+	if len(version) > 0 {
+		return template.URL(routes.CreateDerivativeVersionPath(imageID, derivative, version[0]))
+	}
 	return template.URL(routes.CreateDerivativePath(imageID, derivative))
 }
 
+// derivativeSrcset: the URLs of the ladder with their widths.
+// format: empty for the Accept header negotiation.
+func derivativeSrcset(ladder []derivativeConfig.DerivativeConfig, imageID routes.ImageID, version, format string) string {
+	parts := make([]string, 0, len(ladder))
+	for _, d := range ladder {
+		url := routes.CreateDerivativeVersionPath(imageID, d.Postfix, version)
+		if format != "" {
+			url = routes.CreateDerivativeFormatPath(imageID, d.Postfix, version, format)
+		}
+		if d.MaxWidth > 0 {
+			url = fmt.Sprintf("%s %dw", url, d.MaxWidth)
+		}
+		parts = append(parts, url)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sizesAttr(sizes []string) string {
+	if len(sizes) == 0 || sizes[0] == "" {
+		return ""
+	}
+	return ` sizes="` + template.HTMLEscapeString(sizes[0]) + `"`
+}
+
+// Srcset is the srcset and sizes attribute of an <img> for the widths of the derivative,
+// empty if it has no ladder. sizes: the displayed width, e.g. "(max-width: 640px) 100vw, 50vw".
+func Srcset(imageID routes.ImageID, derivative, version, sizes string) template.HTMLAttr {
+	ladder := derivativeConfig.Ladder(config.Global().Derivatives.Sizes, derivative)
+	if len(ladder) < 2 {
+		return ""
+	}
+	return template.HTMLAttr(`srcset="` + template.HTMLEscapeString(derivativeSrcset(ladder, imageID, version, "")) + `"` + sizesAttr([]string{sizes}))
+}
+
+// PictureSources are the <source> elements of a <picture> for the formats of the
+// derivative besides the jpeg, the <img> of the jpeg follows them in the template.
+// A derivative with widths gets its whole ladder, sizes as for Srcset.
+func PictureSources(imageID routes.ImageID, derivative, version string, sizes ...string) template.HTML {
+	ladder := derivativeConfig.Ladder(config.Global().Derivatives.Sizes, derivative)
+	if len(ladder) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, f := range ladder[len(ladder)-1].Formats {
+		if f == derivativeConfig.FormatJPEG {
+			continue
+		}
+		srcset := derivativeSrcset(ladder, imageID, version, f)
+		if len(ladder) == 1 {
+			srcset = routes.CreateDerivativeFormatPath(imageID, derivative, version, f)
+		}
+		fmt.Fprintf(&b, `<source type="%s" srcset="%s"%s>`,
+			derivativeConfig.FormatMimeType(f),
+			template.HTMLEscapeString(srcset),
+			sizesAttr(sizes))
+	}
+	return template.HTML(b.String())
+}
+
 func AlbumsRootPath() template.URL {
 	return template.URL(routes.CreateAlbumsRootPath())
 }
@@ -86,3 +153,7 @@ func ApiAdminTagsPathView(view string) template.URL {
 	}
 	return template.URL(path.String())
 }
+
+func VideoPath(imageID routes.ImageID) template.URL {
+	return template.URL(routes.CreateVideoPath(imageID))
+}
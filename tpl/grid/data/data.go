@@ -113,6 +113,7 @@ func (s Span) ToRect() *Rect {
 
 type GridImage struct {
 	ImgId       routes.ImageID
+	Version     string // cache-busting version of the derivative URLs
 	Title       string
 	Focus       data.Focus
 	AspectClass Aspect
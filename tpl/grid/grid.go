@@ -5,6 +5,7 @@ import (
 
 	"github.com/ignisVeneficus/lumenta/data"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/derivative"
 	"github.com/ignisVeneficus/lumenta/server/routes"
 	"github.com/rs/zerolog/log"
 
@@ -106,6 +107,7 @@ func BuildGrid(images []dbo.Image, gridCfg gridConfig.GridConfig, salt uint64, u
 		}
 		gi := gridData.GridImage{
 			ImgId:       routes.ImageID(*img.ID),
+			Version:     derivative.Version(img),
 			Title:       title,
 			Focus:       data.ResolveFocus(img.FocusX, img.FocusY, data.ImageFocusMode(img.FocusMode)),
 			Rating:      rating,
@@ -6,6 +6,7 @@ import (
 	"html/template"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ignisVeneficus/logging"
@@ -79,15 +80,30 @@ func newAlbumPageContext(album dbo.Album) adminData.AlbumContext {
 			ACLLevel:    strconv.FormatUint(uint64(album.ACLLevel), 10),
 			ACLUserID:   strconv.FormatUint(uint64(album.ACLUserID), 10),
 			Rank:        tpl.UintToString(&album.Rank),
+			Sort:        string(album.GetSort()),
+			Limit:       limitToString(album.Limit),
+			GroupBy:     string(album.GroupBy),
+			GroupParam:  album.GroupParam,
 		},
-		CoverImage: (*routes.ImageID)(album.CoverImageID),
-		State:      state,
+		CoverImage:   (*routes.ImageID)(album.CoverImageID),
+		Sorts:        dbo.AlbumSorts,
+		GroupBys:     dbo.AlbumGroupBys,
+		GeneratorKey: album.GeneratorKey,
+		State:        state,
 	}
 }
 
+func limitToString(limit *uint32) string {
+	if limit == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*limit), 10)
+}
+
 func toDBAlbum(album dbo.Album, form adminData.AlbumForm) (dbo.Album, validate.ValidationErrors) {
 	// only conversions errors
 	validateErrors := make(validate.ValidationErrors)
+	inherited := album
 
 	album.Name = form.Name
 	parent, err := utils.StrToPtrUint64(form.ParentID)
@@ -103,6 +119,19 @@ func toDBAlbum(album dbo.Album, form adminData.AlbumForm) (dbo.Album, validate.V
 		album.Description = &form.Description
 	}
 	album.RuleJSON = json.RawMessage(form.RuleJSON)
+	album.Sort = dbo.AlbumSort(form.Sort)
+	album.GroupBy = dbo.AlbumGroupBy(form.GroupBy)
+	album.GroupParam = strings.TrimSpace(form.GroupParam)
+	limit, err := strconv.ParseUint(form.Limit, 10, 32)
+	switch {
+	case form.Limit == "":
+		album.Limit = nil
+	case err != nil || limit == 0:
+		validateErrors.AddError(definitions.AlbumFieldLimit, "Not a positive integer number")
+	default:
+		l := uint32(limit)
+		album.Limit = &l
+	}
 	aclLevel, err := utils.StrToUint64(form.ACLLevel)
 	if err != nil {
 		validateErrors.AddError(definitions.AlbumFieldACLLevel, "Not an integer number")
@@ -119,6 +148,13 @@ func toDBAlbum(album dbo.Album, form adminData.AlbumForm) (dbo.Album, validate.V
 	}
 	album.ACLUserID = dbo.UserID(userId)
 
+	if album.IsGenerated() {
+		// inherited from the generator album, the sync copies them on every run
+		album.Sort = inherited.Sort
+		album.Limit = inherited.Limit
+		album.ACLLevel = inherited.ACLLevel
+		album.ACLUserID = inherited.ACLUserID
+	}
 	return album, validateErrors
 }
 
@@ -16,7 +16,10 @@ import (
 	"github.com/ignisVeneficus/lumenta/db"
 	"github.com/ignisVeneficus/lumenta/db/dao"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
+	"github.com/ignisVeneficus/lumenta/derivative"
 	"github.com/ignisVeneficus/lumenta/internal/i18n"
+	"github.com/ignisVeneficus/lumenta/pipeline"
+	"github.com/ignisVeneficus/lumenta/ruleengine"
 	"github.com/ignisVeneficus/lumenta/server/routes"
 	"github.com/ignisVeneficus/lumenta/tpl"
 	tplData "github.com/ignisVeneficus/lumenta/tpl/data"
@@ -227,8 +230,9 @@ func ImagePage(r *tpl.TemplateResolver, cfg config.Config, i18n *i18n.Service) g
 		}
 		if image.Latitude != nil && image.Longitude != nil {
 			imageCtx.Image.SingleMap = &tplData.SingleMap{
-				Lat:  *image.Latitude,
-				Long: *image.Longitude,
+				Lat:   *image.Latitude,
+				Long:  *image.Longitude,
+				Color: tplData.GPSPinColor(image.GPSSource),
 			}
 		}
 		albumIDs := make([]routes.AlbumID, 0)
@@ -236,6 +240,39 @@ func ImagePage(r *tpl.TemplateResolver, cfg config.Config, i18n *i18n.Service) g
 			albumIDs = append(albumIDs, routes.AlbumID(ai))
 		}
 		imageCtx.Image.Covers = albumIDs
+		if f, ok := derivative.Get().ImageFailure(uint64(imageID)); ok {
+			imageCtx.Image.DerivativeError = &adminData.DerivativeError{
+				Error: f.Error,
+				At:    f.At,
+			}
+		}
+
+		// why / why not, on demand
+		var explain *pipeline.ExplainResult
+		switch ruleengine.RuleEvaluation(c.Query(routes.ExplainParam)) {
+		case ruleengine.EvaluationAlbum:
+			var albumID *uint64
+			if s := c.Query(routes.AlbumParam); s != "" {
+				id, err := strconv.ParseUint(s, 10, 64)
+				if err != nil {
+					logScope.ExitErr(fmt.Errorf("invalid album Id"))
+					c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid album Id"})
+					return
+				}
+				albumID = &id
+			}
+			explain, err = pipeline.ExplainImageAlbums(database, ctx, dbImageID, albumID)
+		case ruleengine.EvaluationACL:
+			explain, err = pipeline.ExplainImageACL(database, ctx, cfg.Sync.ACLRules, dbImageID)
+		}
+		if err != nil {
+			logScope.ExitErr(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if explain != nil {
+			imageCtx.Image.Explain = adminData.CreateImageExplain(explain)
+		}
 
 		if err := r.RenderPage(c.Writer, "admin/image", imageCtx, loc, i18n); err != nil {
 			c.AbortWithStatus(http.StatusInternalServerError)
@@ -20,6 +20,9 @@ import (
 	adminData "github.com/ignisVeneficus/lumenta/tpl/data/admin"
 )
 
+// colorWarningLimit: the newest warnings listed on the dashboard
+const colorWarningLimit = 50
+
 func createDashboardStat(label, value string) adminData.DashboardStat {
 	return adminData.DashboardStat{
 		Label: label,
@@ -190,8 +193,27 @@ func MainPage(r *tpl.TemplateResolver, cfg config.Config, i18n *i18n.Service) gi
 		fileSyncCard := getSyncFilesCard(syncStat)
 		cards = append(cards, fileSyncCard)
 
+		warnings, err := dao.QueryColorWarnings(database, ctx, colorWarningLimit)
+		if err != nil {
+			logScope.ExitErr(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		warningCount, err := dao.CountColorWarnings(database, ctx)
+		if err != nil {
+			logScope.ExitErr(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		colorWarnings := make([]adminData.ColorWarningData, len(warnings))
+		for i, w := range warnings {
+			colorWarnings[i] = adminData.ColorWarningData{ColorWarning: w}
+		}
+
 		mainPageCtx := adminData.MainPageContext{
-			Cards: cards,
+			Cards:             cards,
+			ColorWarnings:     colorWarnings,
+			ColorWarningCount: warningCount,
 		}
 		pageCtx := mainPageCtx.GetPage()
 		tpl.CreatePageContext(pageCtx, cfg, c, "main", data.SurfaceAdmin)
@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"html/template"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -48,8 +50,9 @@ func CreateImage(c context.Context, cfg config.Config, db *sql.DB, image dbo.Ima
 
 	if image.Latitude != nil && image.Longitude != nil {
 		singleMap = &tplData.SingleMap{
-			Lat:  *image.Latitude,
-			Long: *image.Longitude,
+			Lat:   *image.Latitude,
+			Long:  *image.Longitude,
+			Color: tplData.GPSPinColor(image.GPSSource),
 		}
 	}
 	albumIds, err := dao.QueryAlbumsIDByImageID(db, ctx, *image.ID)
@@ -223,6 +226,25 @@ func handleImgMetadata(ctx context.Context, cfg config.Config, image dbo.Image)
 	photo.Data = photoList
 	blocks = append(blocks, photo)
 
+	if image.IsVideo() {
+		video := tplData.MetadataBlock{
+			Label: "Video",
+		}
+		videoList := make([]string, 0, 2)
+		if image.Duration != nil {
+			videoList = append(videoList, formatDuration(*image.Duration))
+		}
+		delete(imageMetadata, data.MetaDuration)
+		codecs := make([]string, 0, 2)
+		codecs = addListIfNotEmpty(codecs, imageMetadata, data.MetaVideoCodec)
+		codecs = addListIfNotEmpty(codecs, imageMetadata, data.MetaAudioCodec)
+		if len(codecs) > 0 {
+			videoList = append(videoList, strings.Join(codecs, " · "))
+		}
+		video.Data = videoList
+		blocks = append(blocks, video)
+	}
+
 	takenAt := tplData.MetadataBlock{
 		Label: "Taken at",
 	}
@@ -231,12 +253,39 @@ func handleImgMetadata(ctx context.Context, cfg config.Config, image dbo.Image)
 	takenAt.Data = takenAtList
 	blocks = append(blocks, takenAt)
 
+	location := tplData.MetadataBlock{
+		Label: "Location",
+	}
+	place := make([]string, 0, 3)
+	place = addListIfNotEmpty(place, imageMetadata, data.MetaCity)
+	place = addListIfNotEmpty(place, imageMetadata, data.MetaRegion)
+	place = addListIfNotEmpty(place, imageMetadata, data.MetaCountry)
+	delete(imageMetadata, data.MetaPlaceTags)
+	delete(imageMetadata, data.MetaOverrideTags)
+	if len(place) > 0 {
+		location.Data = []string{strings.Join(place, ", ")}
+		blocks = append(blocks, location)
+	}
+
 	ret.Blocks = blocks
 	// TODO: remaining metada with label system from the presentationConfig
 
 	return ret
 
 }
+
+// formatDuration formats seconds as m:ss or h:mm:ss.
+func formatDuration(seconds float64) string {
+	total := int(math.Round(seconds))
+	h := total / 3600
+	m := (total % 3600) / 60
+	s := total % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
 func addListIfNotEmpty(list []string, data data.Metadata, key string) []string {
 	mvalue, ok := data[key]
 	if !ok {
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
+	"mime"
 	"os"
 	"path/filepath"
 	"strings"
@@ -38,14 +39,35 @@ func ConcatLocalPath(dir string, name string, ext string) string {
 func ConcatGlobalPath(root, dir, name, ext string) string {
 	return filepath.Join(root, dir, name+"."+ext)
 }
-func ConcatGlobalDerivativePath(root, imgRoot, dir, name, postfix, ext string) string {
-	return filepath.Join(root, imgRoot, dir, name+"-"+postfix+"."+ext)
+
+// ConcatGlobalDerivativePath: the source extension is part of the name,
+// IMG_1.jpg and IMG_1.tif of the same folder get separate derivatives.
+func ConcatGlobalDerivativePath(root, imgRoot, dir, name, srcExt, postfix, ext string) string {
+	return filepath.Join(root, imgRoot, dir, name+"."+srcExt+"-"+postfix+"."+ext)
 }
 
 func NormalizeExt(ext string) string {
 	return strings.TrimPrefix(strings.ToLower(ext), ".")
 }
 
+// VideoMimeType returns the content type of a video by its normalized extension.
+func VideoMimeType(ext string) string {
+	switch ext {
+	case "mp4", "m4v":
+		return "video/mp4"
+	case "mov", "qt":
+		return "video/quicktime"
+	case "webm":
+		return "video/webm"
+	case "mkv":
+		return "video/x-matroska"
+	}
+	if t := mime.TypeByExtension("." + ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
 func FileExists(fileName string) (bool, error) {
 	_, err := os.Stat(fileName)
 	if os.IsNotExist(err) {
@@ -1,6 +1,8 @@
 package validate
 
 import (
+	"strconv"
+
 	"github.com/ignisVeneficus/lumenta/data"
 	"github.com/ignisVeneficus/lumenta/db/dbo"
 	"github.com/ignisVeneficus/lumenta/definitions"
@@ -29,6 +31,21 @@ func ValidateAlbum(album dbo.Album, albums []*dbo.AlbumGraph) ValidationErrors {
 	if !dbo.ValidateACLLevel(album.ACLLevel) {
 		validateErrors.AddError(definitions.AlbumFieldACLLevel, "Invalid value")
 	}
+	if !dbo.ValidateAlbumSort(album.GetSort()) {
+		validateErrors.AddError(definitions.AlbumFieldSort, "Invalid value")
+	}
+	switch {
+	case !dbo.ValidateAlbumGroupBy(album.GroupBy):
+		validateErrors.AddError(definitions.AlbumFieldGroupBy, "Invalid value")
+	case album.IsGenerated() && album.GroupBy != dbo.AlbumGroupByNone:
+		validateErrors.AddError(definitions.AlbumFieldGroupBy, "A generated album cannot be a generator.")
+	case album.GroupBy == dbo.AlbumGroupByTag && album.GroupParam == "":
+		validateErrors.AddError(definitions.AlbumFieldGroupParam, "The field is mandatory")
+	case album.GroupBy == dbo.AlbumGroupByPath:
+		if _, err := strconv.Atoi(album.GroupParam); err != nil {
+			validateErrors.AddError(definitions.AlbumFieldGroupParam, "Not an integer number")
+		}
+	}
 	if album.ParentID != nil && album.ParentID != album.ID {
 		var albumId dbo.AlbumID = 0
 		if album.ID != nil {
@@ -29,6 +29,15 @@ function enableEventuallyAvailableImage(img, {
 
       console.info("retry attempt:", attempt, url.toString());
 
+      // inside a <picture> the browser may load a <source>, reload them too
+      if (img.parentElement && img.parentElement.tagName === "PICTURE") {
+        img.parentElement.querySelectorAll("source").forEach(source => {
+          source.srcset = bustSrcset(source.srcset);
+        });
+      }
+      if (img.srcset) {
+        img.srcset = bustSrcset(img.srcset);
+      }
       img.src = url.toString();
 
       delay = Math.min(delay * 1.7, maxDelay);
@@ -63,12 +72,34 @@ function enableEventuallyAvailableImage(img, {
     }
   }
 }
+// bustSrcset adds the retry parameter to every candidate of a srcset
+function bustSrcset(srcset) {
+  return srcset.split(",").map(candidate => {
+    const [src, ...descriptor] = candidate.trim().split(/\s+/);
+    const url = new URL(src, location.href);
+    url.searchParams.set("_r", Date.now());
+    return [url.toString(), ...descriptor].join(" ");
+  }).join(", ");
+}
+// video poster frames are derivatives too: load them through a hidden image
+function enableEventuallyAvailablePoster(video) {
+  if (!video.dataset.poster) return;
+  const img = new Image();
+  img.addEventListener("load", () => {
+    video.poster = img.src;
+  });
+  img.src = video.dataset.poster;
+  enableEventuallyAvailableImage(img);
+}
 function initDerivativeRetry(root = document) {
   root.querySelectorAll(".derivative-img").forEach(img => {
     enableEventuallyAvailableImage(img);
   });
+  root.querySelectorAll(".derivative-video").forEach(video => {
+    enableEventuallyAvailablePoster(video);
+  });
 }
 
 document.addEventListener("DOMContentLoaded", () => {
   initDerivativeRetry();
-});
\ No newline at end of file
+});
\ No newline at end of file
@@ -80,6 +80,10 @@
                 ret.op = value;
             }
             conf.root.querySelectorAll('.xrule-data').forEach(row => {
+                if(row.dataset.raw){
+                    ret.rules.push(JSON.parse(row.dataset.raw));
+                    return;
+                }
                 let data={};
                 row.querySelectorAll('[data-name]').forEach(piece=>{
                     let name = piece.dataset.name;
@@ -107,6 +111,10 @@
                 return node.xselect.getSelected();
             case "string":
                 return node.value;
+            case "number":
+                return node.value === "" ? null : Number(node.value);
+            case "numbers":
+                return node.value.split(',').map(v => v.trim()).filter(v => v !== "").map(Number);
             case "checkbox":
                 return node.checked;
         }
@@ -119,7 +127,12 @@
             case "xselect":
                 node.xselect.setSelected(value);
             case "string":
+            case "number":
                 node.value=value;
+                break;
+            case "numbers":
+                node.value=Array.isArray(value) ? value.join(', ') : value;
+                break;
             case "checkbox":
                 node.checked=!!value
         }
@@ -154,6 +167,10 @@
                 {   id:"any",
                     display:"Any rules",
                     pill:"Any rules"
+                },
+                {   id:"not",
+                    display:"No rules",
+                    pill:"No rules"
                 }],
             selected:["all"]
         });
@@ -260,6 +277,44 @@
         return row;
     }
 
+    // nested groups are not editable here, they are kept as they are
+    function addRawRule(conf,rule){
+        const row= document.createElement('div');
+        row.className = `xrule-row xrule-data xrule-raw`;
+        row.dataset.raw = JSON.stringify(rule);
+
+        const label=document.createElement('div');
+        label.textContent = 'Group';
+        label.className = `xrule-label`;
+        row.appendChild(label);
+
+        const op=document.createElement('div');
+        op.textContent = rule.op || 'all';
+        row.appendChild(op);
+
+        const block=document.createElement('div');
+        block.className = `xrule-block xrule-block-group`;
+        block.textContent = `${(rule.rules || []).length} nested rules`;
+        row.appendChild(block);
+
+        const deleteButton = document.createElement('button');
+        deleteButton.className = `xrule rule-delete action`;
+        deleteButton.type = "button";
+        deleteButton.innerHTML = `<i class="fa-solid fa-xmark xrule-icon icon" title="Delete rule"></i>`;
+        deleteButton.addEventListener('click', (e) => {
+                    e.stopPropagation();
+                    row.remove();
+                });
+
+        const wrapper = document.createElement('div');
+        wrapper.className="xrule-wrapper";
+        wrapper.appendChild(deleteButton);
+        row.appendChild(wrapper);
+
+        conf.root.insertBefore(row,conf.nodes.lastRow);
+        return row;
+    }
+
     function fillLayout(conf){
         const rules = conf.rules;
         const groupOp = conf.root.querySelector('.rule-group-op');
@@ -267,6 +322,10 @@
             groupOp.xselect.setSelected(rules.op || "all");
         }
         rules.rules.forEach(rule=>{
+            if(rule.type === "group"){
+                addRawRule(conf,rule);
+                return;
+            }
             if(rule.type){
                 let ruleData = conf.ruleIds.get(rule.type);
                 if (!ruleData){
@@ -328,6 +387,12 @@
                         display: "After",
                         pill: "After"
                     },
+                    { id: "between", display: "Between dates", pill: "Between" },
+                    { id: "within_last", display: "Within the last (30d, 2w, 6m, 1y)", pill: "Last" },
+                    { id: "anniversary", display: "This day in past years", pill: "This day" },
+                    { id: "monthday", display: "Every year (mm.dd-mm.dd)", pill: "Every year" },
+                    { id: "weekday", display: "On weekdays (sat,sun)", pill: "Weekday" },
+                    { id: "hour", display: "Hour of day (22-5)", pill: "Hour" },
                 ];
             case "rating":
             case "width":
@@ -345,8 +410,26 @@
                         pill: ">"
                     }
                 ];
+            case "meta":
+                return [
+                    { id: "eq", display: "Equal to", pill: "=" },
+                    { id: "ne", display: "Not equal to", pill: "≠" },
+                    { id: "lt", display: "Less than", pill: "<" },
+                    { id: "gt", display: "Greater than", pill: ">" },
+                    { id: "between", display: "Between (min,max)", pill: "Between" },
+                    { id: "in", display: "One of (a,b,c)", pill: "In" },
+                    { id: "regex", display: "Matches regexp", pill: "Regex" },
+                    { id: "exists", display: "Exists", pill: "Exists" },
+                ];
+            case "geo":
+                return [
+                    { id: "radius", display: "Within a distance", pill: "Radius" },
+                    { id: "bbox", display: "Inside a bounding box", pill: "Box" },
+                    { id: "polygon", display: "Inside a GeoJSON polygon", pill: "Polygon" },
+                ];
             case "name":
             case "notchildren":
+            case "expr":
                 return null;
 
         }
@@ -381,6 +464,20 @@
                 dateInput.dataset.name = `date`;
                 dateInput.placeholder="yyyy[.mm[.dd]]";
                 block.appendChild(dateInput);
+
+                const toInput = document.createElement('input');
+                toInput.className = `rule-date-to`;
+                toInput.dataset.type = `string`;
+                toInput.dataset.name = `to`;
+                toInput.placeholder="between: yyyy[.mm[.dd]]";
+                block.appendChild(toInput);
+
+                const recurringInput = document.createElement('input');
+                recurringInput.className = `rule-date-value`;
+                recurringInput.dataset.type = `string`;
+                recurringInput.dataset.name = `value`;
+                recurringInput.placeholder="30d / 12.24-12.26 / sat,sun / 22-5";
+                block.appendChild(recurringInput);
                 break;
             case "name":
                 const nameInput = document.createElement('input');
@@ -460,6 +557,60 @@
                 let chk = createCheckbox("include_children","Include children albums");
                 block.appendChild(chk);
 
+                break;
+            case "meta":
+                const fieldInput = document.createElement('input');
+                fieldInput.className = `rule-meta-field`;
+                fieldInput.dataset.type = `string`;
+                fieldInput.dataset.name = `field`;
+                fieldInput.placeholder="iso";
+                block.appendChild(fieldInput);
+
+                const metaValueInput = document.createElement('input');
+                metaValueInput.className = `rule-meta-value`;
+                metaValueInput.dataset.type = `string`;
+                metaValueInput.dataset.name = `value`;
+                metaValueInput.placeholder="800 / 400,1600 / ^Canon";
+                block.appendChild(metaValueInput);
+                break;
+            case "geo":
+                const centerInput = document.createElement('input');
+                centerInput.className = `rule-geo-center`;
+                centerInput.dataset.type = `numbers`;
+                centerInput.dataset.name = `center`;
+                centerInput.placeholder="center: lat, lon";
+                block.appendChild(centerInput);
+
+                const radiusInput = document.createElement('input');
+                radiusInput.className = `rule-geo-radius`;
+                radiusInput.dataset.type = `number`;
+                radiusInput.dataset.name = `radius_km`;
+                radiusInput.placeholder="radius km: 5";
+                radiusInput.inputMode = "decimal"
+                block.appendChild(radiusInput);
+
+                const bboxInput = document.createElement('input');
+                bboxInput.className = `rule-geo-bbox`;
+                bboxInput.dataset.type = `numbers`;
+                bboxInput.dataset.name = `bbox`;
+                bboxInput.placeholder="box: south, west, north, east";
+                block.appendChild(bboxInput);
+
+                const polygonInput = document.createElement('input');
+                polygonInput.className = `rule-geo-polygon`;
+                polygonInput.dataset.type = `string`;
+                polygonInput.dataset.name = `polygon`;
+                polygonInput.placeholder="polygon: /data/areas/iceland.geojson";
+                block.appendChild(polygonInput);
+                break;
+            case "expr":
+                const exprInput = document.createElement('textarea');
+                exprInput.className = `rule-expr`;
+                exprInput.dataset.type = `string`;
+                exprInput.dataset.name = `expr`;
+                exprInput.rows = 2;
+                exprInput.placeholder='rating >= 4 && camera =~ "X-T"';
+                block.appendChild(exprInput);
                 break;
             case "notchildren":
                 break;
@@ -549,7 +700,22 @@
                     id: "aspect",
                     display: "By aspect ratio",
                     pill: "Aspect"
+                },
+                {
+                    id: "meta",
+                    display: "By metadata field",
+                    pill: "Metadata"
+                },
+                {
+                    id: "geo",
+                    display: "By location",
+                    pill: "Location"
+                },
+                {
+                    id: "expr",
+                    display: "By expression",
+                    pill: "Expression"
                 }
             ]
     }
-})();
\ No newline at end of file
+})();
\ No newline at end of file